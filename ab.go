@@ -0,0 +1,77 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log/slog"
+	"os"
+)
+
+// ComputeAbVmaf scores two distorted encodes against each other. The file named by referenceRole
+// ("a" or "b") takes the reference role and the other is scaled to its resolution before scoring.
+func ComputeAbVmaf(aFilename string, bFilename string, referenceRole string, result chan VmafMetrics) {
+	referenceFilename, testFilename := aFilename, bFilename
+	if referenceRole == "b" {
+		referenceFilename, testFilename = bFilename, aFilename
+	}
+
+	vidioResolution, _ := GetVideoResolutionAndBitrate(referenceFilename)
+	stream, probeErr := SelectVideoStream(referenceFilename)
+	referenceResolution, _ := ReconcileResolution(referenceFilename, vidioResolution, stream, probeErr)
+	if referenceResolution.Height <= 0 || referenceResolution.Width <= 0 {
+		videoLog(referenceFilename).Error("Error reading resolution")
+		result <- VmafMetrics{Mean: -1.0}
+		return
+	}
+	if _, err := SelectVideoStream(testFilename); err != nil {
+		videoLog(testFilename).Error("Error probing", "err", err)
+	}
+	if probeErr == nil {
+		referenceResolution = EffectiveResolution(referenceResolution, stream)
+		if transfer := HdrTransfer(stream); transfer != "" {
+			RegisterHdrReference(referenceFilename, transfer)
+		}
+	}
+
+	defer RemovePrescaled(testFilename)
+	ComputeVmaf(referenceFilename, referenceResolution, testFilename, Resolution{}, SelectVmafModelFor(referenceFilename, referenceResolution, referenceResolution), result)
+}
+
+// RunAbCommand implements the ab subcommand: ab [-reference a|b] <a.mp4> <b.mp4>.
+func RunAbCommand(args []string) int {
+	flags := flag.NewFlagSet("ab", flag.ExitOnError)
+	referenceRole := flags.String("reference", "a", "which encode takes the reference role, a or b")
+	config.RegisterFlags(flags)
+	ParseFlags(flags, args)
+	if err := Preflight(false, true); err != nil {
+		slog.Error("Error checking ffmpeg", "err", err)
+		return 2
+	}
+	if !CheckScoringFlags() {
+		return 2
+	}
+	ApplyHwaccel()
+
+	if flags.NArg() != 2 {
+		fmt.Fprintf(os.Stderr, "Usage: %s ab [-reference a|b] <a.mp4> <b.mp4>\n", os.Args[0])
+		return 2
+	}
+	if *referenceRole != "a" && *referenceRole != "b" {
+		fmt.Fprintf(os.Stderr, "Invalid -reference %q, expected a or b\n", *referenceRole)
+		return 2
+	}
+
+	result := make(chan VmafMetrics, 1)
+	ComputeAbVmaf(flags.Arg(0), flags.Arg(1), *referenceRole, result)
+	metrics := <-result
+	if metrics.Mean < 0 {
+		slog.Error("Error computing VMAF", "a", flags.Arg(0), "b", flags.Arg(1))
+		return 1
+	}
+
+	fmt.Printf("VMAF: %f\n", metrics.Mean)
+	if metrics.Ci != nil {
+		fmt.Printf("95%% CI: %f - %f\n", metrics.Ci.Low, metrics.Ci.High)
+	}
+	return 0
+}
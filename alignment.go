@@ -0,0 +1,45 @@
+package main
+
+import (
+	"fmt"
+)
+
+// When an encode drops or adds leading frames, libvmaf compares every frame against the wrong
+// reference frame and the score collapses. -frame-offset skips frames at the start of one input so
+// the two line up again: a positive offset skips reference frames, for encodes that dropped leading
+// frames, and a negative offset skips encode frames.
+
+// FrameOffsetFilters returns the trim filters, if any, for the test and reference chains.
+func FrameOffsetFilters(offset int) (string, string) {
+	trim := func(frames int) string {
+		return fmt.Sprintf("trim=start_frame=%d,setpts=PTS-STARTPTS", frames)
+	}
+	switch {
+	case offset > 0:
+		return "", trim(offset)
+	case offset < 0:
+		return trim(-offset), ""
+	default:
+		return "", ""
+	}
+}
+
+// CheckFrameAlignment compares the frame counts of the reference and test files, allowing for the
+// configured offset, and describes any mismatch. It returns an empty string when the counts agree
+// or cannot be read from the container headers.
+func CheckFrameAlignment(referenceFilename string, testFilename string) string {
+	referenceStream, err := ProbeVideoStream(referenceFilename)
+	if err != nil {
+		return ""
+	}
+	testStream, err := ProbeVideoStream(testFilename)
+	if err != nil {
+		return ""
+	}
+
+	referenceFrames, testFrames := referenceStream.FrameCount(), testStream.FrameCount()
+	if referenceFrames == 0 || testFrames == 0 || referenceFrames-config.FrameOffset == testFrames {
+		return ""
+	}
+	return fmt.Sprintf("reference has %d frames and encode has %d frames with a frame offset of %d, scores may be misaligned", referenceFrames, testFrames, config.FrameOffset)
+}
@@ -0,0 +1,92 @@
+package main
+
+import (
+	"sort"
+	"sync"
+)
+
+// Statuses of attempted operating points.
+const (
+	// PointOk points were encoded and scored.
+	PointOk = "ok"
+	// PointFailed points failed to encode or to score.
+	PointFailed = "failed"
+	// PointTimeout points were still running when their time ran out.
+	PointTimeout = "timeout"
+	// PointInfeasible points were asked for but lie above the source rate, so they are not encoded.
+	PointInfeasible = "infeasible"
+	// PointSkipped points were never attempted because an earlier point of the walk failed.
+	PointSkipped = "skipped"
+)
+
+// attempts collects every operating point attempted for each reference, so coverage gaps show up
+// in the results instead of being silently left out of the hull.
+var attempts = struct {
+	sync.Mutex
+	byFilename map[string][]ConvexHullPoint
+}{byFilename: make(map[string][]ConvexHullPoint)}
+
+// RecordAttempt records the outcome of one operating point of referenceFilename. A point measured
+// again, as when a failed point is retried, replaces the earlier attempt.
+func RecordAttempt(referenceFilename string, point ConvexHullPoint) {
+	attempts.Lock()
+	defer attempts.Unlock()
+	recorded := attempts.byFilename[referenceFilename]
+	for i := range recorded {
+		if recorded[i].Resolution == point.Resolution && recorded[i].Rate == point.Rate {
+			recorded[i] = point
+			return
+		}
+	}
+	attempts.byFilename[referenceFilename] = append(recorded, point)
+}
+
+// RecordSkippedRates records placeholder points for rates that were not attempted.
+func RecordSkippedRates(referenceFilename string, rates []int, status string, reason string) {
+	for _, rate := range rates {
+		RecordAttempt(referenceFilename, ConvexHullPoint{Rate: rate, Status: status, Reason: reason})
+	}
+}
+
+// MeasuredAttempt returns the point recorded as measured at an operating point of referenceFilename.
+func MeasuredAttempt(referenceFilename string, point OperatingPoint) (ConvexHullPoint, bool) {
+	attempts.Lock()
+	defer attempts.Unlock()
+	for _, recorded := range attempts.byFilename[referenceFilename] {
+		if recorded.Resolution == point.Resolution && recorded.Rate == point.Rate && recorded.Status == PointOk {
+			recorded.Status = ""
+			return recorded, true
+		}
+	}
+	return ConvexHullPoint{}, false
+}
+
+// AttemptsOf returns the attempts recorded so far for referenceFilename.
+func AttemptsOf(referenceFilename string) []ConvexHullPoint {
+	attempts.Lock()
+	defer attempts.Unlock()
+	return append([]ConvexHullPoint(nil), attempts.byFilename[referenceFilename]...)
+}
+
+// TakeAttempts returns and forgets the attempts of referenceFilename, highest rate first. Attempts
+// that are not ok are left out when config.HideFailures is set.
+func TakeAttempts(referenceFilename string) []ConvexHullPoint {
+	attempts.Lock()
+	recorded := attempts.byFilename[referenceFilename]
+	delete(attempts.byFilename, referenceFilename)
+	attempts.Unlock()
+
+	var kept []ConvexHullPoint
+	for _, point := range recorded {
+		if !config.HideFailures || point.Status == PointOk {
+			kept = append(kept, point)
+		}
+	}
+	sort.SliceStable(kept, func(i, j int) bool {
+		if kept[i].Rate != kept[j].Rate {
+			return kept[i].Rate > kept[j].Rate
+		}
+		return kept[i].Resolution.Height > kept[j].Resolution.Height
+	})
+	return kept
+}
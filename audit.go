@@ -0,0 +1,123 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// toolVersion is the version of this tool, set at build time with -ldflags "-X main.toolVersion=...".
+var toolVersion = "dev"
+
+// RunMetadata describes the run that produced a result.
+type RunMetadata struct {
+	ToolVersion    string
+	FfmpegVersion  string          `json:",omitempty"`
+	LibvmafVersion string          `json:",omitempty"`
+	VmafSampling   string          `json:",omitempty"`
+	VmafPlanes     string          `json:",omitempty"`
+	VmafWindow     *ScoringWindow  `json:",omitempty"`
+	Settings       *EncodeSettings `json:",omitempty"`
+}
+
+var runMetadata RunMetadata
+
+// NewRunMetadata collects the metadata for the current run. The ffmpeg version is queried once here,
+// since results are not comparable across upgrades. The libvmaf version takes a scoring pass to read,
+// so it is only queried with -audit, and versions missing from either run are not compared.
+func NewRunMetadata() RunMetadata {
+	metadata := RunMetadata{ToolVersion: toolVersion}
+	if config.VmafSampling != AllFramesSampling {
+		metadata.VmafSampling = config.VmafSampling
+	}
+	metadata.VmafPlanes = config.VmafPlanes
+	metadata.VmafWindow, _ = ActiveScoringWindow()
+	settings := ActiveEncodeSettings()
+	metadata.Settings = &settings
+	ffmpegVersion, err := FfmpegVersion()
+	if err != nil {
+		slog.Error("Error getting ffmpeg version", "err", err)
+	}
+	metadata.FfmpegVersion = ffmpegVersion
+	if config.DryRun || !config.Audit {
+		// A dry run writes no results to compare and executes no libvmaf pass.
+		return metadata
+	}
+	libvmafVersion, err := LibvmafVersion()
+	if err != nil {
+		slog.Error("Error getting libvmaf version", "err", err)
+	}
+	metadata.LibvmafVersion = libvmafVersion
+	return metadata
+}
+
+// FfmpegVersion returns the output of ffmpeg -version.
+func FfmpegVersion() (string, error) {
+	output, err := exec.Command(config.FfmpegPath, "-version").Output()
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(string(output)), nil
+}
+
+// LibvmafVersion returns the version of the libvmaf ffmpeg is built against. Neither the filter help
+// nor -buildconf print it, so it is read from the log of a one frame libvmaf pass on a test pattern.
+func LibvmafVersion() (string, error) {
+	directory, err := ScratchDir("vmaf-version")
+	if err != nil {
+		return "", err
+	}
+	defer os.RemoveAll(directory)
+
+	logPath := filepath.Join(directory, "version.json")
+	source := "testsrc=size=176x144:rate=1:duration=1"
+	cmd := FfmpegCommand("-f", "lavfi", "-i", source, "-f", "lavfi", "-i", source, "-lavfi", "libvmaf=log_fmt=json:log_path="+logPath, "-f", "null", "-")
+	if output, err := cmd.CombinedOutput(); err != nil {
+		return "", fmt.Errorf("%w: %s", err, strings.TrimSpace(string(output)))
+	}
+	data, err := os.ReadFile(logPath)
+	if err != nil {
+		return "", err
+	}
+	var log struct {
+		Version string `json:"version"`
+	}
+	if err := json.Unmarshal(data, &log); err != nil {
+		return "", err
+	}
+	return log.Version, nil
+}
+
+// firstLine returns the first line of value, which for ffmpeg -version is the version itself.
+func firstLine(value string) string {
+	if i := strings.IndexByte(value, '\n'); i >= 0 {
+		return value[:i]
+	}
+	return value
+}
+
+// VersionMismatches describes how the ffmpeg and libvmaf versions of two runs differ. Versions that
+// were not recorded are not compared.
+func VersionMismatches(a RunMetadata, b RunMetadata) []string {
+	var mismatches []string
+	if a.FfmpegVersion != "" && b.FfmpegVersion != "" && a.FfmpegVersion != b.FfmpegVersion {
+		mismatches = append(mismatches, fmt.Sprintf("ffmpeg %q differs from %q", firstLine(a.FfmpegVersion), firstLine(b.FfmpegVersion)))
+	}
+	if a.LibvmafVersion != "" && b.LibvmafVersion != "" && a.LibvmafVersion != b.LibvmafVersion {
+		mismatches = append(mismatches, fmt.Sprintf("libvmaf %s differs from %s", a.LibvmafVersion, b.LibvmafVersion))
+	}
+	return mismatches
+}
+
+// CommandHash returns a stable hash of the command line. It hashes the arguments rather than the
+// resolved binary path so identical settings hash the same on every machine.
+func CommandHash(cmd *exec.Cmd) string {
+	sum := sha256.Sum256([]byte(strings.Join(cmd.Args, " ")))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,50 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// VMAF never scores audio, but the sync handling of a production encode still decides which video
+// frames it ships: -vsync cfr duplicates and drops frames to hit a constant rate, and resampling
+// audio with -async to follow the timestamps moves the timestamps the muxer writes the video
+// against. -vsync and -async apply the production settings to every encode, -async keeping the
+// source audio in the encode for the purpose, so the frames scored are the frames shipped. Scoring
+// itself always drops audio. Both settings are recorded in the hull's encode settings.
+
+// vsyncMethods are the video sync methods accepted by -vsync.
+var vsyncMethods = []string{"auto", "passthrough", "cfr", "vfr", "drop"}
+
+// ValidateSync checks -vsync and -async.
+func ValidateSync() error {
+	if config.Vsync != "" {
+		found := false
+		for _, method := range vsyncMethods {
+			if config.Vsync == method {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return fmt.Errorf("unknown -vsync %q, expected one of %s", config.Vsync, strings.Join(vsyncMethods, ", "))
+		}
+	}
+	if config.Async < 0 {
+		return fmt.Errorf("-async %d is negative", config.Async)
+	}
+	return nil
+}
+
+// SyncEncodeArgs returns the encode arguments applying -vsync and -async. The audio of a source
+// without any is skipped.
+func SyncEncodeArgs() []string {
+	var args []string
+	if config.Vsync != "" {
+		args = append(args, "-vsync", config.Vsync)
+	}
+	if config.Async > 0 {
+		args = append(args, "-map", "0:a:0?", "-af", "aresample=async="+strconv.Itoa(config.Async))
+	}
+	return args
+}
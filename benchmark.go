@@ -0,0 +1,80 @@
+package main
+
+import (
+	"log/slog"
+)
+
+// EncodeOnlyMode marks results that hold encode benchmarks and no VMAF scores.
+const EncodeOnlyMode = "encode-only"
+
+// EncodeStat is the benchmark of one encode on the resolution and rate grid.
+type EncodeStat struct {
+	Resolution    Resolution
+	Rate          int
+	EncodeSeconds float64
+	CpuSeconds    float64
+	AchievedRate  int
+	Error         string `json:",omitempty"`
+}
+
+// EncodeBenchmarkResult is the document written for each video in encode-only mode.
+type EncodeBenchmarkResult struct {
+	Metadata RunMetadata
+	Mode     string
+	Encodes  []EncodeStat
+}
+
+// BenchmarkEncodes encodes the reference at every ladder resolution up to its own and every target
+// rate, one encode at a time so the timings do not contend with each other.
+func BenchmarkEncodes(referenceVideoFilename string, referenceVideoResolution Resolution, targetRates []int) []EncodeStat {
+	var stats []EncodeStat
+	for _, resolution := range LadderFor(referenceVideoResolution) {
+		if resolution.Height > referenceVideoResolution.Height {
+			continue
+		}
+		for _, rate := range targetRates {
+			stat := EncodeStat{Resolution: resolution, Rate: rate}
+			encodedFilename := EncodedFilename(referenceVideoFilename, resolution, rate)
+
+			encodeResult := make(chan error, 1)
+			EncodeVideo(referenceVideoFilename, encodedFilename, resolution, rate, encodeResult)
+			usage := TakeEncodeUsage(encodedFilename)
+			stat.EncodeSeconds, stat.CpuSeconds = usage.WallSeconds, usage.CpuSeconds()
+
+			if err := <-encodeResult; err == nil {
+				achievedRate, err := AchievedRate(encodedFilename)
+				if err != nil {
+					stat.Error = err.Error()
+				}
+				stat.AchievedRate = achievedRate
+			} else {
+				stat.Error = err.Error()
+			}
+			RemoveIntermediate(encodedFilename)
+			stats = append(stats, stat)
+		}
+	}
+	return stats
+}
+
+// BenchmarkVideo runs the encode-only benchmark for one video and writes the result to outputFilename.
+func BenchmarkVideo(videoFilename string, resolution Resolution, rate int, outputFilename string) {
+	targetRates := GetTargetRates(rate)
+	if len(targetRates) == 0 {
+		videoLog(videoFilename).Info("No target rates, skipping", "rate", rate)
+		summary.Record(videoFilename, AssetFailed, "no target rates")
+		return
+	}
+
+	result := EncodeBenchmarkResult{Metadata: runMetadata, Mode: EncodeOnlyMode, Encodes: BenchmarkEncodes(videoFilename, resolution, targetRates)}
+	if err := CreateOutputDirectory(outputFilename); err != nil {
+		slog.Error("Error creating output directory", "file", outputFilename, "err", err)
+		summary.Record(videoFilename, AssetFailed, err.Error())
+		return
+	}
+	if err := WriteJson(result, outputFilename); err != nil {
+		summary.Record(videoFilename, AssetFailed, err.Error())
+		return
+	}
+	summary.Record(videoFilename, AssetDone, "")
+}
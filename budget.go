@@ -0,0 +1,71 @@
+package main
+
+import (
+	"fmt"
+	"log/slog"
+	"runtime"
+)
+
+// Every walk in flight runs two ffmpeg processes at once, the candidate resolution and the next one
+// down, for each of its -parallel-rates rates, and every ffmpeg process runs its own threads. -cpu-budget
+// divides a number of cores between the two levels so the product never exceeds the budget:
+//
+//	processes per walk = 2 * max(1, parallel rates)
+//	threads per process = clamp(budget / processes per walk, 1, maxThreadsPerProcess)
+//	walks in flight = max(1, budget / (processes per walk * threads per process))
+//
+// Threads are filled first, up to maxThreadsPerProcess, because one walk's rates are sequential
+// and run as fast as their processes do; beyond that encoders and libvmaf scale poorly, so further
+// cores go to more walks at once. A budget of 16 with one rate at a time gives two processes of
+// eight threads and one walk; a budget of 64 gives four walks. Only when the budget is smaller than
+// the processes of one walk is it exceeded, by running each process single threaded. The Go
+// runtime, which only waits on ffmpeg, is limited to the budget too.
+
+// maxThreadsPerProcess is the thread count above which one ffmpeg process stops scaling well.
+const maxThreadsPerProcess = 8
+
+// CpuPlan is how the CPU budget is divided.
+type CpuPlan struct {
+	// Walks is how many videos are walked at once.
+	Walks int
+	// EncoderThreads is passed to every encode as -threads, zero leaves it to the encoder.
+	EncoderThreads int
+	// VmafThreads is the libvmaf n_threads of every scoring pass.
+	VmafThreads int
+}
+
+// defaultCpuPlan is used without -cpu-budget.
+var defaultCpuPlan = CpuPlan{Walks: 100, VmafThreads: maxThreadsPerProcess}
+
+var cpuPlan = defaultCpuPlan
+
+// PlanCpuBudget divides budget cores between walks and ffmpeg threads, see above.
+func PlanCpuBudget(budget int, parallelRates int) CpuPlan {
+	processesPerWalk := 2 * IntMax(1, parallelRates)
+	threads := IntMin(IntMax(1, budget/processesPerWalk), maxThreadsPerProcess)
+	walks := IntMax(1, budget/(processesPerWalk*threads))
+	return CpuPlan{Walks: walks, EncoderThreads: threads, VmafThreads: threads}
+}
+
+// ApplyCpuBudget sets cpuPlan and GOMAXPROCS from config.CpuBudget and logs the result.
+func ApplyCpuBudget() error {
+	if config.CpuBudget < 0 {
+		return fmt.Errorf("CPU budget %d must not be negative", config.CpuBudget)
+	}
+	if config.CpuBudget == 0 {
+		cpuPlan = defaultCpuPlan
+		return nil
+	}
+	cpuPlan = PlanCpuBudget(config.CpuBudget, config.ParallelRates)
+	runtime.GOMAXPROCS(config.CpuBudget)
+	slog.Info("CPU budget", "budget", config.CpuBudget, "walks", cpuPlan.Walks, "threads", cpuPlan.VmafThreads)
+	return nil
+}
+
+// EncoderThreadArgs returns the encoder thread option of the plan.
+func EncoderThreadArgs() []string {
+	if cpuPlan.EncoderThreads == 0 {
+		return nil
+	}
+	return []string{"-threads", fmt.Sprint(cpuPlan.EncoderThreads)}
+}
@@ -0,0 +1,40 @@
+package main
+
+import "testing"
+
+func TestPlanCpuBudgetStaysWithinBudget(t *testing.T) {
+	for budget := 1; budget <= 256; budget++ {
+		for _, parallelRates := range []int{0, 1, 2, 3, 4, 8} {
+			plan := PlanCpuBudget(budget, parallelRates)
+			processesPerWalk := 2 * IntMax(1, parallelRates)
+			if plan.Walks < 1 || plan.EncoderThreads < 1 || plan.EncoderThreads > maxThreadsPerProcess || plan.VmafThreads != plan.EncoderThreads {
+				t.Fatalf("budget %d, %d rates: invalid plan %+v", budget, parallelRates, plan)
+			}
+			used := plan.Walks * processesPerWalk * plan.EncoderThreads
+			// A budget below the processes of one walk runs them single threaded.
+			if used > budget && !(plan.Walks == 1 && plan.EncoderThreads == 1) {
+				t.Errorf("budget %d, %d rates: plan %+v uses %d cores", budget, parallelRates, plan, used)
+			}
+			// Cores are only left idle when another walk would not fit.
+			if budget >= processesPerWalk && budget-used >= processesPerWalk*plan.EncoderThreads {
+				t.Errorf("budget %d, %d rates: plan %+v leaves %d cores idle", budget, parallelRates, plan, budget-used)
+			}
+		}
+	}
+}
+
+func TestPlanCpuBudgetExamples(t *testing.T) {
+	for _, test := range []struct {
+		budget, parallelRates int
+		expected              CpuPlan
+	}{
+		{16, 1, CpuPlan{Walks: 1, EncoderThreads: 8, VmafThreads: 8}},
+		{64, 1, CpuPlan{Walks: 4, EncoderThreads: 8, VmafThreads: 8}},
+		{8, 2, CpuPlan{Walks: 1, EncoderThreads: 2, VmafThreads: 2}},
+		{1, 1, CpuPlan{Walks: 1, EncoderThreads: 1, VmafThreads: 1}},
+	} {
+		if plan := PlanCpuBudget(test.budget, test.parallelRates); plan != test.expected {
+			t.Errorf("budget %d, %d rates: got %+v, expected %+v", test.budget, test.parallelRates, plan, test.expected)
+		}
+	}
+}
@@ -0,0 +1,106 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// -clip analyses a clip of the asset instead of the whole asset. The clip is extracted once, to a
+// lossless intermediate, and that intermediate is the reference for every encode and every VMAF
+// pass, so the reference and the distorted inputs always start at the same frame whatever seek is
+// used. The seek only decides where the clip starts:
+//
+//   - accurate seeks after decoding, so the clip starts exactly at the requested time. Every frame
+//     before it is decoded and dropped, which is slow for clips deep into long assets.
+//   - keyframe seeks in the demuxer without accurate seeking, so the clip starts at the keyframe at
+//     or before the requested time. It is fast, but the clip can start up to a GOP early.
+//
+// Seeking the source separately for the encode and for scoring would let the two seeks land on
+// different frames, and a one frame shift is enough to collapse VMAF.
+
+// Clip seek modes.
+const (
+	AccurateClipSeek = "accurate"
+	KeyframeClipSeek = "keyframe"
+)
+
+// ActiveClip returns the configured clip, or nil to analyse the whole asset.
+func ActiveClip() (*ScoringWindow, error) {
+	if config.Clip == "" {
+		return nil, nil
+	}
+	return ParseScoringWindow(config.Clip, "seconds")
+}
+
+// ValidateClip checks -clip and -clip-seek.
+func ValidateClip() error {
+	if _, err := ActiveClip(); err != nil {
+		return err
+	}
+	if config.ClipSeek != AccurateClipSeek && config.ClipSeek != KeyframeClipSeek {
+		return fmt.Errorf("unknown -clip-seek %q, expected %s or %s", config.ClipSeek, AccurateClipSeek, KeyframeClipSeek)
+	}
+	return nil
+}
+
+// BuildClipCommand returns the ffmpeg command extracting the clip of videoFilename to clipFilename.
+func BuildClipCommand(videoFilename string, clipFilename string, clip *ScoringWindow) *exec.Cmd {
+	start := fmt.Sprintf("%g", clip.Start)
+	duration := fmt.Sprintf("%g", clip.End-clip.Start)
+	var args []string
+	if config.ClipSeek == KeyframeClipSeek {
+		args = append(args, "-noaccurate_seek", "-ss", start)
+		args = append(args, SourceInputArgs(videoFilename)...)
+	} else {
+		args = append(args, SourceInputArgs(videoFilename)...)
+		args = append(args, "-ss", start)
+	}
+	args = append(args, "-t", duration, "-map", VideoStreamSpecifier(0, videoFilename), "-c:v", "ffv1", clipFilename)
+	return FfmpegCommand(args...)
+}
+
+// ExtractClip extracts the configured clip of videoFilename into a new temporary directory and
+// returns the clip and a function removing it.
+func ExtractClip(videoFilename string) (string, func(), error) {
+	clip, err := ActiveClip()
+	if err != nil {
+		return "", nil, err
+	}
+	if clip == nil {
+		return "", nil, errors.New("no clip configured")
+	}
+	directory, err := ScratchDir("vmaf-clip")
+	if err != nil {
+		return "", nil, err
+	}
+	cleanup := func() { os.RemoveAll(directory) }
+
+	base := strings.TrimSuffix(filepath.Base(videoFilename), filepath.Ext(videoFilename))
+	clipFilename := filepath.Join(directory, base+".mkv")
+	if err := extractWindow(videoFilename, clipFilename, clip); err != nil {
+		cleanup()
+		return "", nil, err
+	}
+	return clipFilename, cleanup, nil
+}
+
+// extractWindow extracts the window of videoFilename to the lossless clipFilename.
+func extractWindow(videoFilename string, clipFilename string, window *ScoringWindow) error {
+	cmd := BuildClipCommand(videoFilename, clipFilename, window)
+	release := readLimiter.Acquire(videoFilename)
+	slog.Debug("Executing command", "cmd", cmd.String())
+	start := time.Now()
+	_, err := RunMeasured(cmd, ErrEncodeFailed)
+	release()
+	if err != nil {
+		return err
+	}
+	videoLog(videoFilename).Info("Extracted clip", "start", window.Start, "end", window.End, "took", time.Since(start))
+	return nil
+}
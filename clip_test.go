@@ -0,0 +1,67 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+// argIndex returns the position of the first arg equal to value, or -1.
+func argIndex(args []string, value string) int {
+	for i, arg := range args {
+		if arg == value {
+			return i
+		}
+	}
+	return -1
+}
+
+func TestBuildClipCommandSeek(t *testing.T) {
+	previous := config
+	t.Cleanup(func() { config = previous })
+	config = DefaultConfig()
+	clip := &ScoringWindow{Unit: "seconds", Start: 61.5, End: 71.5}
+
+	for _, test := range []struct {
+		seek        string
+		beforeInput bool
+	}{
+		{AccurateClipSeek, false},
+		{KeyframeClipSeek, true},
+	} {
+		config.ClipSeek = test.seek
+		args := BuildClipCommand("master.mov", "clip.mkv", clip).Args
+		seek, input := argIndex(args, "-ss"), argIndex(args, "-i")
+		if seek < 0 || input < 0 || args[seek+1] != "61.5" || args[argIndex(args, "-t")+1] != "10" {
+			t.Fatalf("%s: %v does not extract 10s from 61.5s", test.seek, args)
+		}
+		if (seek < input) != test.beforeInput {
+			t.Errorf("%s: -ss at %d and -i at %d in %v", test.seek, seek, input, args)
+		}
+		if accurate := argIndex(args, "-noaccurate_seek") < 0; accurate != (test.seek == AccurateClipSeek) {
+			t.Errorf("%s: %v seeks accurately: %v", test.seek, args, accurate)
+		}
+	}
+}
+
+// The clip is extracted once, so the encodes and both scoring inputs start at its first frame: no
+// command reading the clip seeks on its own.
+func TestClipIsReadWithoutSeeking(t *testing.T) {
+	previous := config
+	t.Cleanup(func() { config = previous })
+	config = DefaultConfig()
+	config.Clip = "60-70"
+	reference, rung := "clip.mkv", Resolution{720, 1280}
+	encoded := EncodedFilename(reference, rung, 2000)
+
+	commands := map[string][]string{
+		"encode": BuildEncodeCommand(reference, encoded, rung, 2000).Args,
+		"vmaf":   BuildVmafCommand(reference, Resolution{1080, 1920}, encoded, rung, StandardVmafModel, "").Args,
+	}
+	for name, args := range commands {
+		for _, arg := range args {
+			if arg == "-ss" || arg == "-t" || strings.Contains(arg, "trim=") {
+				t.Errorf("%s command %v seeks the clip", name, args)
+			}
+		}
+	}
+}
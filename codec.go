@@ -0,0 +1,172 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+)
+
+// CodecProfile holds the encoder settings applied to every encode of a walk.
+type CodecProfile struct {
+	Encoder string
+	// PresetFlag is the option Preset is passed with, since not every encoder calls it -preset.
+	PresetFlag string
+	Preset     string
+	Tune       string
+	// ExtraArgs are further encoder options passed verbatim.
+	ExtraArgs []string
+	// Container is the extension of the intermediate encodes.
+	Container string
+}
+
+// codecProfiles are the built-in defaults for each supported encoder, so switching codecs does not
+// silently carry over settings that only suit another one. libx264 is left untuned, as it always
+// was, since no -tune flag could clear a default tune and film would change every existing hull.
+var codecProfiles = map[string]CodecProfile{
+	"libx264": {
+		Encoder:    "libx264",
+		PresetFlag: "-preset",
+		Preset:     "medium",
+		Container:  "mp4",
+	},
+	"libx265": {
+		Encoder:    "libx265",
+		PresetFlag: "-preset",
+		Preset:     "medium",
+		ExtraArgs:  []string{"-x265-params", "log-level=error"},
+		Container:  "mp4",
+	},
+	"libvpx-vp9": {
+		Encoder:    "libvpx-vp9",
+		PresetFlag: "-cpu-used",
+		Preset:     "2",
+		ExtraArgs:  []string{"-deadline", "good", "-row-mt", "1"},
+		Container:  "webm",
+	},
+	"libaom-av1": {
+		Encoder:    "libaom-av1",
+		PresetFlag: "-cpu-used",
+		Preset:     "6",
+		ExtraArgs:  []string{"-row-mt", "1"},
+		Container:  "mp4",
+	},
+	"h264_nvenc": {
+		Encoder:    "h264_nvenc",
+		PresetFlag: "-preset",
+		Preset:     "p5",
+		Tune:       "hq",
+		Container:  "mp4",
+	},
+	"hevc_nvenc": {
+		Encoder:    "hevc_nvenc",
+		PresetFlag: "-preset",
+		Preset:     "p5",
+		Tune:       "hq",
+		Container:  "mp4",
+	},
+	"av1_nvenc": {
+		Encoder:    "av1_nvenc",
+		PresetFlag: "-preset",
+		Preset:     "p5",
+		Tune:       "hq",
+		Container:  "mp4",
+	},
+	"h264_qsv": {
+		Encoder:    "h264_qsv",
+		PresetFlag: "-preset",
+		Preset:     "medium",
+		Container:  "mp4",
+	},
+	"hevc_qsv": {
+		Encoder:    "hevc_qsv",
+		PresetFlag: "-preset",
+		Preset:     "medium",
+		Container:  "mp4",
+	},
+	"libsvtav1": {
+		Encoder:    "libsvtav1",
+		PresetFlag: "-preset",
+		Preset:     "8",
+		Container:  "mp4",
+	},
+}
+
+// ActiveCodecProfile returns the built-in profile of the configured codec with the fields the user
+// set on the command line overriding it.
+func ActiveCodecProfile() CodecProfile {
+	profile := codecProfiles[config.Codec]
+	if config.Preset != "" {
+		profile.Preset = config.Preset
+	}
+	if config.Tune != "" {
+		profile.Tune = config.Tune
+	}
+	if config.EncoderArgs != "" {
+		profile.ExtraArgs = strings.Fields(config.EncoderArgs)
+	}
+	if config.Container != "" {
+		profile.Container = config.Container
+	}
+	return profile
+}
+
+// EncodeArgs returns the ffmpeg output options selecting and configuring the encoder.
+func (profile CodecProfile) EncodeArgs() []string {
+	args := []string{"-c:v", profile.Encoder}
+	if profile.Preset != "" {
+		args = append(args, profile.PresetFlag, profile.Preset)
+	}
+	if profile.Tune != "" {
+		args = append(args, "-tune", profile.Tune)
+	}
+	return append(args, profile.ExtraArgs...)
+}
+
+// ValidateCodec reports an error for codecs without a built-in profile.
+func ValidateCodec() error {
+	if _, ok := codecProfiles[config.Codec]; !ok {
+		return fmt.Errorf("unknown codec %q, expected one of %s", config.Codec, strings.Join(codecNames(), ", "))
+	}
+	return nil
+}
+
+func codecNames() []string {
+	var names []string
+	for name := range codecProfiles {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func printCodecProfile(name string, profile CodecProfile) {
+	fmt.Printf("%s:\n", name)
+	fmt.Printf("    encoder:   %s\n", profile.Encoder)
+	fmt.Printf("    preset:    %s %s\n", profile.PresetFlag, profile.Preset)
+	fmt.Printf("    tune:      %s\n", profile.Tune)
+	fmt.Printf("    args:      %s\n", strings.Join(profile.ExtraArgs, " "))
+	fmt.Printf("    container: %s\n", profile.Container)
+}
+
+// RunProfilesCommand implements the profiles subcommand, which lists the built-in codec profiles
+// and the active profile after the codec flags are applied.
+func RunProfilesCommand(args []string) int {
+	flags := flag.NewFlagSet("profiles", flag.ExitOnError)
+	config.RegisterFlags(flags)
+	ParseFlags(flags, args)
+
+	if err := ValidateCodec(); err != nil {
+		fmt.Fprintf(os.Stderr, "Invalid -codec. Error code: %s\n", err.Error())
+		return 2
+	}
+
+	fmt.Printf("Built-in profiles:\n")
+	for _, name := range codecNames() {
+		printCodecProfile(name, codecProfiles[name])
+	}
+	fmt.Printf("\nActive profile:\n")
+	printCodecProfile(config.Codec, ActiveCodecProfile())
+	return 0
+}
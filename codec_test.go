@@ -0,0 +1,27 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestActiveCodecProfileEncodeArgs(t *testing.T) {
+	previous := config
+	t.Cleanup(func() { config = previous })
+
+	for _, test := range []struct {
+		codec, preset, tune string
+		want                []string
+	}{
+		{"libx264", "", "", []string{"-c:v", "libx264", "-preset", "medium"}},
+		{"libx264", "slow", "film", []string{"-c:v", "libx264", "-preset", "slow", "-tune", "film"}},
+		{"libvpx-vp9", "", "", []string{"-c:v", "libvpx-vp9", "-cpu-used", "2", "-deadline", "good", "-row-mt", "1"}},
+		{"h264_nvenc", "", "", []string{"-c:v", "h264_nvenc", "-preset", "p5", "-tune", "hq"}},
+	} {
+		config = DefaultConfig()
+		config.Codec, config.Preset, config.Tune = test.codec, test.preset, test.tune
+		if got := ActiveCodecProfile().EncodeArgs(); !reflect.DeepEqual(got, test.want) {
+			t.Errorf("%s -preset %q -tune %q: got %v, want %v", test.codec, test.preset, test.tune, got, test.want)
+		}
+	}
+}
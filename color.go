@@ -0,0 +1,78 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Color handling must be identical for the encode and for both VMAF inputs. When ffmpeg is left to
+// pick conversion matrices itself, a mistagged or BT.2020 master can be converted one way during
+// the encode and another way during scoring, which silently shifts every VMAF score. Setting
+// -colorspace makes the matrix explicit throughout.
+
+// scaleColorMatrix maps an ffmpeg -colorspace name to the matrix name the scale filter accepts.
+func scaleColorMatrix(colorSpace string) string {
+	switch colorSpace {
+	case "bt2020nc", "bt2020c":
+		return "bt2020"
+	case "bt470bg":
+		return "bt470"
+	default:
+		return colorSpace
+	}
+}
+
+// ColorScaleOptions returns the scale filter options pinning the conversion matrix, or an empty
+// string when no color space is configured.
+func ColorScaleOptions() string {
+	if config.ColorSpace == "" {
+		return ""
+	}
+	matrix := scaleColorMatrix(config.ColorSpace)
+	return fmt.Sprintf("in_color_matrix=%s:out_color_matrix=%s", matrix, matrix)
+}
+
+// ColorEncodeArgs returns the ffmpeg output options tagging the encode with the configured color properties.
+func ColorEncodeArgs() []string {
+	var args []string
+	if config.ColorSpace != "" {
+		args = append(args, "-colorspace", config.ColorSpace)
+	}
+	if config.ColorPrimaries != "" {
+		args = append(args, "-color_primaries", config.ColorPrimaries)
+	}
+	if config.ColorTrc != "" {
+		args = append(args, "-color_trc", config.ColorTrc)
+	}
+	return args
+}
+
+func knownColorTag(tag string) bool {
+	return tag != "" && tag != "unknown" && tag != "reserved"
+}
+
+// CheckColorTags returns warnings for a source whose color tags disagree with the configuration or
+// with each other.
+func CheckColorTags(stream ProbeStream) []string {
+	var warnings []string
+	compare := func(name string, configured string, tagged string) {
+		if configured != "" && knownColorTag(tagged) && configured != tagged {
+			warnings = append(warnings, fmt.Sprintf("%s is tagged %s but %s is configured", name, tagged, configured))
+		}
+	}
+	compare("color space", config.ColorSpace, stream.ColorSpace)
+	compare("color primaries", config.ColorPrimaries, stream.ColorPrimaries)
+	compare("color transfer", config.ColorTrc, stream.ColorTransfer)
+
+	if knownColorTag(stream.ColorSpace) && knownColorTag(stream.ColorPrimaries) {
+		spaceIsBt2020 := strings.HasPrefix(stream.ColorSpace, "bt2020")
+		primariesAreBt2020 := strings.HasPrefix(stream.ColorPrimaries, "bt2020")
+		if spaceIsBt2020 != primariesAreBt2020 {
+			warnings = append(warnings, fmt.Sprintf("color space %s and primaries %s are inconsistent, the source may be mistagged", stream.ColorSpace, stream.ColorPrimaries))
+		}
+	}
+	if !knownColorTag(stream.ColorSpace) && config.ColorSpace == "" {
+		warnings = append(warnings, "color space is untagged and no -colorspace is configured, ffmpeg will guess the matrix")
+	}
+	return warnings
+}
@@ -0,0 +1,81 @@
+package main
+
+import (
+	"encoding/csv"
+	"fmt"
+	"os"
+	"strconv"
+	"sync"
+)
+
+// combinedCsvHeader are the columns of the combined CSV.
+var combinedCsvHeader = []string{"asset", "resolution", "rate", "vmaf"}
+
+// CombinedCsv is one CSV collecting the hull points of every asset of the batch, for ladder
+// planning without a merge step. Walks append to it concurrently, each asset's rows together. A
+// nil CombinedCsv records nothing.
+type CombinedCsv struct {
+	mu     sync.Mutex
+	file   *os.File
+	writer *csv.Writer
+}
+
+var combinedCsv *CombinedCsv
+
+// OpenCombinedCsv opens the combined CSV at path, writing the header when the file is new or empty.
+// A resumed batch keeps appending to the existing file, a new batch starts it over.
+func OpenCombinedCsv(path string, resume bool) (*CombinedCsv, error) {
+	if err := CreateOutputDirectory(path); err != nil {
+		return nil, err
+	}
+	flags := os.O_APPEND | os.O_CREATE | os.O_WRONLY
+	if !resume {
+		flags |= os.O_TRUNC
+	}
+	file, err := os.OpenFile(path, flags, 0644)
+	if err != nil {
+		return nil, err
+	}
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return nil, err
+	}
+	combined := &CombinedCsv{file: file, writer: csv.NewWriter(file)}
+	if info.Size() == 0 {
+		combined.writer.Write(combinedCsvHeader)
+		combined.writer.Flush()
+		if err := combined.writer.Error(); err != nil {
+			file.Close()
+			return nil, err
+		}
+	}
+	return combined, nil
+}
+
+// Append writes the valid points of an asset's hull.
+func (c *CombinedCsv) Append(videoFilename string, convexHull []ConvexHullPoint) error {
+	if c == nil {
+		return nil
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for _, point := range convexHull {
+		if point.VmafScore < 0 {
+			continue
+		}
+		c.writer.Write([]string{videoFilename, point.Resolution.ToFilterString(), strconv.Itoa(point.Rate), fmt.Sprintf("%f", point.VmafScore)})
+	}
+	c.writer.Flush()
+	return c.writer.Error()
+}
+
+// Close closes the combined CSV, waiting for a walk appending to it.
+func (c *CombinedCsv) Close() error {
+	if c == nil {
+		return nil
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.file.Close()
+}
@@ -0,0 +1,87 @@
+package main
+
+import (
+	"bufio"
+	"os"
+	"sync"
+)
+
+// CompletedManifest lists, one per line, every video whose hull a batch has written. A batch rerun
+// against a grown input list diffs the input list against it and schedules only the new videos,
+// instead of checking the outputs of thousands of finished videos one by one. It is only ever
+// appended to, so it stays cheap however large the catalog; the per-video check for an existing
+// hull still skips anything it misses. A nil CompletedManifest records nothing.
+type CompletedManifest struct {
+	mu   sync.Mutex
+	file *os.File
+	done map[string]bool
+}
+
+var completedManifest *CompletedManifest
+
+// OpenCompletedManifest reads the completed manifest at path, creating it when missing, and opens
+// it for appending.
+func OpenCompletedManifest(path string) (*CompletedManifest, error) {
+	if err := CreateOutputDirectory(path); err != nil {
+		return nil, err
+	}
+	file, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_RDWR, 0644)
+	if err != nil {
+		return nil, err
+	}
+	done := make(map[string]bool)
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		if line := scanner.Text(); line != "" {
+			done[line] = true
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		file.Close()
+		return nil, err
+	}
+	return &CompletedManifest{file: file, done: done}, nil
+}
+
+// Delta returns the videos not listed as completed, in input order.
+func (c *CompletedManifest) Delta(videoFilenames []string) []string {
+	if c == nil {
+		return videoFilenames
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	var delta []string
+	for _, videoFilename := range videoFilenames {
+		if !c.done[videoFilename] {
+			delta = append(delta, videoFilename)
+		}
+	}
+	return delta
+}
+
+// Add lists a video as completed.
+func (c *CompletedManifest) Add(videoFilename string) error {
+	if c == nil {
+		return nil
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.done[videoFilename] {
+		return nil
+	}
+	if _, err := c.file.WriteString(videoFilename + "\n"); err != nil {
+		return err
+	}
+	c.done[videoFilename] = true
+	return nil
+}
+
+// Close closes the completed manifest, waiting for a video being added.
+func (c *CompletedManifest) Close() error {
+	if c == nil {
+		return nil
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.file.Close()
+}
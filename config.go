@@ -0,0 +1,514 @@
+package main
+
+import (
+	"flag"
+	"time"
+)
+
+// Config holds the settings shared by every convex hull walk in a run.
+type Config struct {
+	// ConfigFile is a YAML file setting the flags not given on the command line. See configfile.go.
+	ConfigFile string
+
+	// Speed is a speed profile setting the flags given neither on the command line nor in ConfigFile.
+	// See speed.go.
+	Speed string
+
+	// AutoPhoneModel selects the libvmaf phone model for rungs below PhoneModelMaxHeight.
+	AutoPhoneModel      bool
+	PhoneModelMaxHeight int
+
+	// AutoUhdModel scores with the libvmaf 4K model whenever the scoring resolution is UHD.
+	AutoUhdModel bool
+
+	// MaxHeight skips sources whose short side is above it, or none when zero.
+	MaxHeight int
+
+	// MinHeight, MinSourceRate in kbps, MinDuration and MaxDuration in seconds and ExcludeCodec,
+	// comma separated ffprobe codec names, skip sources, each unless zero or empty. See filter.go.
+	MinHeight     int
+	MinSourceRate int
+	MinDuration   float64
+	MaxDuration   float64
+	ExcludeCodec  string
+
+	// VmafModel is the libvmaf model version, or .json model file, every rung is scored with, "neg"
+	// for NegVmafModel. Empty leaves the choice to -auto-phone-model and libvmaf.
+	VmafModel string
+
+	// VmafNegGap also scores every point with the NEG model in the same pass.
+	VmafNegGap bool
+
+	// VmafFps resamples both inputs to this frame rate before scoring. See fps.go.
+	VmafFps string
+
+	// Vsync and Async are the production video sync method and audio resampling rate every encode
+	// applies, so its frame timing matches what ships. See avsync.go.
+	Vsync string
+	Async int
+
+	// NormalizeVmaf also expresses every score relative to the source scored against itself. See normalize.go.
+	NormalizeVmaf bool
+
+	// VmafPlanes is LumaPlanes or ChromaPlanes, which adds chroma-aware metrics. See planes.go.
+	VmafPlanes string
+
+	// VmafStdDevPenalty is subtracted from a point's VMAF for each unit of frame standard deviation
+	// when ranking points, so steadier encodes win close calls. See stddev.go.
+	VmafStdDevPenalty float64
+
+	// VmafSubsample scores every VmafSubsample-th frame, or every frame when one.
+	VmafSubsample int
+
+	// VmafCi scores with the bootstrap model to report a 95% confidence interval per point.
+	VmafCi bool
+
+	// VmafSampling is AllFramesSampling or SceneSampling. Scene sampling scores SceneWindow frames from
+	// the start of each scene change detected above SceneThreshold.
+	VmafSampling   string
+	SceneThreshold float64
+	SceneWindow    int
+
+	// VmafFrames and VmafTime restrict scoring to a "start-end" window in frames or seconds.
+	VmafFrames string
+	VmafTime   string
+
+	// Clip analyses only the "start-end" seconds of each asset, extracted once with ClipSeek. See clip.go.
+	Clip     string
+	ClipSeek string
+
+	// Clips samples each asset with that many clips of ClipLength seconds, placed by ClipPlacement,
+	// and aggregates their scores. See multiclip.go.
+	Clips         int
+	ClipLength    float64
+	ClipPlacement string
+
+	// FrameOffset skips leading frames of the reference, or of the encode when negative, to realign them.
+	FrameOffset int
+
+	// ScaleDistorted and ScaleReference are the scale algorithms bringing each input to VmafResolution,
+	// the reference resolution when empty, or NoScale to leave the input untouched. See scaling.go.
+	ScaleDistorted string
+	ScaleReference string
+	VmafResolution string
+
+	// VideoStream is the position among the video streams of each source of the stream to read, or
+	// AutoVideoStream for the primary one. See stream.go.
+	VideoStream int
+
+	// DisplayResolutions is a CSV mapping rungs to the display resolution each is scored at instead
+	// of VmafResolution. See display.go.
+	DisplayResolutions string
+
+	// Prescale scales each encode to the reference resolution once, losslessly, before scoring.
+	Prescale bool
+
+	// Audit records a hash of every ffmpeg command and the libvmaf version in the results.
+	Audit bool
+
+	// MaxReadsPerSource and MaxConcurrentReads cap concurrent ffmpeg reads of source files. Zero is unlimited.
+	MaxReadsPerSource  int
+	MaxConcurrentReads int
+
+	// Ladder is a ladder preset name or a comma separated list of WIDTHxHEIGHT resolutions.
+	Ladder string
+
+	// LadderFile is a YAML ladder replacing -ladder, see ReadLadderFile.
+	LadderFile string
+
+	// SourceBitrate is the source rate in kbps used when it cannot be read from the source itself.
+	SourceBitrate int
+
+	// MinRate and MaxRate bound the target rates in kbps, MinRate only the generated rates: the rate
+	// steps of -rates or a ladder are used as listed. MinRateFraction and MaxRateFraction further
+	// bound them relative to the source rate when non-zero.
+	MinRate         int
+	MaxRate         int
+	MinRateFraction float64
+	MaxRateFraction float64
+
+	// RateStep is the spacing in kbps of the linear target rates. Rates is a comma separated list
+	// of target rates replacing them and the rates of the ladder, see ApplyRates.
+	RateStep int
+	Rates    string
+
+	// RateSpacing is LinearRateSpacing or LogRateSpacing, which generates RatePoints rates instead.
+	RateSpacing string
+	RatePoints  int
+
+	// ParallelRates is how many rates of one walk are measured at once, see WalkConvexHullParallel.
+	ParallelRates int
+
+	// AssetTimeout is the wall-clock budget of one asset, after which its hull is written as it
+	// stands. Zero is unlimited. See deadline.go.
+	AssetTimeout time.Duration
+
+	// Exhaustive measures every ladder resolution at every target rate and computes the hull over
+	// them, so rates are independent and -parallel-rates loses nothing. See ExhaustiveGrid.
+	Exhaustive bool
+
+	// SanityCheck keeps points whose VMAF is implausible for their rate out of the hull. See
+	// ImplausiblePoints for the thresholds.
+	SanityCheck     bool
+	SanityTolerance float64
+	SanityMinBpp    float64
+	SanityMaxVmaf   float64
+
+	// MonotonicResolution corrects the final hull so resolution never drops as the rate rises.
+	MonotonicResolution bool
+
+	// MaxResolutionStep is how many ladder rungs the resolution may move between consecutive rates
+	// of the final hull, unlimited when zero. See LimitResolutionStep.
+	MaxResolutionStep int
+
+	// MaxRes and MinRes clip the ladder to the rungs between them, inclusive, each WIDTHxHEIGHT or a
+	// name such as 720p. See ApplyResolutionRange.
+	MaxRes string
+	MinRes string
+
+	// Hwaccel is the ffmpeg hardware accelerator inputs are decoded with, e.g. cuda. See hwaccel.go.
+	Hwaccel string
+	// Gpu is the index of the GPU NVENC encodes run on, or -1 to let the driver choose.
+	Gpu int
+	// QsvDevice is the render node, such as /dev/dri/renderD129, Quick Sync encodes run on. Empty
+	// lets the driver choose.
+	QsvDevice string
+
+	// CpuBudget is the number of cores divided between walks and ffmpeg threads, see budget.go. Zero
+	// keeps batches of 100 videos and 8 libvmaf threads.
+	CpuBudget int
+
+	// MinVmafGain is the VMAF improvement a lower resolution needs before the walk switches to it.
+	MinVmafGain float64
+
+	// Codec selects a built-in codec profile; Preset, Tune, EncoderArgs and Container override its
+	// fields when set.
+	Codec       string
+	Preset      string
+	Tune        string
+	EncoderArgs string
+	Container   string
+
+	// Profile and Level constrain the encodes to what a device can decode. Empty leaves the encoder default.
+	Profile string
+	Level   string
+	// Tiles splits every frame of AV1 and VP9 encodes into COLUMNSxROWS tiles, so decoders and the
+	// encoder can work on them in parallel. Empty leaves the encoder default.
+	Tiles string
+	// Crf encodes at this constant rate factor capped at each target rate, instead of at an average
+	// rate, with encoders that support it. 0 encodes at the average rate.
+	Crf int
+
+	// ColorSpace, ColorPrimaries and ColorTrc make color handling explicit and identical for the
+	// encode and for scoring, see color.go. Empty leaves the choice to ffmpeg.
+	ColorSpace     string
+	ColorPrimaries string
+	ColorTrc       string
+
+	// GridFile is a CSV of resolution,rate operating points to measure instead of walking the ladder.
+	GridFile string
+
+	// Extend adds the target rates missing from an existing hull instead of skipping the video.
+	Extend bool
+
+	// HideFailures leaves attempts that are not ok out of the results.
+	HideFailures bool
+
+	// Hdr walks HDR sources keeping their PQ or HLG signal, see hdr.go, scoring them with HdrVmafModel
+	// when set. Without it HDR sources are skipped.
+	Hdr          bool
+	HdrVmafModel string
+
+	// Deinterlace is the deinterlacer, yadif or bwdif, applied to interlaced sources. See deinterlace.go.
+	Deinterlace string
+
+	// QuickBounds measures only the top and bottom operating points of each asset, flagging assets
+	// whose top point scores below QuickBoundsMinVmaf. See quickbounds.go.
+	QuickBounds        bool
+	QuickBoundsMinVmaf float64
+
+	// TargetVmaf searches each asset for the cheapest point reaching this VMAF, to within
+	// TargetVmafPrecision kbps, instead of walking the hull. See target.go.
+	TargetVmaf          float64
+	TargetVmafPrecision int
+
+	// DryRun prints the encode and VMAF commands of each video instead of running them. See dryrun.go.
+	DryRun bool
+
+	// EncodeOnly benchmarks the encodes of every ladder resolution at every rate and skips VMAF.
+	EncodeOnly bool
+
+	// Strict exits non-zero when any processed video produced no valid hull points.
+	Strict bool
+
+	// OnError is what a failed video does to the rest of the batch, ContinueOnError or
+	// AbortOnError, and FailureReport the JSON file the failures are written to at the end.
+	OnError       string
+	FailureReport string
+
+	// VegaLite writes a Vega-Lite plot spec next to every hull.
+	VegaLite bool
+
+	// Heatmap writes every measured point next to every hull as a resolution by rate matrix, in
+	// JsonHeatmap or CsvHeatmap format. See heatmap.go.
+	Heatmap string
+
+	// ManifestPath is the batch manifest recording the status of every asset. Resume continues the
+	// batch it describes instead of starting a new one.
+	ManifestPath string
+	Resume       bool
+
+	// InputHash records a PartialInputHash or FullInputHash digest of every source in its hull, and
+	// recomputes hulls whose source changed. See inputhash.go.
+	InputHash string
+
+	// CompletedManifest lists every video whose hull was written, so reruns only schedule new ones.
+	// See completed.go.
+	CompletedManifest string
+
+	// CombinedCsv is a CSV collecting the hull points of every asset, next to the per-asset outputs.
+	CombinedCsv string
+
+	// WebhookUrl receives a POST as each asset finishes, with WebhookTimeout per request and up to
+	// WebhookRetries retries. See webhook.go.
+	WebhookUrl     string
+	WebhookTimeout time.Duration
+	WebhookRetries int
+
+	// KeepTemp keeps the run temporary directory instead of removing it at exit. See tempdir.go.
+	KeepTemp bool
+
+	// Quiet logs only warnings and errors, Verbose debug records too, in LogFormat. See log.go.
+	Quiet     bool
+	Verbose   bool
+	LogFormat string
+
+	// KeepIntermediates keeps every intermediate encode and libvmaf log, organized per source
+	// directory under the encode directory, instead of removing each once it is scored. See
+	// tempdir.go.
+	KeepIntermediates bool
+
+	// FfmpegLogLevel is the -loglevel of every ffmpeg invocation.
+	FfmpegLogLevel string
+
+	// FfmpegPath and FfprobePath are the binaries run, looked up in PATH unless they are paths.
+	FfmpegPath  string
+	FfprobePath string
+
+	// InputList is the file listing the videos of the batch, one path per line relative to
+	// VideoDirectory.
+	InputList      string
+	VideoDirectory string
+
+	// Scan discovers the videos under VideoDirectory instead of reading InputList: the files with
+	// one of the comma separated Extensions that match one of the Include globs, when given. See
+	// discover.go.
+	Scan       bool
+	Extensions string
+	Include    string
+
+	// Overrides is a JSON or CSV file of per-video settings, see ReadVideoOverrides.
+	Overrides string
+
+	// SkipExisting leaves the videos whose result already exists out of the batch.
+	SkipExisting bool
+
+	// BatchSize is how many videos are walked at once, or zero for the number -cpu-budget plans.
+	BatchSize int
+
+	// EncodeDirectory is where the intermediate encodes of each video are written, mirroring the
+	// layout of VideoDirectory, or empty to write them in the run temporary directory.
+	EncodeDirectory string
+
+	// EncodeTemplate is the file name of each intermediate encode, see ValidateEncodeTemplate.
+	EncodeTemplate string
+
+	// WorkDir is the directory the run temporary directory is created in, the system temporary
+	// directory when empty. See tempdir.go.
+	WorkDir string
+
+	// OutputTemplate is the path each hull is written to, see outputTemplatePlaceholders.
+	OutputTemplate string
+
+	// LadderScript is the path template of the production encode commands of each hull, and
+	// LadderOutput the template of their outputs. See ladderscript.go.
+	LadderScript string
+	LadderOutput string
+}
+
+// DefaultConfig returns the configuration used when no flags are given.
+func DefaultConfig() Config {
+	return Config{
+		AutoPhoneModel:      false,
+		PhoneModelMaxHeight: 540,
+		QuickBoundsMinVmaf:  90,
+		TargetVmafPrecision: 50,
+		VideoStream:         AutoVideoStream,
+		Codec:               "libx264",
+		Ladder:              "default",
+		MinRate:             500,
+		MaxRate:             10000,
+		RateStep:            500,
+		RateSpacing:         LinearRateSpacing,
+		RatePoints:          20,
+		InputList:           "filenames.txt",
+		VideoDirectory:      "videos",
+		Extensions:          "mp4,mov,m4v,mkv,webm,mxf,ts,y4m",
+		OutputTemplate:      "{dir}/{base}.json",
+		LogFormat:           TextLogFormat,
+		OnError:             ContinueOnError,
+		LadderOutput:        "{dir}/{base}_{width}x{height}_{rate}kbps.{container}",
+		EncodeTemplate:      "{base}_{height}x{width}_{rate}kbps.{container}",
+		FfmpegLogLevel:      "error",
+		Gpu:                 -1,
+		FfmpegPath:          "ffmpeg",
+		FfprobePath:         "ffprobe",
+		VmafSampling:        AllFramesSampling,
+		VmafPlanes:          LumaPlanes,
+		SceneThreshold:      0.3,
+		SceneWindow:         12,
+		ClipSeek:            AccurateClipSeek,
+		ClipLength:          10,
+		VmafSubsample:       1,
+		ClipPlacement:       EvenClipPlacement,
+		ScaleDistorted:      "bicubic",
+		ScaleReference:      NoScale,
+		WebhookTimeout:      10 * time.Second,
+		WebhookRetries:      3,
+		SanityTolerance:     10,
+		SanityMaxVmaf:       95,
+	}
+}
+
+var config = DefaultConfig()
+
+// RegisterFlags binds the configuration fields to command line flags.
+func (c *Config) RegisterFlags(flags *flag.FlagSet) {
+	flags.StringVar(&c.ConfigFile, "config", c.ConfigFile, "YAML file setting flags not given on the command line, keyed by flag name")
+	flags.StringVar(&c.Speed, "speed", c.Speed, "speed profile bundling preset, VMAF subsampling, clips and search: fast, balanced or accurate")
+	flags.BoolVar(&c.AutoPhoneModel, "auto-phone-model", c.AutoPhoneModel, "score rungs below -phone-model-max-height with the libvmaf phone model")
+	flags.BoolVar(&c.AutoUhdModel, "auto-4k-model", c.AutoUhdModel, "score with the libvmaf 4K model whenever encodes are compared at 2160p or above")
+	flags.IntVar(&c.MaxHeight, "max-height", c.MaxHeight, "skip sources whose height, the short side, is above this; 0 walks every source")
+	flags.IntVar(&c.MinHeight, "min-height", c.MinHeight, "skip sources whose height, the short side, is below this")
+	flags.IntVar(&c.MinSourceRate, "min-source-rate", c.MinSourceRate, "skip sources below this rate in kbps")
+	flags.Float64Var(&c.MinDuration, "min-duration", c.MinDuration, "skip sources shorter than this many seconds, such as stills")
+	flags.Float64Var(&c.MaxDuration, "max-duration", c.MaxDuration, "skip sources longer than this many seconds; 0 walks sources of any length")
+	flags.StringVar(&c.ExcludeCodec, "exclude-codec", c.ExcludeCodec, "comma separated codecs, as ffprobe names them, whose sources are skipped, such as mpeg2video,mjpeg")
+	flags.IntVar(&c.PhoneModelMaxHeight, "phone-model-max-height", c.PhoneModelMaxHeight, "rungs with a height below this are scored with the phone model when -auto-phone-model is set")
+	flags.StringVar(&c.VmafModel, "vmaf-model", c.VmafModel, "libvmaf model version or .json model file for every rung, or neg for "+NegVmafModel)
+	flags.BoolVar(&c.VmafNegGap, "vmaf-neg-gap", c.VmafNegGap, "also score with "+NegVmafModel+" in the same pass; a large gap to VMAF indicates sharpening gaming the metric")
+	flags.StringVar(&c.VmafFps, "vmaf-fps", c.VmafFps, "frame rate, e.g. 30000/1001 or 25, both inputs are resampled to before scoring so long assets stay aligned")
+	flags.StringVar(&c.Vsync, "vsync", c.Vsync, "video sync method of every encode: auto, passthrough, cfr, vfr or drop, to match the frame timing of production encodes")
+	flags.IntVar(&c.Async, "async", c.Async, "audio samples per second resampled to follow timestamps in every encode, as production encodes do; audio is never scored")
+	flags.BoolVar(&c.NormalizeVmaf, "normalize-vmaf", c.NormalizeVmaf, "also record each score as a percentage of the source scored against itself, for comparisons across sources")
+	flags.StringVar(&c.VmafPlanes, "vmaf-planes", c.VmafPlanes, "planes scored: luma for standard VMAF, or chroma to also report per-plane PSNR and CIEDE2000")
+	flags.IntVar(&c.VmafSubsample, "vmaf-subsample", c.VmafSubsample, "score every n-th frame with libvmaf n_subsample; 1 scores every frame")
+	flags.Float64Var(&c.VmafStdDevPenalty, "vmaf-stddev-penalty", c.VmafStdDevPenalty, "VMAF subtracted per unit of frame standard deviation when choosing between points, to prefer steady quality; 0 ranks by mean alone")
+	flags.BoolVar(&c.VmafCi, "vmaf-ci", c.VmafCi, "score with the libvmaf bootstrap model and report 95% confidence intervals")
+	flags.StringVar(&c.VmafSampling, "vmaf-sampling", c.VmafSampling, "frames to score: all, or scene to score only the frames around scene changes")
+	flags.Float64Var(&c.SceneThreshold, "scene-threshold", c.SceneThreshold, "ffmpeg scene score above which a frame starts a new scene for -vmaf-sampling scene")
+	flags.IntVar(&c.SceneWindow, "scene-window", c.SceneWindow, "frames scored from the start of each scene for -vmaf-sampling scene")
+	flags.StringVar(&c.VmafFrames, "vmaf-frames", c.VmafFrames, "score only frames start-end, e.g. 1000-1500")
+	flags.StringVar(&c.VmafTime, "vmaf-time", c.VmafTime, "score only seconds start-end, e.g. 60-90")
+	flags.StringVar(&c.Clip, "clip", c.Clip, "analyse only seconds start-end of each video, extracted once as the reference for encoding and scoring")
+	flags.StringVar(&c.ClipSeek, "clip-seek", c.ClipSeek, "how -clip seeks: accurate starts exactly at start but decodes everything before it, keyframe is fast but starts at the preceding keyframe")
+	flags.IntVar(&c.Clips, "clips", c.Clips, "score each operating point on this many short clips of each video instead of all of it, 0 to disable")
+	flags.Float64Var(&c.ClipLength, "clip-length", c.ClipLength, "length of each -clips clip in seconds")
+	flags.StringVar(&c.ClipPlacement, "clip-placement", c.ClipPlacement, "where -clips clips start: even, or scene to start them at scene changes")
+	flags.IntVar(&c.FrameOffset, "frame-offset", c.FrameOffset, "leading reference frames to skip when scoring, negative to skip encode frames instead")
+	flags.StringVar(&c.ScaleDistorted, "scale-distorted", c.ScaleDistorted, "scale algorithm bringing each encode to the scoring resolution, e.g. bilinear to match a player, or none; changes VMAF")
+	flags.StringVar(&c.ScaleReference, "scale-reference", c.ScaleReference, "scale algorithm bringing the reference to the scoring resolution, or none to leave it untouched")
+	flags.StringVar(&c.VmafResolution, "vmaf-resolution", c.VmafResolution, "WIDTHxHEIGHT both inputs are scored at, the reference resolution when empty")
+	flags.IntVar(&c.VideoStream, "video-stream", c.VideoStream, "video stream N of each source to read, as in -map 0:v:N; -1 picks the largest stream that is not an attached picture")
+	flags.StringVar(&c.DisplayResolutions, "display-resolutions", c.DisplayResolutions, "CSV of rung,display WIDTHxHEIGHT rows; each rung is scored upscaled to the resolution it is watched at")
+	flags.BoolVar(&c.Prescale, "prescale", c.Prescale, "scale each encode to the reference resolution once before scoring instead of in every VMAF pass")
+	flags.BoolVar(&c.Audit, "audit", c.Audit, "record ffmpeg command hashes per point and the libvmaf version of the run")
+	flags.IntVar(&c.MaxReadsPerSource, "max-reads-per-source", c.MaxReadsPerSource, "maximum ffmpeg processes reading the same source file at once, 0 for unlimited")
+	flags.IntVar(&c.MaxConcurrentReads, "max-concurrent-reads", c.MaxConcurrentReads, "maximum ffmpeg processes reading source files at once, 0 for unlimited")
+	flags.StringVar(&c.Ladder, "ladder", c.Ladder, "candidate resolutions: a preset, see the ladders subcommand, or a list like 1920x1080,1280x720")
+	flags.StringVar(&c.LadderFile, "ladder-file", c.LadderFile, "YAML file of candidate resolutions and optional rate steps, replacing -ladder")
+	flags.IntVar(&c.SourceBitrate, "source-bitrate", c.SourceBitrate, "source rate in kbps for sources whose bitrate cannot be read from their metadata")
+	flags.IntVar(&c.MinRate, "min-rate", c.MinRate, "lowest generated target rate in kbps; the rate steps of -rates or a ladder are used as listed")
+	flags.IntVar(&c.RateStep, "rate-step", c.RateStep, "spacing of the linear target rates in kbps")
+	flags.StringVar(&c.RateSpacing, "rate-spacing", c.RateSpacing, "spacing of the generated target rates: linear steps of -rate-step, or log for -rate-points rates evenly spaced on a log scale")
+	flags.IntVar(&c.RatePoints, "rate-points", c.RatePoints, "number of target rates -rate-spacing log generates between the rate bounds")
+	flags.StringVar(&c.Rates, "rates", c.Rates, "comma separated target rates in kbps replacing the linear steps and those of the ladder, e.g. 100,200,400,800")
+	flags.IntVar(&c.MaxRate, "max-rate", c.MaxRate, "highest target rate in kbps, never above the source rate")
+	flags.Float64Var(&c.MinRateFraction, "min-rate-fraction", c.MinRateFraction, "lowest target rate as a fraction of the source rate, e.g. 0.05")
+	flags.Float64Var(&c.MaxRateFraction, "max-rate-fraction", c.MaxRateFraction, "highest target rate as a fraction of the source rate, e.g. 0.8")
+	flags.BoolVar(&c.Exhaustive, "exhaustive", c.Exhaustive, "encode every ladder resolution at every target rate and compute the hull over them instead of walking; rates run in parallel with -parallel-rates")
+	flags.DurationVar(&c.AssetTimeout, "asset-timeout", c.AssetTimeout, "wall-clock budget of one video, e.g. 2h, after which the points measured so far are written and the video is marked partial; 0 is unlimited")
+	flags.IntVar(&c.ParallelRates, "parallel-rates", c.ParallelRates, "rates of one walk measured at once; the hull is that of the sequential walk")
+	flags.StringVar(&c.MaxRes, "max-res", c.MaxRes, "highest ladder resolution walked, e.g. 720p or 1280x720; sources above it are walked from it")
+	flags.StringVar(&c.MinRes, "min-res", c.MinRes, "lowest ladder resolution walked, e.g. 240p or 426x240")
+	flags.IntVar(&c.MaxResolutionStep, "max-resolution-step", c.MaxResolutionStep, "most ladder rungs the resolution may move between consecutive rates of the hull, measuring intermediate resolutions as needed; 0 is unlimited")
+	flags.BoolVar(&c.SanityCheck, "sanity-check", c.SanityCheck, "flag points whose VMAF is implausible for their rate, as silently corrupted encodes score, and keep them out of the hull")
+	flags.Float64Var(&c.SanityTolerance, "sanity-tolerance", c.SanityTolerance, "VMAF a point may stray from what the neighbouring rates at its resolution predict before -sanity-check flags it")
+	flags.Float64Var(&c.SanityMinBpp, "sanity-min-bpp", c.SanityMinBpp, "bits per pixel below which -sanity-check flags scores of at least -sanity-max-vmaf; 0 disables the check")
+	flags.Float64Var(&c.SanityMaxVmaf, "sanity-max-vmaf", c.SanityMaxVmaf, "VMAF -sanity-check finds implausible below -sanity-min-bpp")
+	flags.BoolVar(&c.MonotonicResolution, "monotonic-resolution", c.MonotonicResolution, "correct the final hull so no rate uses a lower resolution than a lower rate, flagging corrected points")
+	flags.StringVar(&c.QsvDevice, "qsv-device", c.QsvDevice, "device Quick Sync encodes run on, e.g. /dev/dri/renderD129; empty lets the driver choose")
+	flags.IntVar(&c.Gpu, "gpu", c.Gpu, "index of the GPU NVENC encodes run on; -1 lets the driver choose")
+	flags.StringVar(&c.Hwaccel, "hwaccel", c.Hwaccel, "decode every input with this ffmpeg hardware accelerator, e.g. cuda, falling back to software decoding")
+	flags.IntVar(&c.CpuBudget, "cpu-budget", c.CpuBudget, "cores to use, divided between videos walked at once and threads per ffmpeg process; 0 for the old fixed sizing")
+	flags.Float64Var(&c.MinVmafGain, "min-vmaf-gain", c.MinVmafGain, "minimum VMAF improvement required before the walk switches to a lower resolution")
+	flags.StringVar(&c.Codec, "codec", c.Codec, "codec profile to encode with, see the profiles subcommand")
+	flags.StringVar(&c.Preset, "preset", c.Preset, "encoder preset, overriding the codec profile")
+	flags.StringVar(&c.Tune, "tune", c.Tune, "encoder tune, overriding the codec profile")
+	flags.StringVar(&c.EncoderArgs, "encoder-args", c.EncoderArgs, "space separated extra encoder options, replacing those of the codec profile")
+	flags.StringVar(&c.Container, "container", c.Container, "extension of intermediate encodes, overriding the codec profile")
+	flags.StringVar(&c.Profile, "profile", c.Profile, "encoder profile passed as -profile:v, e.g. baseline, main or high")
+	flags.StringVar(&c.Level, "level", c.Level, "encoder level passed as -level:v, e.g. 3.1 or 4.1")
+	flags.IntVar(&c.Crf, "crf", c.Crf, "encode at this CRF capped at each target rate instead of at an average rate, for libsvtav1 and NVENC; 0 for the average rate")
+	flags.StringVar(&c.Tiles, "tiles", c.Tiles, "COLUMNSxROWS tiles of AV1 and VP9 encodes, powers of two such as 2x2")
+	flags.StringVar(&c.ColorSpace, "colorspace", c.ColorSpace, "color matrix used for the encode and both VMAF inputs, e.g. bt709 or bt2020nc; inconsistent color handling silently corrupts VMAF")
+	flags.StringVar(&c.ColorPrimaries, "color-primaries", c.ColorPrimaries, "color primaries the encodes are tagged with, e.g. bt709 or bt2020")
+	flags.StringVar(&c.ColorTrc, "color-trc", c.ColorTrc, "transfer characteristics the encodes are tagged with, e.g. bt709 or smpte2084")
+	flags.StringVar(&c.GridFile, "grid", c.GridFile, "CSV of WIDTHxHEIGHT,kbps operating points to encode and score exactly, computing the hull over them instead of walking the ladder")
+	flags.BoolVar(&c.Extend, "extend", c.Extend, "compute only the target rates missing from an existing hull, merge them in and rewrite it; refuses hulls computed with other settings")
+	flags.BoolVar(&c.HideFailures, "hide-failures", c.HideFailures, "leave failed, timed out, infeasible and skipped operating points out of the attempts in each result")
+	flags.StringVar(&c.Deinterlace, "deinterlace", c.Deinterlace, "deinterlace sources detected as interlaced with yadif or bwdif in every encode and reference scoring input")
+	flags.BoolVar(&c.Hdr, "hdr", c.Hdr, "walk HDR sources as 10-bit PQ or HLG throughout instead of skipping them; nothing is tone-mapped")
+	flags.StringVar(&c.HdrVmafModel, "hdr-vmaf-model", c.HdrVmafModel, "libvmaf model version, or .json model file, used for HDR sources instead of the SDR model")
+	flags.BoolVar(&c.QuickBounds, "quick-bounds", c.QuickBounds, "measure only the top and bottom operating points of each asset and write them to a .bounds.json next to the hull")
+	flags.Float64Var(&c.TargetVmaf, "target-vmaf", c.TargetVmaf, "find the cheapest resolution and rate of each asset reaching this VMAF and write it to a .target.json next to the hull, instead of walking the hull")
+	flags.IntVar(&c.TargetVmafPrecision, "target-vmaf-precision", c.TargetVmafPrecision, "kbps to which -target-vmaf binary searches the rate at each resolution")
+	flags.Float64Var(&c.QuickBoundsMinVmaf, "quick-bounds-min-vmaf", c.QuickBoundsMinVmaf, "VMAF below which the top point of -quick-bounds flags the asset for the full walk")
+	flags.BoolVar(&c.EncodeOnly, "encode-only", c.EncodeOnly, "benchmark encode time and achieved rate over the resolution and rate grid without computing VMAF")
+	flags.BoolVar(&c.Strict, "strict", c.Strict, "exit non-zero if any processed video produced no valid hull points")
+	flags.StringVar(&c.OnError, "on-error", c.OnError, "continue the batch after a video fails, or abort it, exiting non-zero, once the videos in progress finish")
+	flags.StringVar(&c.FailureReport, "failure-report", c.FailureReport, "JSON file listing every failed video and why, written at the end of the batch")
+	flags.StringVar(&c.Heatmap, "heatmap", c.Heatmap, "also write every measured point as a resolution by rate VMAF matrix next to each result, as json or csv")
+	flags.BoolVar(&c.VegaLite, "vega-lite", c.VegaLite, "write a Vega-Lite plot spec of the hull next to each result")
+	flags.StringVar(&c.ManifestPath, "manifest", c.ManifestPath, "batch manifest file recording the status of every video")
+	flags.StringVar(&c.WebhookUrl, "webhook", c.WebhookUrl, "URL POSTed a JSON notification with the status, and hull, of every video as it finishes")
+	flags.DurationVar(&c.WebhookTimeout, "webhook-timeout", c.WebhookTimeout, "timeout of each -webhook request")
+	flags.IntVar(&c.WebhookRetries, "webhook-retries", c.WebhookRetries, "retries of a -webhook notification that failed on a network error or a 5xx or 429 response")
+	flags.StringVar(&c.CombinedCsv, "combined-csv", c.CombinedCsv, "also append the hull points of every asset to this CSV of asset,resolution,rate,vmaf rows")
+	flags.StringVar(&c.InputHash, "input-hash", c.InputHash, "record a digest of every video and recompute hulls whose video changed: partial hashes the size and both ends, full the whole file")
+	flags.StringVar(&c.CompletedManifest, "completed-manifest", c.CompletedManifest, "file listing every video whose hull was written; reruns skip the listed videos without checking their outputs")
+	flags.BoolVar(&c.Resume, "resume", c.Resume, "resume the batch recorded in -manifest, skipping finished videos")
+	flags.BoolVar(&c.KeepIntermediates, "keep-intermediates", c.KeepIntermediates, "keep the intermediate encodes and libvmaf logs of every video under -encode-dir, or the run temporary directory, for debugging")
+	flags.BoolVar(&c.Quiet, "quiet", c.Quiet, "log only warnings and errors")
+	flags.BoolVar(&c.Verbose, "verbose", c.Verbose, "log debug records too, such as every ffmpeg command")
+	flags.StringVar(&c.LogFormat, "log-format", c.LogFormat, "format of the log on standard error, text or json")
+	flags.BoolVar(&c.KeepTemp, "keep-temp", c.KeepTemp, "keep the temporary directory of the run, logged at startup, for debugging")
+	flags.StringVar(&c.FfmpegPath, "ffmpeg", c.FfmpegPath, "ffmpeg binary to run, a name looked up in PATH or a path")
+	flags.StringVar(&c.FfprobePath, "ffprobe", c.FfprobePath, "ffprobe binary to run, a name looked up in PATH or a path")
+	flags.StringVar(&c.FfmpegLogLevel, "ffmpeg-loglevel", c.FfmpegLogLevel, "-loglevel passed to every ffmpeg invocation, e.g. error, warning or info")
+	flags.StringVar(&c.InputList, "input-list", c.InputList, "file listing the videos to process, one path per line relative to -video-dir")
+	flags.StringVar(&c.VideoDirectory, "video-dir", c.VideoDirectory, "directory the paths of -input-list are relative to")
+	flags.BoolVar(&c.DryRun, "dry-run", c.DryRun, "probe every video and print the encodes and VMAF passes its walk could run, without running them")
+	flags.BoolVar(&c.Scan, "scan", c.Scan, "discover the videos under -video-dir recursively instead of reading -input-list")
+	flags.StringVar(&c.Extensions, "extensions", c.Extensions, "comma separated file extensions -scan discovers")
+	flags.StringVar(&c.Include, "include", c.Include, "comma separated globs narrowing -scan, a file name like *_master.mov or a path like shows/**/*.mxf")
+	flags.StringVar(&c.Overrides, "overrides", c.Overrides, "JSON or CSV file mapping videos to their own ladder, min and max rate, codec and max resolution")
+	flags.BoolVar(&c.SkipExisting, "skip-existing", c.SkipExisting, "leave videos whose hull output already exists out of the batch")
+	flags.IntVar(&c.BatchSize, "batch-size", c.BatchSize, "videos walked at once; 0 uses the number -cpu-budget plans")
+	flags.StringVar(&c.EncodeDirectory, "encode-dir", c.EncodeDirectory, "directory the intermediate encodes are written to, mirroring -video-dir; empty writes them to the run temporary directory")
+	flags.StringVar(&c.EncodeTemplate, "encode-template", c.EncodeTemplate, "file name of each intermediate encode, with the -output-template placeholders except {dir} and {rel}, and {width}, {height}, {rate} and {container}")
+	flags.StringVar(&c.WorkDir, "workdir", c.WorkDir, "directory each run creates its temporary directory in; empty uses the system temporary directory")
+	flags.StringVar(&c.OutputTemplate, "output-template", c.OutputTemplate, "path of each hull JSON, with placeholders {base}, {codec}, {date}, {dir}, {ext}, {preset} and {rel}")
+	flags.StringVar(&c.LadderScript, "ladder-script", c.LadderScript, "path template of a shell script, or .json of commands, with the ffmpeg encode of every hull point")
+	flags.StringVar(&c.LadderOutput, "ladder-output", c.LadderOutput, "output template of the -ladder-script encodes, adding {width}, {height}, {rate} and {container}")
+}
@@ -0,0 +1,149 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// A configuration file sets flags from YAML, so a pipeline's ladder, encoder, VMAF and concurrency
+// settings can be committed rather than typed. Keys are flag names; a mapping prefixes the keys
+// inside it with its own, so
+//
+//	codec: libx264
+//	vmaf:
+//	  model: vmaf_v0.6.1
+//	  fps: 30
+//	ladder: default
+//
+// sets -codec, -vmaf-model, -vmaf-fps and -ladder. A list sets a comma separated flag.
+//
+// Every flag can also be set from the environment, for containers and orchestrators that configure
+// through it: VMAF_ followed by the flag name in upper case with dashes as underscores, so
+// VMAF_WORKDIR sets -workdir, VMAF_CPU_BUDGET -cpu-budget and VMAF_CONFIG the file itself. Flags
+// given on the command line win over the environment, the environment over the file and the file
+// over the defaults; the values are then validated like the flags they set.
+
+// envPrefix starts the name of the environment variable of every flag.
+const envPrefix = "VMAF_"
+
+// EnvName returns the environment variable that sets the flag name.
+func EnvName(name string) string {
+	return envPrefix + strings.ToUpper(strings.ReplaceAll(name, "-", "_"))
+}
+
+// ApplyEnvironment sets the flags that were not given on the command line from their environment
+// variables.
+func ApplyEnvironment(flags *flag.FlagSet) error {
+	explicit := make(map[string]bool)
+	flags.Visit(func(f *flag.Flag) { explicit[f.Name] = true })
+	var err error
+	flags.VisitAll(func(f *flag.Flag) {
+		value, ok := os.LookupEnv(EnvName(f.Name))
+		if !ok || explicit[f.Name] || err != nil {
+			return
+		}
+		if setErr := flags.Set(f.Name, value); setErr != nil {
+			err = fmt.Errorf("%s: %w", EnvName(f.Name), setErr)
+		}
+	})
+	return err
+}
+
+// ParseFlags parses args into flags, applies the environment, the -config file and then the -speed
+// profile and sets up logging, exiting with status 2 when any is invalid.
+func ParseFlags(flags *flag.FlagSet, args []string) {
+	flags.Parse(args)
+	if err := ApplyEnvironment(flags); err != nil {
+		fmt.Fprintf(os.Stderr, "Invalid environment. Error code: %s\n", err.Error())
+		os.Exit(2)
+	}
+	if config.ConfigFile != "" {
+		if err := LoadConfigFile(flags, config.ConfigFile); err != nil {
+			fmt.Fprintf(os.Stderr, "Invalid -config %s. Error code: %s\n", config.ConfigFile, err.Error())
+			os.Exit(2)
+		}
+	}
+	if err := ApplySpeedProfile(flags); err != nil {
+		fmt.Fprintf(os.Stderr, "Invalid -speed %s. Error code: %s\n", config.Speed, err.Error())
+		os.Exit(2)
+	}
+	if err := SetupLogging(); err != nil {
+		fmt.Fprintf(os.Stderr, "Invalid logging flags. Error code: %s\n", err.Error())
+		os.Exit(2)
+	}
+}
+
+// LoadConfigFile sets the flags of the YAML file path that were not given on the command line or
+// from the environment.
+func LoadConfigFile(flags *flag.FlagSet, path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	var document map[string]interface{}
+	if err := yaml.Unmarshal(data, &document); err != nil {
+		return err
+	}
+	values := make(map[string]string)
+	if err := flattenConfig("", document, values); err != nil {
+		return err
+	}
+
+	explicit := make(map[string]bool)
+	flags.Visit(func(f *flag.Flag) { explicit[f.Name] = true })
+	names := make([]string, 0, len(values))
+	for name := range values {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		if flags.Lookup(name) == nil {
+			return fmt.Errorf("unknown setting %s", name)
+		}
+		if name == "config" {
+			return fmt.Errorf("a configuration file cannot include another")
+		}
+		if explicit[name] {
+			continue
+		}
+		if err := flags.Set(name, values[name]); err != nil {
+			return fmt.Errorf("%s: %w", name, err)
+		}
+	}
+	return nil
+}
+
+// flattenConfig adds the flag values of a YAML mapping to values, prefixing nested keys.
+func flattenConfig(prefix string, document map[string]interface{}, values map[string]string) error {
+	for key, value := range document {
+		name := key
+		if prefix != "" {
+			name = prefix + "-" + key
+		}
+		switch value := value.(type) {
+		case map[string]interface{}:
+			if err := flattenConfig(name, value, values); err != nil {
+				return err
+			}
+		case []interface{}:
+			items := make([]string, len(value))
+			for i, item := range value {
+				if _, ok := item.(map[string]interface{}); ok {
+					return fmt.Errorf("%s: lists hold values, not mappings", name)
+				}
+				items[i] = fmt.Sprint(item)
+			}
+			values[name] = strings.Join(items, ",")
+		case nil:
+			return fmt.Errorf("%s has no value", name)
+		default:
+			values[name] = fmt.Sprint(value)
+		}
+	}
+	return nil
+}
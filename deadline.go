@@ -0,0 +1,46 @@
+package main
+
+import (
+	"fmt"
+	"sync"
+	"time"
+)
+
+// -asset-timeout bounds the wall-clock time of one asset, so a few pathological sources whose
+// encodes are slow but never hang cannot hold a worker for hours. The budget is checked before
+// every operating point: once it runs out the walk stops, the points already measured are written
+// as the hull and the asset is recorded as partial. A point already running finishes first. The
+// attempts of the points never started are recorded as timed out, and -extend measures them later.
+
+// assetDeadlines holds the deadline of every reference while its asset is processed.
+var assetDeadlines = struct {
+	sync.Mutex
+	byFilename map[string]time.Time
+}{byFilename: make(map[string]time.Time)}
+
+// StartAssetBudget starts the -asset-timeout budget of referenceFilename, counted from started, and
+// returns the function ending it. Without -asset-timeout the budget is unlimited.
+func StartAssetBudget(referenceFilename string, started time.Time) func() {
+	if config.AssetTimeout <= 0 {
+		return func() {}
+	}
+	assetDeadlines.Lock()
+	assetDeadlines.byFilename[referenceFilename] = started.Add(config.AssetTimeout)
+	assetDeadlines.Unlock()
+	return func() {
+		assetDeadlines.Lock()
+		delete(assetDeadlines.byFilename, referenceFilename)
+		assetDeadlines.Unlock()
+	}
+}
+
+// CheckAssetBudget returns an error matching ErrTimeout once the budget of referenceFilename has run out.
+func CheckAssetBudget(referenceFilename string) error {
+	assetDeadlines.Lock()
+	deadline, ok := assetDeadlines.byFilename[referenceFilename]
+	assetDeadlines.Unlock()
+	if ok && time.Now().After(deadline) {
+		return fmt.Errorf("%w: asset exceeded -asset-timeout %s", ErrTimeout, config.AssetTimeout)
+	}
+	return nil
+}
@@ -0,0 +1,114 @@
+package main
+
+import (
+	"fmt"
+	"log/slog"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// Encoding or scoring an interlaced master as if it were progressive scores the combing of its
+// fields rather than the encode. Sources are detected as interlaced from the field order ffprobe
+// reports or, with -deinterlace and when the container does not say, from ffmpeg's idet filter over
+// the first idetFrames frames. The detected sources are then deinterlaced with yadif or bwdif,
+// one frame per frame so the resolution and frame rate of the walk are those of the source, in
+// every encode and in the reference input of every scoring pass; the encodes themselves are
+// progressive and scored as they are. The field parity is taken from detection rather than from
+// frame flags, which lossless clips do not always keep. Without -deinterlace no idet pass is run
+// and a source the container reports as interlaced is still walked, with a warning. The
+// deinterlacer is recorded in the encode settings and the parity in the result.
+
+// Deinterlacers accepted by -deinterlace.
+var deinterlacers = []string{"yadif", "bwdif"}
+
+// Field parities of interlaced sources.
+const (
+	TopFieldFirst    = "tff"
+	BottomFieldFirst = "bff"
+)
+
+// idetFrames is the number of frames idet inspects when the field order is unknown.
+const idetFrames = 300
+
+var idetSummary = regexp.MustCompile(`Multi frame detection:\s*TFF:\s*(\d+)\s*BFF:\s*(\d+)\s*Progressive:\s*(\d+)`)
+
+// ValidateDeinterlace checks -deinterlace.
+func ValidateDeinterlace() error {
+	if config.Deinterlace == "" {
+		return nil
+	}
+	for _, deinterlacer := range deinterlacers {
+		if config.Deinterlace == deinterlacer {
+			return nil
+		}
+	}
+	return fmt.Errorf("unknown -deinterlace %q, expected one of %s", config.Deinterlace, strings.Join(deinterlacers, ", "))
+}
+
+// DetectFieldParity returns TopFieldFirst or BottomFieldFirst for an interlaced stream of filename,
+// or an empty string for a progressive one, or one of unknown field order without -deinterlace.
+func DetectFieldParity(filename string, stream ProbeStream) (string, error) {
+	switch stream.FieldOrder {
+	case "progressive":
+		return "", nil
+	case "tt", "tb":
+		return TopFieldFirst, nil
+	case "bb", "bt":
+		return BottomFieldFirst, nil
+	}
+	if config.Deinterlace == "" {
+		return "", nil
+	}
+
+	args := append(SourceInputArgs(filename), "-map", VideoStreamSpecifier(0, filename), "-vf", "idet", "-frames:v", strconv.Itoa(idetFrames), "-an", "-f", "null", "-")
+	// idet logs its summary at info level, whatever -ffmpeg-loglevel is.
+	cmd := ffmpegCommandWithLogLevel("info", args...)
+	slog.Debug("Executing command", "cmd", cmd.String())
+	output, err := cmd.CombinedOutput()
+	if err != nil {
+		return "", ClassifyFfmpegError(err, string(output), ErrProbeFailed)
+	}
+	match := idetSummary.FindStringSubmatch(string(output))
+	if match == nil {
+		return "", fmt.Errorf("%w: no idet summary", ErrProbeFailed)
+	}
+	tff, _ := strconv.Atoi(match[1])
+	bff, _ := strconv.Atoi(match[2])
+	progressive, _ := strconv.Atoi(match[3])
+	switch {
+	case tff+bff <= progressive:
+		return "", nil
+	case tff >= bff:
+		return TopFieldFirst, nil
+	default:
+		return BottomFieldFirst, nil
+	}
+}
+
+// interlacedReferences maps each interlaced reference of the run deinterlaced with -deinterlace to
+// its field parity.
+var interlacedReferences = struct {
+	sync.Mutex
+	byFilename map[string]string
+}{byFilename: make(map[string]string)}
+
+// RegisterInterlacedReference records that referenceFilename is interlaced with the given parity.
+func RegisterInterlacedReference(referenceFilename string, parity string) {
+	interlacedReferences.Lock()
+	defer interlacedReferences.Unlock()
+	interlacedReferences.byFilename[referenceFilename] = parity
+}
+
+// DeinterlaceFilter returns the filter deinterlacing referenceFilename, or an empty string when it
+// is progressive or not deinterlaced.
+func DeinterlaceFilter(referenceFilename string) string {
+	interlacedReferences.Lock()
+	parity, ok := interlacedReferences.byFilename[referenceFilename]
+	interlacedReferences.Unlock()
+	if !ok || config.Deinterlace == "" {
+		return ""
+	}
+	return fmt.Sprintf("%s=mode=send_frame:parity=%s:deint=all", config.Deinterlace, parity)
+}
@@ -0,0 +1,43 @@
+package main
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+// fakeIdet writes an ffmpeg that prints an idet summary of the given frame counts and points
+// config.FfmpegPath at it.
+func fakeIdet(t *testing.T, summary string) {
+	t.Helper()
+	config.FfmpegPath = fakeBinary(t, "ffmpeg", "echo '[Parsed_idet_0 @ 0x5581] Multi frame detection: "+summary+"' >&2\n")
+}
+
+func TestDetectFieldParity(t *testing.T) {
+	previous := config
+	t.Cleanup(func() { config = previous })
+
+	for _, test := range []struct {
+		name, fieldOrder, deinterlace, idet string
+		want                                string
+	}{
+		{"progressive", "progressive", "yadif", "", ""},
+		{"top field first", "tt", "", "", TopFieldFirst},
+		{"bottom field first", "bt", "", "", BottomFieldFirst},
+		{"unknown without -deinterlace", "unknown", "", "", ""},
+		{"unknown", "", "bwdif", "TFF:   250 BFF:     0 Progressive:    10 Undetermined:    40", TopFieldFirst},
+		{"unknown bottom field first", "unknown", "yadif", "TFF:    12 BFF:   201 Progressive:    30 Undetermined:    57", BottomFieldFirst},
+		{"unknown progressive", "", "yadif", "TFF:     0 BFF:     3 Progressive:   290 Undetermined:     7", ""},
+	} {
+		config = DefaultConfig()
+		config.Deinterlace = test.deinterlace
+		// Without an idet summary to print, running ffmpeg at all fails the test.
+		config.FfmpegPath = filepath.Join(t.TempDir(), "missing")
+		if test.idet != "" {
+			fakeIdet(t, test.idet)
+		}
+		parity, err := DetectFieldParity("master.mxf", ProbeStream{FieldOrder: test.fieldOrder})
+		if parity != test.want || err != nil {
+			t.Errorf("%s: got %q, %v, want %q", test.name, parity, err, test.want)
+		}
+	}
+}
@@ -0,0 +1,258 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log/slog"
+	"math"
+	"os"
+	"sort"
+)
+
+// HullDiffRow compares two hulls at one bitrate.
+type HullDiffRow struct {
+	Rate               int
+	BaselineVmaf       float64
+	NewVmaf            float64
+	VmafDelta          float64
+	BaselineResolution Resolution
+	NewResolution      Resolution
+	ResolutionChanged  bool
+}
+
+// HullDiff is the comparison of a new hull against a baseline hull. BdRate is the average bitrate
+// change in percent at equal VMAF, negative when the new hull is cheaper, and nil when the hulls do
+// not overlap enough to compute it.
+type HullDiff struct {
+	Rows   []HullDiffRow
+	BdRate *float64
+}
+
+// sortedByRate returns a copy of the hull ordered by increasing rate.
+func sortedByRate(convexHull []ConvexHullPoint) []ConvexHullPoint {
+	sorted := append([]ConvexHullPoint(nil), convexHull...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].Rate < sorted[j].Rate })
+	return sorted
+}
+
+// InterpolateHull returns the VMAF at rate, linearly interpolated between the surrounding points,
+// and the resolution of the nearest point. It does not extrapolate beyond the measured rates.
+func InterpolateHull(convexHull []ConvexHullPoint, rate int) (float64, Resolution, bool) {
+	sorted := sortedByRate(convexHull)
+	if len(sorted) == 0 || rate < sorted[0].Rate || rate > sorted[len(sorted)-1].Rate {
+		return 0, Resolution{}, false
+	}
+
+	i := sort.Search(len(sorted), func(i int) bool { return sorted[i].Rate >= rate })
+	if sorted[i].Rate == rate {
+		return sorted[i].VmafScore, sorted[i].Resolution, true
+	}
+
+	low, high := sorted[i-1], sorted[i]
+	fraction := float64(rate-low.Rate) / float64(high.Rate-low.Rate)
+	vmaf := low.VmafScore + fraction*(high.VmafScore-low.VmafScore)
+	if fraction < 0.5 {
+		return vmaf, low.Resolution, true
+	}
+	return vmaf, high.Resolution, true
+}
+
+// DiffConvexHulls aligns the two hulls on the union of their rates, interpolating where one hull
+// was not measured at a rate, and computes the BD-rate between them.
+func DiffConvexHulls(baseline []ConvexHullPoint, candidate []ConvexHullPoint) HullDiff {
+	rateSet := make(map[int]bool)
+	for _, point := range baseline {
+		rateSet[point.Rate] = true
+	}
+	for _, point := range candidate {
+		rateSet[point.Rate] = true
+	}
+	var rates []int
+	for rate := range rateSet {
+		rates = append(rates, rate)
+	}
+	sort.Sort(sort.Reverse(sort.IntSlice(rates)))
+
+	var diff HullDiff
+	for _, rate := range rates {
+		baselineVmaf, baselineResolution, baselineOk := InterpolateHull(baseline, rate)
+		newVmaf, newResolution, newOk := InterpolateHull(candidate, rate)
+		if !baselineOk || !newOk {
+			continue
+		}
+		diff.Rows = append(diff.Rows, HullDiffRow{
+			Rate:               rate,
+			BaselineVmaf:       baselineVmaf,
+			NewVmaf:            newVmaf,
+			VmafDelta:          newVmaf - baselineVmaf,
+			BaselineResolution: baselineResolution,
+			NewResolution:      newResolution,
+			ResolutionChanged:  baselineResolution != newResolution,
+		})
+	}
+
+	if bdRate, ok := BdRate(baseline, candidate); ok {
+		diff.BdRate = &bdRate
+	}
+	return diff
+}
+
+// polyfit returns the least squares polynomial coefficients, lowest order first, of y over x.
+func polyfit(x []float64, y []float64, degree int) []float64 {
+	n := degree + 1
+	// Normal equations augmented with the right hand side.
+	matrix := make([][]float64, n)
+	for row := range matrix {
+		matrix[row] = make([]float64, n+1)
+		for col := 0; col < n; col++ {
+			for i := range x {
+				matrix[row][col] += math.Pow(x[i], float64(row+col))
+			}
+		}
+		for i := range x {
+			matrix[row][n] += y[i] * math.Pow(x[i], float64(row))
+		}
+	}
+
+	// Gaussian elimination with partial pivoting.
+	for col := 0; col < n; col++ {
+		pivot := col
+		for row := col + 1; row < n; row++ {
+			if math.Abs(matrix[row][col]) > math.Abs(matrix[pivot][col]) {
+				pivot = row
+			}
+		}
+		matrix[col], matrix[pivot] = matrix[pivot], matrix[col]
+		for row := col + 1; row < n; row++ {
+			factor := matrix[row][col] / matrix[col][col]
+			for k := col; k <= n; k++ {
+				matrix[row][k] -= factor * matrix[col][k]
+			}
+		}
+	}
+	coefficients := make([]float64, n)
+	for row := n - 1; row >= 0; row-- {
+		sum := matrix[row][n]
+		for k := row + 1; k < n; k++ {
+			sum -= matrix[row][k] * coefficients[k]
+		}
+		coefficients[row] = sum / matrix[row][row]
+	}
+	return coefficients
+}
+
+// polyIntegral returns the integral of the polynomial from low to high.
+func polyIntegral(coefficients []float64, low float64, high float64) float64 {
+	integral := 0.0
+	for power, coefficient := range coefficients {
+		p := float64(power + 1)
+		integral += coefficient / p * (math.Pow(high, p) - math.Pow(low, p))
+	}
+	return integral
+}
+
+// BdRate computes the Bjontegaard delta rate of candidate against baseline in percent. Each curve
+// is fitted as log rate over VMAF with a polynomial of up to third order, and the fits are compared
+// over the VMAF range both hulls cover.
+func BdRate(baseline []ConvexHullPoint, candidate []ConvexHullPoint) (float64, bool) {
+	fit := func(convexHull []ConvexHullPoint) ([]float64, float64, float64, bool) {
+		var vmaf, logRate []float64
+		for _, point := range convexHull {
+			if point.Rate <= 0 || point.VmafScore < 0 {
+				continue
+			}
+			vmaf = append(vmaf, point.VmafScore)
+			logRate = append(logRate, math.Log10(float64(point.Rate)))
+		}
+		if len(vmaf) < 2 {
+			return nil, 0, 0, false
+		}
+		low, high := vmaf[0], vmaf[0]
+		for _, v := range vmaf {
+			low, high = math.Min(low, v), math.Max(high, v)
+		}
+		if high == low {
+			return nil, 0, 0, false
+		}
+		return polyfit(vmaf, logRate, IntMin(3, len(vmaf)-1)), low, high, true
+	}
+
+	baselineFit, baselineLow, baselineHigh, baselineOk := fit(baseline)
+	candidateFit, candidateLow, candidateHigh, candidateOk := fit(candidate)
+	if !baselineOk || !candidateOk {
+		return 0, false
+	}
+
+	low := math.Max(baselineLow, candidateLow)
+	high := math.Min(baselineHigh, candidateHigh)
+	if high <= low {
+		return 0, false
+	}
+
+	averageDifference := (polyIntegral(candidateFit, low, high) - polyIntegral(baselineFit, low, high)) / (high - low)
+	return (math.Pow(10, averageDifference) - 1) * 100, true
+}
+
+// PrintHullDiff writes the diff as a human readable table.
+func PrintHullDiff(diff HullDiff) {
+	fmt.Printf("%8s  %10s  %10s  %8s  %11s  %11s\n", "kbps", "base vmaf", "new vmaf", "delta", "base res", "new res")
+	for _, row := range diff.Rows {
+		marker := ""
+		if row.ResolutionChanged {
+			marker = " *"
+		}
+		fmt.Printf("%8d  %10.3f  %10.3f  %+8.3f  %11s  %11s%s\n", row.Rate, row.BaselineVmaf, row.NewVmaf, row.VmafDelta, row.BaselineResolution.ToFilterString(), row.NewResolution.ToFilterString(), marker)
+	}
+	if diff.BdRate != nil {
+		fmt.Printf("BD-rate: %+.2f%%\n", *diff.BdRate)
+	} else {
+		fmt.Printf("BD-rate: not computable, the hulls do not overlap in VMAF\n")
+	}
+}
+
+// RunDiffCommand implements the diff subcommand: diff [-json out.json] <baseline.json> <new.json>.
+func RunDiffCommand(args []string) int {
+	flags := flag.NewFlagSet("diff", flag.ExitOnError)
+	jsonFilename := flags.String("json", "", "also write the diff as JSON to this file")
+	flags.Parse(args)
+
+	if flags.NArg() != 2 {
+		fmt.Fprintf(os.Stderr, "Usage: %s diff [-json out.json] <baseline.json> <new.json>\n", os.Args[0])
+		return 2
+	}
+
+	baseline, err := ReadConvexHullFromJson(flags.Arg(0))
+	if err != nil {
+		slog.Error("Error reading baseline hull", "file", flags.Arg(0), "err", err)
+		return 1
+	}
+	candidate, err := ReadConvexHullFromJson(flags.Arg(1))
+	if err != nil {
+		slog.Error("Error reading new hull", "file", flags.Arg(1), "err", err)
+		return 1
+	}
+
+	for _, mismatch := range VersionMismatches(baseline.Metadata, candidate.Metadata) {
+		slog.Warn("Hulls were produced with different versions, VMAF differences may not be caused by the encodes", "mismatch", mismatch)
+	}
+
+	diff := DiffConvexHulls(baseline.ConvexHull, candidate.ConvexHull)
+	PrintHullDiff(diff)
+
+	if *jsonFilename != "" {
+		jsonFile, err := os.Create(*jsonFilename)
+		if err != nil {
+			slog.Error("Error creating json file", "file", *jsonFilename, "err", err)
+			return 1
+		}
+		defer jsonFile.Close()
+		encoder := json.NewEncoder(jsonFile)
+		encoder.SetIndent("", "    ")
+		if err := encoder.Encode(diff); err != nil {
+			slog.Error("Error encoding json file", "file", *jsonFilename, "err", err)
+			return 1
+		}
+	}
+	return 0
+}
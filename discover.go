@@ -0,0 +1,218 @@
+package main
+
+import (
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// With -scan the videos of a batch are discovered under -video-dir instead of read from
+// -input-list: every file with one of the -extensions, optionally narrowed to the -include globs.
+// Hidden files and directories are left out, as are the encodes this tool writes, which a run in
+// progress or a -ladder-script may have left next to the sources.
+
+// encodeOutputName matches the names the default -encode-template and -ladder-output give encodes.
+var encodeOutputName = regexp.MustCompile(`_\d+x\d+_\d+kbps\.[^.]+$`)
+
+// encodeTemplateName returns a pattern matching the file names -encode-template gives encodes, so
+// that encodes named by a custom template are left out too.
+func encodeTemplateName(template string) *regexp.Regexp {
+	template = template[strings.LastIndex(template, "/")+1:]
+	var pattern strings.Builder
+	pattern.WriteString("^")
+	last := 0
+	for _, match := range outputTemplatePlaceholder.FindAllStringSubmatchIndex(template, -1) {
+		pattern.WriteString(regexp.QuoteMeta(template[last:match[0]]))
+		switch template[match[2]:match[3]] {
+		case "width", "height", "rate":
+			pattern.WriteString(`\d+`)
+		default:
+			pattern.WriteString(`.*`)
+		}
+		last = match[1]
+	}
+	pattern.WriteString(regexp.QuoteMeta(template[last:]))
+	pattern.WriteString("$")
+	return regexp.MustCompile(pattern.String())
+}
+
+// InputFilenames returns the videos of the batch: the paths given as arguments, where - reads
+// paths from standard input one per line, or else the videos discovered with -scan or read from
+// -input-list, which are relative to -video-dir.
+func InputFilenames(args []string) ([]string, error) {
+	if len(args) > 0 {
+		if config.Scan {
+			return nil, errors.New("videos are given as arguments and discovered with -scan")
+		}
+		var filenames []string
+		for _, arg := range args {
+			if arg != "-" {
+				filenames = append(filenames, arg)
+				continue
+			}
+			lines, err := readLines(arg)
+			if err != nil {
+				return nil, err
+			}
+			for _, line := range lines {
+				if line = strings.TrimSpace(line); line != "" {
+					filenames = append(filenames, line)
+				}
+			}
+		}
+		return filenames, nil
+	}
+
+	var filenames []string
+	var err error
+	if config.Scan {
+		filenames, err = DiscoverVideos(config.VideoDirectory)
+	} else {
+		filenames, err = readLines(config.InputList)
+	}
+	for i := range filenames {
+		filenames[i] = filepath.Join(config.VideoDirectory, filenames[i])
+	}
+	return filenames, err
+}
+
+// DiscoverVideos returns the videos under directory, relative to it and sorted.
+func DiscoverVideos(directory string) ([]string, error) {
+	extensions := make(map[string]bool)
+	for _, extension := range strings.Split(config.Extensions, ",") {
+		extensions["."+strings.ToLower(strings.TrimPrefix(strings.TrimSpace(extension), "."))] = true
+	}
+	var patterns []string
+	for _, pattern := range strings.Split(config.Include, ",") {
+		if pattern = strings.TrimSpace(pattern); pattern != "" {
+			patterns = append(patterns, pattern)
+		}
+	}
+
+	encodeName := encodeTemplateName(config.EncodeTemplate)
+
+	var videos []string
+	err := filepath.WalkDir(directory, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if path != directory && strings.HasPrefix(entry.Name(), ".") {
+			if entry.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if entry.IsDir() || !extensions[strings.ToLower(filepath.Ext(path))] || encodeOutputName.MatchString(entry.Name()) || encodeName.MatchString(entry.Name()) {
+			return nil
+		}
+		rel, err := filepath.Rel(directory, path)
+		if err != nil {
+			return err
+		}
+		if len(patterns) > 0 && !matchesAnyGlob(patterns, filepath.ToSlash(rel)) {
+			return nil
+		}
+		videos = append(videos, rel)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(videos)
+	return videos, nil
+}
+
+// matchesAnyGlob reports whether the slash separated rel matches one of the patterns. A pattern
+// without a slash matches the file name in any directory; otherwise it matches the whole path, with
+// ** standing for any number of directories.
+func matchesAnyGlob(patterns []string, rel string) bool {
+	for _, pattern := range patterns {
+		if !strings.Contains(pattern, "/") {
+			if matched, _ := filepath.Match(pattern, filepath.Base(rel)); matched {
+				return true
+			}
+			continue
+		}
+		if matchGlobSegments(strings.Split(pattern, "/"), strings.Split(rel, "/")) {
+			return true
+		}
+	}
+	return false
+}
+
+// matchGlobSegments matches path segments against pattern segments.
+func matchGlobSegments(pattern []string, segments []string) bool {
+	if len(pattern) == 0 {
+		return len(segments) == 0
+	}
+	if pattern[0] == "**" {
+		for skip := 0; skip <= len(segments); skip++ {
+			if matchGlobSegments(pattern[1:], segments[skip:]) {
+				return true
+			}
+		}
+		return false
+	}
+	if len(segments) == 0 {
+		return false
+	}
+	matched, _ := filepath.Match(pattern[0], segments[0])
+	return matched && matchGlobSegments(pattern[1:], segments[1:])
+}
+
+// ValidateInputDiscovery checks -include and -skip-existing.
+func ValidateInputDiscovery() error {
+	if config.Include != "" && !config.Scan {
+		return errors.New("-include filters the videos -scan discovers")
+	}
+	if config.Scan && strings.TrimSpace(config.Extensions) == "" {
+		return errors.New("-scan needs at least one extension")
+	}
+	for _, pattern := range strings.Split(config.Include, ",") {
+		for _, segment := range strings.Split(strings.TrimSpace(pattern), "/") {
+			if _, err := filepath.Match(segment, ""); err != nil {
+				return err
+			}
+		}
+	}
+	if config.SkipExisting && (config.Extend || config.InputHash != "") {
+		return errors.New("-skip-existing leaves out the hulls -extend and -input-hash revisit")
+	}
+	return nil
+}
+
+// WithoutExistingHullsOverridden is WithoutExistingHulls with the result of each video looked for
+// with its overrides applied, see EachOverrideGroup, keeping the order of videoFilenames.
+func WithoutExistingHullsOverridden(videoFilenames []string, overrides map[string]VideoOverrides) []string {
+	existing := make(map[string]bool)
+	EachOverrideGroup(GroupByOverrides(videoFilenames, overrides), func(group []string) {
+		for _, videoFilename := range group {
+			existing[videoFilename] = true
+		}
+		for _, videoFilename := range WithoutExistingHulls(group) {
+			existing[videoFilename] = false
+		}
+	})
+	var pending []string
+	for _, videoFilename := range videoFilenames {
+		if !existing[videoFilename] {
+			pending = append(pending, videoFilename)
+		}
+	}
+	return pending
+}
+
+// WithoutExistingHulls returns the videos whose result is not written yet.
+func WithoutExistingHulls(videoFilenames []string) []string {
+	var pending []string
+	for _, videoFilename := range videoFilenames {
+		if _, err := os.Stat(HullFilename(videoFilename)); os.IsNotExist(err) {
+			pending = append(pending, videoFilename)
+		}
+	}
+	return pending
+}
@@ -0,0 +1,87 @@
+package main
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+// By default every encode is scored upscaled to the reference resolution, as if every rung were
+// watched on a screen matching the source. -display-resolutions maps each rung to the resolution
+// it is actually watched at, such as a 360p rung played full screen on a 1080p TV, and the encode
+// and the reference are both scaled to that display resolution for scoring. The reference is then
+// scaled too, so -scale-reference must not be none for rungs whose display differs from the source.
+
+// displayResolutions maps rungs to the resolution they are scored at, when not empty.
+var displayResolutions map[Resolution]Resolution
+
+// ReadDisplayResolutions reads a CSV of rung,display rows of WIDTHxHEIGHT resolutions. A leading
+// rung,display header row and blank lines are skipped.
+func ReadDisplayResolutions(filename string) (map[Resolution]Resolution, error) {
+	file, err := os.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	reader := csv.NewReader(file)
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+	mapping := make(map[Resolution]Resolution)
+	for row := 1; ; row++ {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		if row == 1 && len(record) > 0 && strings.EqualFold(strings.TrimSpace(record[0]), "rung") {
+			continue
+		}
+		if len(record) != 2 {
+			return nil, fmt.Errorf("row %d has %d fields, expected rung,display", row, len(record))
+		}
+		rung, err := ParseResolution(record[0])
+		if err != nil {
+			return nil, fmt.Errorf("row %d: %w", row, err)
+		}
+		display, err := ParseResolution(record[1])
+		if err != nil {
+			return nil, fmt.Errorf("row %d: %w", row, err)
+		}
+		if _, ok := mapping[rung]; ok {
+			return nil, fmt.Errorf("row %d maps rung %s again", row, rung.ToFilterString())
+		}
+		mapping[rung] = display
+	}
+	if len(mapping) == 0 {
+		return nil, errors.New("mapping has no rungs")
+	}
+	return mapping, nil
+}
+
+// LoadDisplayResolutions loads -display-resolutions, which replaces the single -vmaf-resolution.
+func LoadDisplayResolutions() error {
+	if config.DisplayResolutions == "" {
+		return nil
+	}
+	if config.VmafResolution != "" {
+		return errors.New("it cannot be combined with -vmaf-resolution")
+	}
+	mapping, err := ReadDisplayResolutions(config.DisplayResolutions)
+	displayResolutions = mapping
+	return err
+}
+
+// DisplayResolutionOf returns the display resolution of a rung, or nil when it is not mapped.
+func DisplayResolutionOf(rung Resolution) *Resolution {
+	display, ok := displayResolutions[rung]
+	if !ok {
+		return nil
+	}
+	return &display
+}
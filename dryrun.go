@@ -0,0 +1,97 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+)
+
+// -dry-run probes every video, as the walk does to find its resolution and rate, and prints the
+// encode and VMAF commands the run would execute for it without executing any. The grid modes
+// measure exactly the points printed. A walk adapts to the scores, so its plan is every point it
+// could reach, the ladder from where it starts down to the bottom at every target rate, of which it
+// usually measures a few per rate. Scene sampling selects its frames at run time, so the printed
+// VMAF commands score every frame. Besides checking whether an existing hull is out of date for
+// -input-hash, the dry run reads only the metadata of the inputs: it runs no idet on them, so
+// interlaced sources are planned without the deinterlacer of -deinterlace.
+
+// dryRunTotals adds up the plans of every video of a dry run.
+var dryRunTotals struct {
+	sync.Mutex
+	videos, rates, points int
+}
+
+// ValidateDryRun refuses the modes whose work -dry-run cannot plan and the outputs it must not touch.
+func ValidateDryRun() error {
+	if !config.DryRun {
+		return nil
+	}
+	if config.QuickBounds || config.TargetVmaf > 0 || config.EncodeOnly {
+		return errors.New("-dry-run plans hull walks and grids, not -quick-bounds, -target-vmaf or -encode-only")
+	}
+	if config.ManifestPath != "" || config.WebhookUrl != "" || config.CompletedManifest != "" {
+		return errors.New("-dry-run would record planned videos in -manifest, -completed-manifest or -webhook")
+	}
+	if config.CombinedCsv != "" {
+		return errors.New("-dry-run would start -combined-csv over")
+	}
+	return nil
+}
+
+// PlannedPoints returns the operating points the run would measure for a source, and whether the
+// walk measures only some of them.
+func PlannedPoints(referenceVideoResolution Resolution, referenceVideoRate int) ([]OperatingPoint, bool) {
+	if len(operatingGrid) > 0 {
+		return operatingGrid, false
+	}
+	if config.Exhaustive {
+		return ExhaustiveGrid(referenceVideoResolution, referenceVideoRate), false
+	}
+	start := WalkStart(referenceVideoResolution)
+	var points []OperatingPoint
+	for _, rate := range GetTargetRates(referenceVideoRate) {
+		for _, resolution := range LadderFor(referenceVideoResolution) {
+			if resolution.Height <= start.Height && resolution.Width <= start.Width {
+				points = append(points, OperatingPoint{Resolution: resolution, Rate: rate})
+			}
+		}
+	}
+	return points, true
+}
+
+// PrintDryRun prints the commands the run would execute for videoFilename.
+func PrintDryRun(videoFilename string, referenceVideoResolution Resolution, referenceVideoRate int) {
+	points, adaptive := PlannedPoints(referenceVideoResolution, referenceVideoRate)
+	rates := make(map[int]bool)
+	var plan strings.Builder
+	for _, point := range points {
+		rates[point.Rate] = true
+		encodedFilename := EncodedFilename(videoFilename, point.Resolution, point.Rate)
+		model := SelectVmafModelFor(videoFilename, referenceVideoResolution, point.Resolution)
+		fmt.Fprintf(&plan, "  %s at %d kbps -> %s\n", point.Resolution.ToFilterString(), point.Rate, encodedFilename)
+		if firstPass := BuildFirstPassCommand(videoFilename, encodedFilename, point.Resolution, point.Rate); firstPass != nil {
+			fmt.Fprintf(&plan, "    pass 1: %s\n", firstPass.String())
+		}
+		fmt.Fprintf(&plan, "    encode: %s\n", BuildEncodeCommand(videoFilename, encodedFilename, point.Resolution, point.Rate).String())
+		fmt.Fprintf(&plan, "    vmaf:   %s\n", BuildVmafCommand(videoFilename, referenceVideoResolution, ScoredFilename(encodedFilename), point.Resolution, model, "").String())
+	}
+
+	bound := "exactly"
+	if adaptive {
+		bound = "at most"
+	}
+	fmt.Printf("Dry run of %s: %s %d encodes and VMAF passes over %d rates, hull to %s\n%s", videoFilename, bound, len(points), len(rates), HullFilename(videoFilename), plan.String())
+	dryRunTotals.Lock()
+	dryRunTotals.videos++
+	dryRunTotals.rates += len(rates)
+	dryRunTotals.points += len(points)
+	dryRunTotals.Unlock()
+}
+
+// PrintDryRunTotals prints the work planned for the whole run.
+func PrintDryRunTotals() {
+	dryRunTotals.Lock()
+	defer dryRunTotals.Unlock()
+	fmt.Printf("Dry run: %d videos, %d rates, at most %d encodes and as many VMAF passes\n", dryRunTotals.videos, dryRunTotals.rates, dryRunTotals.points)
+}
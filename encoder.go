@@ -0,0 +1,584 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Encoder is one video encoder the walk can encode with. BuildEncodeCommand supplies the input,
+// stream selection, scaling, color and output around the options the encoder builds, so a codec or
+// hardware encoder is added by registering an Encoder and a CodecProfile of its defaults, without
+// touching the walk.
+type Encoder interface {
+	// Name is the ffmpeg encoder name, such as libx264.
+	Name() string
+	// Validate reports an error for settings the encoder does not accept, such as -profile or -level.
+	Validate() error
+	// BuildArgs returns the ffmpeg output options encoding with the profile at rate kbps.
+	BuildArgs(profile CodecProfile, rate int) []string
+}
+
+// encoders are the encoders with behavior of their own, by name. Encoders of a codec profile that
+// are not listed are run as an ffmpegEncoder.
+var encoders = map[string]Encoder{
+	"libx264":    x264Encoder{ffmpegEncoder{"libx264"}},
+	"libx265":    x265Encoder{ffmpegEncoder{"libx265"}},
+	"libvpx-vp9": vp9Encoder{ffmpegEncoder{"libvpx-vp9"}},
+	"libaom-av1": aomEncoder{ffmpegEncoder{"libaom-av1"}},
+	"libsvtav1":  svtAv1Encoder{ffmpegEncoder{"libsvtav1"}},
+	"h264_nvenc": nvencEncoder{ffmpegEncoder{"h264_nvenc"}, []string{"baseline", "main", "high", "high444p"}, h264Level},
+	"hevc_nvenc": nvencEncoder{ffmpegEncoder{"hevc_nvenc"}, []string{"main", "main10", "rext"}, hevcLevel},
+	"av1_nvenc":  nvencEncoder{ffmpegEncoder{"av1_nvenc"}, []string{"main"}, av1Level},
+	"h264_qsv":   qsvEncoder{ffmpegEncoder{"h264_qsv"}, []string{"baseline", "main", "high"}, h264Level, "libx264"},
+	"hevc_qsv":   qsvEncoder{ffmpegEncoder{"hevc_qsv"}, []string{"main", "main10", "mainsp", "rext"}, hevcLevel, "libx265"},
+}
+
+// TwoPassEncoder is implemented by encoders that encode in two passes when TwoPass says so: an
+// analysis pass writing statistics to the -passlogfile and the encode reading them back, which
+// meets the target rate far more closely than a single pass.
+type TwoPassEncoder interface {
+	Encoder
+	TwoPass() bool
+}
+
+// twoPass reports whether the active encoder encodes in two passes.
+func twoPass() bool {
+	encoder, ok := ActiveEncoder().(TwoPassEncoder)
+	return ok && encoder.TwoPass()
+}
+
+// PassLogPrefix returns the -passlogfile of the two-pass encode to outputFilename.
+func PassLogPrefix(outputFilename string) string {
+	return outputFilename + ".pass"
+}
+
+// PassLogFilename returns the statistics file ffmpeg writes for the -passlogfile of outputFilename,
+// suffixed with the index of the one video stream encoded.
+func PassLogFilename(outputFilename string) string {
+	return PassLogPrefix(outputFilename) + "-0.log"
+}
+
+// TiledEncoder is implemented by encoders that split frames into the tiles of -tiles.
+type TiledEncoder interface {
+	Encoder
+	// TileArgs returns the options splitting frames into columns by rows tiles, both powers of two.
+	TileArgs(columns, rows int) []string
+}
+
+// ParseTiles parses a -tiles value such as 2x2 into its columns and rows.
+func ParseTiles(tiles string) (int, int, error) {
+	columns, rows, ok := strings.Cut(tiles, "x")
+	if !ok {
+		return 0, 0, fmt.Errorf("tiles %q are not COLUMNSxROWS", tiles)
+	}
+	var counts [2]int
+	for i, count := range []string{columns, rows} {
+		n, err := strconv.Atoi(count)
+		if err != nil || n < 1 || n&(n-1) != 0 {
+			return 0, 0, fmt.Errorf("tile count %q is not a power of two", count)
+		}
+		counts[i] = n
+	}
+	return counts[0], counts[1], nil
+}
+
+// ValidateTiles checks -tiles and that the active encoder has tiles.
+func ValidateTiles() error {
+	if config.Tiles == "" {
+		return nil
+	}
+	if _, _, err := ParseTiles(config.Tiles); err != nil {
+		return err
+	}
+	if _, ok := ActiveEncoder().(TiledEncoder); !ok {
+		return fmt.Errorf("%s has no tiles", ActiveEncoder().Name())
+	}
+	return nil
+}
+
+// tileArgs returns the tile options of encoder for -tiles, none when unset.
+func tileArgs(encoder TiledEncoder) []string {
+	if config.Tiles == "" {
+		return nil
+	}
+	columns, rows, err := ParseTiles(config.Tiles)
+	if err != nil {
+		return nil
+	}
+	return encoder.TileArgs(columns, rows)
+}
+
+// CrfEncoder is implemented by encoders that encode at the capped constant rate factor of -crf.
+type CrfEncoder interface {
+	Encoder
+	// MaxCrf is the highest rate factor the encoder accepts, the lowest being 1.
+	MaxCrf() int
+}
+
+// ValidateCrf checks -crf against the range of the active encoder.
+func ValidateCrf() error {
+	if config.Crf == 0 {
+		return nil
+	}
+	encoder, ok := ActiveEncoder().(CrfEncoder)
+	if !ok {
+		return fmt.Errorf("%s has no capped CRF", ActiveEncoder().Name())
+	}
+	if config.Crf < 1 || config.Crf > encoder.MaxCrf() {
+		return fmt.Errorf("CRF %d is not between 1 and %d", config.Crf, encoder.MaxCrf())
+	}
+	return nil
+}
+
+// splitParams separates the values of option, such as -x265-params, from the other arguments, so
+// they can be joined with further parameters into the one option the encoder keeps.
+func splitParams(args []string, option string) ([]string, []string) {
+	var params, rest []string
+	for i := 0; i < len(args); i++ {
+		if args[i] == option && i+1 < len(args) {
+			params = append(params, args[i+1])
+			i++
+			continue
+		}
+		rest = append(rest, args[i])
+	}
+	return params, rest
+}
+
+// log2 returns the exponent of a power of two.
+func log2(n int) int {
+	exponent := 0
+	for n > 1 {
+		n >>= 1
+		exponent++
+	}
+	return exponent
+}
+
+// FallbackEncoder is implemented by hardware encoders that can fail to initialize on a machine
+// whose ffmpeg lists them, for want of a device or driver. The run then encodes with the software
+// codec profile of Fallback instead.
+type FallbackEncoder interface {
+	Encoder
+	Fallback() string
+	// Probe encodes a few synthetic frames, returning an error when the encoder does not initialize
+	// or does not run where it was told to.
+	Probe() error
+}
+
+// ApplyEncoderFallback probes the active encoder when it has a fallback, and switches -codec to the
+// fallback when the probe fails, rather than failing every encode of the run. It runs once the
+// binaries are found, so a missing ffmpeg is not mistaken for a failing encoder, and checks the
+// encoder settings against the fallback like they were against the codec; the preflight then
+// checks that ffmpeg has the fallback encoder.
+func ApplyEncoderFallback() error {
+	encoder, ok := ActiveEncoder().(FallbackEncoder)
+	if !ok {
+		return nil
+	}
+	err := encoder.Probe()
+	if err == nil {
+		return nil
+	}
+	slog.Warn("Encoder failed to initialize, encoding in software", "encoder", encoder.Name(), "fallback", encoder.Fallback(), "err", err)
+	config.Codec = encoder.Fallback()
+	for _, check := range []func() error{ValidateEncoder, ValidateTiles, ValidateCrf} {
+		if err := check(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ActiveEncoder returns the encoder of the active codec profile.
+func ActiveEncoder() Encoder {
+	name := ActiveCodecProfile().Encoder
+	if encoder, ok := encoders[name]; ok {
+		return encoder
+	}
+	return ffmpegEncoder{name}
+}
+
+// ValidateEncoder checks the encoder settings against the active encoder.
+func ValidateEncoder() error {
+	return ActiveEncoder().Validate()
+}
+
+// containsString reports whether values holds value.
+func containsString(values []string, value string) bool {
+	for _, candidate := range values {
+		if candidate == value {
+			return true
+		}
+	}
+	return false
+}
+
+// ffmpegEncoder is an ffmpeg encoder controlled by the common options: an average rate with -b:v and
+// -profile:v and -level:v passed through for the encoder to check.
+type ffmpegEncoder struct {
+	name string
+}
+
+func (encoder ffmpegEncoder) Name() string {
+	return encoder.name
+}
+
+func (encoder ffmpegEncoder) Validate() error {
+	return nil
+}
+
+func (encoder ffmpegEncoder) BuildArgs(profile CodecProfile, rate int) []string {
+	args := profile.EncodeArgs()
+	args = append(args, EncoderThreadArgs()...)
+	args = append(args, "-b:v", fmt.Sprintf("%dk", rate))
+	if config.Profile != "" {
+		args = append(args, "-profile:v", config.Profile)
+	}
+	if config.Level != "" {
+		args = append(args, "-level:v", config.Level)
+	}
+	return args
+}
+
+var h264Profiles = []string{"baseline", "main", "high", "high10", "high422", "high444"}
+
+var h264Level = regexp.MustCompile(`^[1-6](\.[0-2])?$|^1b$`)
+
+// x264Encoder is libx264, which checks -profile and -level before the walk rather than failing
+// every encode.
+type x264Encoder struct {
+	ffmpegEncoder
+}
+
+func (encoder x264Encoder) Validate() error {
+	if config.Profile != "" && !containsString(h264Profiles, config.Profile) {
+		return fmt.Errorf("unknown profile %q, expected one of %s", config.Profile, strings.Join(h264Profiles, ", "))
+	}
+	if config.Level != "" && !h264Level.MatchString(config.Level) {
+		return fmt.Errorf("invalid level %q, expected a level such as 3.1 or 4", config.Level)
+	}
+	return nil
+}
+
+var (
+	hevcProfiles = []string{"main", "main10", "main12", "mainstillpicture", "main-intra", "main10-intra", "main422-10", "main444-8", "main444-10"}
+	hevcLevel    = regexp.MustCompile(`^(1|2|2\.1|3|3\.1|4|4\.1|5|5\.[12]|6|6\.[12])$`)
+	x265Tunes    = []string{"psnr", "ssim", "grain", "zerolatency", "fastdecode", "animation"}
+)
+
+// x265Encoder is libx265. It takes its threads, pools in x265 terms, and level through
+// -x265-params rather than the ffmpeg options, and ffmpeg keeps only the last -x265-params given,
+// so they are merged with those of the profile into one. HEVC in MP4 and MOV is tagged hvc1, which
+// Apple players require; other containers keep their own tag.
+type x265Encoder struct {
+	ffmpegEncoder
+}
+
+func (encoder x265Encoder) Validate() error {
+	if config.Profile != "" && !containsString(hevcProfiles, config.Profile) {
+		return fmt.Errorf("unknown HEVC profile %q, expected one of %s", config.Profile, strings.Join(hevcProfiles, ", "))
+	}
+	if config.Level != "" && !hevcLevel.MatchString(config.Level) {
+		return fmt.Errorf("invalid HEVC level %q, expected a level such as 4.1 or 5", config.Level)
+	}
+	if tune := ActiveCodecProfile().Tune; tune != "" && !containsString(x265Tunes, tune) {
+		return fmt.Errorf("unknown x265 tune %q, expected one of %s", tune, strings.Join(x265Tunes, ", "))
+	}
+	return nil
+}
+
+func (encoder x265Encoder) BuildArgs(profile CodecProfile, rate int) []string {
+	var params []string
+	params, profile.ExtraArgs = splitParams(profile.ExtraArgs, "-x265-params")
+	if cpuPlan.EncoderThreads > 0 {
+		params = append(params, fmt.Sprintf("pools=%d", cpuPlan.EncoderThreads))
+	}
+	if config.Level != "" {
+		params = append(params, "level-idc="+config.Level)
+	}
+
+	args := append(profile.EncodeArgs(), "-b:v", fmt.Sprintf("%dk", rate))
+	if config.Profile != "" {
+		args = append(args, "-profile:v", config.Profile)
+	}
+	if len(params) > 0 {
+		args = append(args, "-x265-params", strings.Join(params, ":"))
+	}
+	if profile.Container == "mp4" || profile.Container == "mov" {
+		args = append(args, "-tag:v", "hvc1")
+	}
+	return args
+}
+
+var vp9Level = regexp.MustCompile(`^[1-6](\.[0-2])?$`)
+
+// vp9Encoder is libvpx-vp9 in its good quality deadline, with row based multithreading, encoding in
+// two passes as VP9 rate control is designed to. Its profiles are numbers, 2 and 3 for high bit
+// depth, and its level goes unchanged to the -level target level private to libvpx, which scales it
+// itself, rather than to the generic -level:v.
+type vp9Encoder struct {
+	ffmpegEncoder
+}
+
+func (encoder vp9Encoder) TwoPass() bool {
+	return true
+}
+
+func (encoder vp9Encoder) Validate() error {
+	if config.Profile != "" && !containsString([]string{"0", "1", "2", "3"}, config.Profile) {
+		return fmt.Errorf("unknown VP9 profile %q, expected 0, 1, 2 or 3", config.Profile)
+	}
+	if config.Level != "" && !vp9Level.MatchString(config.Level) {
+		return fmt.Errorf("invalid VP9 level %q, expected a level such as 4.1 or 5", config.Level)
+	}
+	if config.Tune != "" {
+		return errors.New("libvpx-vp9 has no -tune")
+	}
+	return nil
+}
+
+func (encoder vp9Encoder) BuildArgs(profile CodecProfile, rate int) []string {
+	args := profile.EncodeArgs()
+	args = append(args, EncoderThreadArgs()...)
+	args = append(args, "-b:v", fmt.Sprintf("%dk", rate))
+	if config.Profile != "" {
+		args = append(args, "-profile:v", config.Profile)
+	}
+	if config.Level != "" {
+		args = append(args, "-level", config.Level)
+	}
+	return append(args, tileArgs(encoder)...)
+}
+
+// TileArgs passes the tiles of libvpx-vp9 as the base two logarithm of their count.
+func (encoder vp9Encoder) TileArgs(columns, rows int) []string {
+	return []string{"-tile-columns", strconv.Itoa(log2(columns)), "-tile-rows", strconv.Itoa(log2(rows))}
+}
+
+var aomProfiles = []string{"main", "high", "professional"}
+
+// aomEncoder is libaom-av1, whose speed is -cpu-used, the preset of its codec profile, from 0, the
+// slowest and best, to 9. It encodes in two passes like VP9, and has no levels in ffmpeg.
+type aomEncoder struct {
+	ffmpegEncoder
+}
+
+func (encoder aomEncoder) TwoPass() bool {
+	return true
+}
+
+func (encoder aomEncoder) Validate() error {
+	if cpuUsed, err := strconv.Atoi(ActiveCodecProfile().Preset); err != nil || cpuUsed < 0 || cpuUsed > 9 {
+		return fmt.Errorf("libaom-av1 -cpu-used %q is not between 0 and 9", ActiveCodecProfile().Preset)
+	}
+	if config.Profile != "" && !containsString(aomProfiles, config.Profile) {
+		return fmt.Errorf("unknown AV1 profile %q, expected one of %s", config.Profile, strings.Join(aomProfiles, ", "))
+	}
+	if config.Level != "" {
+		return errors.New("libaom-av1 takes no -level")
+	}
+	if tune := ActiveCodecProfile().Tune; tune != "" && tune != "psnr" && tune != "ssim" {
+		return fmt.Errorf("unknown libaom-av1 tune %q, expected psnr or ssim", tune)
+	}
+	return nil
+}
+
+func (encoder aomEncoder) BuildArgs(profile CodecProfile, rate int) []string {
+	args := profile.EncodeArgs()
+	args = append(args, EncoderThreadArgs()...)
+	args = append(args, "-b:v", fmt.Sprintf("%dk", rate))
+	if config.Profile != "" {
+		args = append(args, "-profile:v", config.Profile)
+	}
+	return append(args, tileArgs(encoder)...)
+}
+
+// TileArgs passes the tiles of libaom-av1 as their count.
+func (encoder aomEncoder) TileArgs(columns, rows int) []string {
+	return []string{"-tiles", fmt.Sprintf("%dx%d", columns, rows)}
+}
+
+// svtAv1Encoder is libsvtav1, the fast AV1 encoder, with presets from 0, the slowest, to 13. It
+// encodes in one pass at an average rate, or with -crf at a constant rate factor capped at the
+// target rate, which spends less than the target on easy content. Like x265 it keeps only the last
+// -svtav1-params, so the tiles are merged into those of the profile.
+type svtAv1Encoder struct {
+	ffmpegEncoder
+}
+
+func (encoder svtAv1Encoder) MaxCrf() int {
+	return 63
+}
+
+func (encoder svtAv1Encoder) Validate() error {
+	if preset, err := strconv.Atoi(ActiveCodecProfile().Preset); err != nil || preset < 0 || preset > 13 {
+		return fmt.Errorf("libsvtav1 preset %q is not between 0 and 13", ActiveCodecProfile().Preset)
+	}
+	if config.Profile != "" && !containsString(aomProfiles, config.Profile) {
+		return fmt.Errorf("unknown AV1 profile %q, expected one of %s", config.Profile, strings.Join(aomProfiles, ", "))
+	}
+	if config.Level != "" {
+		return errors.New("libsvtav1 takes no -level")
+	}
+	if config.Tune != "" {
+		return errors.New("libsvtav1 has no -tune, pass tune= in -svtav1-params instead")
+	}
+	return nil
+}
+
+func (encoder svtAv1Encoder) BuildArgs(profile CodecProfile, rate int) []string {
+	var params []string
+	params, profile.ExtraArgs = splitParams(profile.ExtraArgs, "-svtav1-params")
+	params = append(params, tileArgs(encoder)...)
+
+	args := append(profile.EncodeArgs(), EncoderThreadArgs()...)
+	if config.Crf > 0 {
+		args = append(args, "-crf", strconv.Itoa(config.Crf), "-maxrate", fmt.Sprintf("%dk", rate))
+	} else {
+		args = append(args, "-b:v", fmt.Sprintf("%dk", rate))
+	}
+	if config.Profile != "" {
+		args = append(args, "-profile:v", config.Profile)
+	}
+	if len(params) > 0 {
+		args = append(args, "-svtav1-params", strings.Join(params, ":"))
+	}
+	return args
+}
+
+// TileArgs returns the tiles of libsvtav1 as -svtav1-params parameters, in base two logarithms.
+func (encoder svtAv1Encoder) TileArgs(columns, rows int) []string {
+	return []string{fmt.Sprintf("tile-columns=%d", log2(columns)), fmt.Sprintf("tile-rows=%d", log2(rows))}
+}
+
+var (
+	nvencPreset = regexp.MustCompile(`^p[1-7]$`)
+	nvencTunes  = []string{"hq", "ll", "ull", "lossless"}
+	av1Level    = regexp.MustCompile(`^[2-7]\.[0-3]$`)
+)
+
+// nvencEncoder is an NVIDIA NVENC encoder, h264_nvenc, hevc_nvenc or av1_nvenc. The average rate
+// maps to variable rate control at -b:v and -crf to constant quality at -cq capped at the target
+// rate, both with a full resolution second pass inside the one encode, since a walk compares rates
+// and single pass NVENC misses them by more than software encoders. The encoder runs on the GPU
+// of -gpu and ignores the CPU budget; its presets are p1, the fastest, to p7.
+type nvencEncoder struct {
+	ffmpegEncoder
+	profiles []string
+	levels   *regexp.Regexp
+}
+
+func (encoder nvencEncoder) MaxCrf() int {
+	return 51
+}
+
+func (encoder nvencEncoder) Validate() error {
+	profile := ActiveCodecProfile()
+	if !nvencPreset.MatchString(profile.Preset) {
+		return fmt.Errorf("%s preset %q is not p1 to p7", encoder.name, profile.Preset)
+	}
+	if profile.Tune != "" && !containsString(nvencTunes, profile.Tune) {
+		return fmt.Errorf("unknown %s tune %q, expected one of %s", encoder.name, profile.Tune, strings.Join(nvencTunes, ", "))
+	}
+	if config.Profile != "" && !containsString(encoder.profiles, config.Profile) {
+		return fmt.Errorf("unknown %s profile %q, expected one of %s", encoder.name, config.Profile, strings.Join(encoder.profiles, ", "))
+	}
+	if config.Level != "" && !encoder.levels.MatchString(config.Level) {
+		return fmt.Errorf("invalid %s level %q", encoder.name, config.Level)
+	}
+	if config.Gpu < -1 {
+		return fmt.Errorf("GPU %d is neither an index nor -1", config.Gpu)
+	}
+	return nil
+}
+
+func (encoder nvencEncoder) BuildArgs(profile CodecProfile, rate int) []string {
+	args := append(profile.EncodeArgs(), "-rc", "vbr", "-multipass", "fullres")
+	if config.Crf > 0 {
+		args = append(args, "-cq", strconv.Itoa(config.Crf), "-b:v", "0", "-maxrate", fmt.Sprintf("%dk", rate))
+	} else {
+		args = append(args, "-b:v", fmt.Sprintf("%dk", rate))
+	}
+	args = append(args, "-bufsize", fmt.Sprintf("%dk", 2*rate))
+	if config.Gpu >= 0 {
+		args = append(args, "-gpu", strconv.Itoa(config.Gpu))
+	}
+	if config.Profile != "" {
+		args = append(args, "-profile:v", config.Profile)
+	}
+	if config.Level != "" {
+		args = append(args, "-level:v", config.Level)
+	}
+	if encoder.name == "hevc_nvenc" && (profile.Container == "mp4" || profile.Container == "mov") {
+		args = append(args, "-tag:v", "hvc1")
+	}
+	return args
+}
+
+// qsvEncoder is an Intel Quick Sync encoder, h264_qsv or hevc_qsv, on the device of -qsv-device. It
+// takes the x264 preset names and encodes at a variable rate from system memory frames, and falls
+// back to the software encoder of the same codec where Quick Sync does not initialize.
+type qsvEncoder struct {
+	ffmpegEncoder
+	profiles []string
+	levels   *regexp.Regexp
+	fallback string
+}
+
+func (encoder qsvEncoder) Fallback() string {
+	return encoder.fallback
+}
+
+// Probe encodes a few frames at the verbose log level, where the child device opened for the
+// session is logged, so an encode on another device than -qsv-device fails the probe.
+func (encoder qsvEncoder) Probe() error {
+	args := append([]string{"-f", "lavfi", "-i", "color=size=256x144:duration=0.2"}, encoder.BuildArgs(ActiveCodecProfile(), 500)...)
+	cmd := ffmpegCommandWithLogLevel("verbose", append(args, "-f", "null", os.DevNull)...)
+	slog.Debug("Executing command", "cmd", cmd.String())
+	output, err := cmd.CombinedOutput()
+	if err != nil {
+		return ClassifyFfmpegError(err, string(output), ErrEncodeFailed)
+	}
+	if config.QsvDevice != "" && !strings.Contains(string(output), config.QsvDevice) {
+		return fmt.Errorf("%s did not open -qsv-device %s", encoder.name, config.QsvDevice)
+	}
+	return nil
+}
+
+func (encoder qsvEncoder) Validate() error {
+	if config.Profile != "" && !containsString(encoder.profiles, config.Profile) {
+		return fmt.Errorf("unknown %s profile %q, expected one of %s", encoder.name, config.Profile, strings.Join(encoder.profiles, ", "))
+	}
+	if config.Level != "" && !encoder.levels.MatchString(config.Level) {
+		return fmt.Errorf("invalid %s level %q", encoder.name, config.Level)
+	}
+	if config.Tune != "" {
+		return fmt.Errorf("%s has no -tune", encoder.name)
+	}
+	return nil
+}
+
+func (encoder qsvEncoder) BuildArgs(profile CodecProfile, rate int) []string {
+	var args []string
+	if config.QsvDevice != "" {
+		args = append(args, "-init_hw_device", "qsv=hw:,child_device="+config.QsvDevice)
+	}
+	args = append(args, profile.EncodeArgs()...)
+	args = append(args, "-b:v", fmt.Sprintf("%dk", rate), "-maxrate", fmt.Sprintf("%dk", 2*rate), "-bufsize", fmt.Sprintf("%dk", 2*rate))
+	if config.Profile != "" {
+		args = append(args, "-profile:v", config.Profile)
+	}
+	if config.Level != "" {
+		args = append(args, "-level:v", config.Level)
+	}
+	if encoder.name == "hevc_qsv" && (profile.Container == "mp4" || profile.Container == "mov") {
+		args = append(args, "-tag:v", "hvc1")
+	}
+	return args
+}
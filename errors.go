@@ -0,0 +1,125 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os/exec"
+	"strings"
+)
+
+// Failure modes callers can tell apart with errors.Is. EncodeVideo, ComputeVmaf (through
+// VmafMetrics.Err) and the log and probe parsers return errors wrapping one of these:
+//
+//	errors.Is(err, ErrFfmpegNotFound)      ffmpeg or ffprobe is not on the PATH
+//	errors.Is(err, ErrEncoderUnavailable)  ffmpeg reports "Unknown encoder"
+//	errors.Is(err, ErrLibvmafMissing)      ffmpeg reports "No such filter: 'libvmaf'"
+//	errors.Is(err, ErrInputNotFound)       ffmpeg reports "No such file or directory" for an input
+//	errors.Is(err, ErrEncodeFailed)        any other encode failure
+//	errors.Is(err, ErrVmafFailed)          any other scoring failure
+//	errors.Is(err, ErrVmafParse)           the libvmaf log is missing or malformed
+//	errors.Is(err, ErrProbeFailed)         any other ffprobe failure
+//	errors.Is(err, ErrTimeout)             the asset ran out of its -asset-timeout, see CheckAssetBudget
+//
+// The more specific kinds take precedence over ErrEncodeFailed and ErrVmafFailed, and errors.As
+// still reaches the underlying *exec.ExitError.
+var (
+	ErrFfmpegNotFound     = errors.New("ffmpeg not found")
+	ErrEncoderUnavailable = errors.New("encoder unavailable")
+	ErrLibvmafMissing     = errors.New("libvmaf missing")
+	ErrInputNotFound      = errors.New("input not found")
+	ErrEncodeFailed       = errors.New("encode failed")
+	ErrVmafFailed         = errors.New("vmaf failed")
+	ErrVmafParse          = errors.New("vmaf parse failed")
+	ErrProbeFailed        = errors.New("probe failed")
+	ErrTimeout            = errors.New("timeout")
+)
+
+// FfmpegError is a failed ffmpeg or ffprobe process.
+type FfmpegError struct {
+	// Kind is one of the sentinel errors above.
+	Kind error
+	// Err is the error of the process, and Stderr the last line it wrote.
+	Err    error
+	Stderr string
+}
+
+func (e *FfmpegError) Error() string {
+	if e.Stderr == "" {
+		return fmt.Sprintf("%s: %s", e.Kind.Error(), e.Err.Error())
+	}
+	return fmt.Sprintf("%s: %s: %s", e.Kind.Error(), e.Err.Error(), e.Stderr)
+}
+
+// Is matches the kind of the failure.
+func (e *FfmpegError) Is(target error) bool {
+	return target == e.Kind
+}
+
+func (e *FfmpegError) Unwrap() error {
+	return e.Err
+}
+
+// VmafFailure returns why scoring failed for metrics with a negative mean, ErrVmafFailed when the
+// failure was not classified.
+func VmafFailure(metrics VmafMetrics) error {
+	if metrics.Err != nil {
+		return metrics.Err
+	}
+	return ErrVmafFailed
+}
+
+// ClassifyFfmpegError returns the error of a failed process with the kind read from its error and
+// stderr, or fallback when nothing more specific is recognised.
+func ClassifyFfmpegError(err error, stderr string, fallback error) error {
+	if err == nil {
+		return nil
+	}
+	kind := fallback
+	switch {
+	case errors.Is(err, exec.ErrNotFound):
+		kind = ErrFfmpegNotFound
+	case strings.Contains(stderr, "Unknown encoder"):
+		kind = ErrEncoderUnavailable
+	case strings.Contains(stderr, "No such filter: 'libvmaf'"):
+		kind = ErrLibvmafMissing
+	case strings.Contains(stderr, "No such file or directory"):
+		kind = ErrInputNotFound
+	}
+	return &FfmpegError{Kind: kind, Err: err, Stderr: lastLine(stderr)}
+}
+
+// stderrOf returns the stderr captured in the error of exec.Cmd.Output.
+func stderrOf(err error) string {
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) {
+		return string(exitErr.Stderr)
+	}
+	return ""
+}
+
+// lastLine returns the last non-empty line of output.
+func lastLine(output string) string {
+	lines := strings.Split(strings.TrimSpace(output), "\n")
+	return strings.TrimSpace(lines[len(lines)-1])
+}
+
+// stderrTail keeps the last stderrTailSize bytes written to it, enough for ffmpeg's final error
+// without holding the whole log of a long run.
+type stderrTail struct {
+	buffer bytes.Buffer
+}
+
+const stderrTailSize = 4096
+
+func (tail *stderrTail) Write(p []byte) (int, error) {
+	tail.buffer.Write(p)
+	if excess := tail.buffer.Len() - stderrTailSize; excess > 0 {
+		tail.buffer.Next(excess)
+	}
+	return len(p), nil
+}
+
+func (tail *stderrTail) String() string {
+	return tail.buffer.String()
+}
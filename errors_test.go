@@ -0,0 +1,72 @@
+package main
+
+import (
+	"errors"
+	"os/exec"
+	"strings"
+	"testing"
+	"time"
+)
+
+var sentinels = []error{ErrFfmpegNotFound, ErrEncoderUnavailable, ErrLibvmafMissing, ErrInputNotFound, ErrEncodeFailed, ErrVmafFailed, ErrVmafParse, ErrProbeFailed, ErrTimeout}
+
+// exitError returns the *exec.ExitError of a process that failed.
+func exitError(t *testing.T) error {
+	t.Helper()
+	err := exec.Command("false").Run()
+	var exitErr *exec.ExitError
+	if !errors.As(err, &exitErr) {
+		t.Skipf("false did not exit with an error: %v", err)
+	}
+	return err
+}
+
+func TestErrorsIsSentinel(t *testing.T) {
+	previous := config
+	t.Cleanup(func() { config = previous })
+	config = DefaultConfig()
+	failed := exitError(t)
+
+	config.AssetTimeout = time.Minute
+	defer StartAssetBudget("overdue.mp4", time.Now().Add(-time.Hour))()
+	_, parseErr := ParseVmafLog(strings.NewReader(`{"pooled_metrics":`), nil)
+
+	for _, test := range []struct {
+		name string
+		err  error
+		want error
+	}{
+		{"not on the PATH", ClassifyFfmpegError(&exec.Error{Name: "ffmpeg", Err: exec.ErrNotFound}, "", ErrEncodeFailed), ErrFfmpegNotFound},
+		{"unknown encoder", ClassifyFfmpegError(failed, "[vost#0:0] Unknown encoder 'libx265'\n", ErrEncodeFailed), ErrEncoderUnavailable},
+		{"no libvmaf", ClassifyFfmpegError(failed, "No such filter: 'libvmaf'\n", ErrVmafFailed), ErrLibvmafMissing},
+		{"no input", ClassifyFfmpegError(failed, "master.mov: No such file or directory\n", ErrEncodeFailed), ErrInputNotFound},
+		{"encode", ClassifyFfmpegError(failed, "Conversion failed!\n", ErrEncodeFailed), ErrEncodeFailed},
+		{"vmaf", ClassifyFfmpegError(failed, "Conversion failed!\n", ErrVmafFailed), ErrVmafFailed},
+		{"unclassified vmaf", VmafFailure(VmafMetrics{Mean: -1}), ErrVmafFailed},
+		{"vmaf log", parseErr, ErrVmafParse},
+		{"probe", ClassifyFfmpegError(failed, "Invalid data found when processing input\n", ErrProbeFailed), ErrProbeFailed},
+		{"asset timeout", CheckAssetBudget("overdue.mp4"), ErrTimeout},
+	} {
+		for _, sentinel := range sentinels {
+			if got := errors.Is(test.err, sentinel); got != (sentinel == test.want) {
+				t.Errorf("%s: errors.Is(%v, %v) is %v", test.name, test.err, sentinel, got)
+			}
+		}
+	}
+}
+
+// The kind is matched with errors.Is and the process error stays reachable with errors.As.
+func TestFfmpegErrorUnwrap(t *testing.T) {
+	failed := exitError(t)
+	err := ClassifyFfmpegError(failed, "frame=  10\nUnknown encoder 'libsvtav1'\n", ErrEncodeFailed)
+	var exitErr *exec.ExitError
+	if !errors.As(err, &exitErr) {
+		t.Errorf("errors.As(%v) does not reach the *exec.ExitError", err)
+	}
+	if !strings.HasSuffix(err.Error(), ": Unknown encoder 'libsvtav1'") {
+		t.Errorf("%q does not end with the last line of stderr", err.Error())
+	}
+	if ClassifyFfmpegError(nil, "Unknown encoder", ErrEncodeFailed) != nil {
+		t.Error("a process that succeeded has an error")
+	}
+}
@@ -0,0 +1,126 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"sort"
+)
+
+// EncodeSettings are the settings that make points of two runs comparable. Extending a hull with
+// points measured under other settings would mix incompatible measurements into one curve.
+type EncodeSettings struct {
+	Codec              CodecProfile
+	Profile            string `json:",omitempty"`
+	Level              string `json:",omitempty"`
+	Tiles              string `json:",omitempty"`
+	Crf                int    `json:",omitempty"`
+	ScaleDistorted     string
+	ScaleReference     string
+	VmafResolution     string `json:",omitempty"`
+	DisplayResolutions string `json:",omitempty"`
+	FrameOffset        int    `json:",omitempty"`
+	VmafCi             bool   `json:",omitempty"`
+	VmafFps            string `json:",omitempty"`
+	VmafSubsample      int    `json:",omitempty"`
+	Vsync              string `json:",omitempty"`
+	Async              int    `json:",omitempty"`
+	Deinterlace        string `json:",omitempty"`
+}
+
+// ActiveEncodeSettings returns the settings of the current run.
+func ActiveEncodeSettings() EncodeSettings {
+	settings := EncodeSettings{
+		Codec:              ActiveCodecProfile(),
+		Profile:            config.Profile,
+		Level:              config.Level,
+		Tiles:              config.Tiles,
+		Crf:                config.Crf,
+		ScaleDistorted:     config.ScaleDistorted,
+		ScaleReference:     config.ScaleReference,
+		VmafResolution:     config.VmafResolution,
+		DisplayResolutions: config.DisplayResolutions,
+		FrameOffset:        config.FrameOffset,
+		VmafCi:             config.VmafCi,
+		VmafFps:            config.VmafFps,
+		Vsync:              config.Vsync,
+		Async:              config.Async,
+		Deinterlace:        config.Deinterlace,
+	}
+	// Hulls written before -vmaf-subsample scored every frame and record no subsampling.
+	if config.VmafSubsample > 1 {
+		settings.VmafSubsample = config.VmafSubsample
+	}
+	return settings
+}
+
+// CheckExtendable returns an error when the points of existing were not measured the way the
+// current run would measure them.
+func CheckExtendable(existing RunMetadata) error {
+	if existing.Settings == nil {
+		return errors.New("hull does not record the settings it was computed with")
+	}
+	if !reflect.DeepEqual(*existing.Settings, ActiveEncodeSettings()) {
+		return fmt.Errorf("hull was computed with settings %+v, not %+v", *existing.Settings, ActiveEncodeSettings())
+	}
+	existingPlanes := existing.VmafPlanes
+	if existingPlanes == "" {
+		// Hulls written before -vmaf-planes were scored on luma.
+		existingPlanes = LumaPlanes
+	}
+	if existingPlanes != runMetadata.VmafPlanes {
+		return fmt.Errorf("hull was scored on %s planes, not %s", existingPlanes, runMetadata.VmafPlanes)
+	}
+	if existing.VmafSampling != runMetadata.VmafSampling || !reflect.DeepEqual(existing.VmafWindow, runMetadata.VmafWindow) {
+		return errors.New("hull was scored on other frames")
+	}
+	return nil
+}
+
+// ExtendConvexHull computes the target rates the existing hull has no point for and returns the
+// hull recomputed over old and new points, together with every measured point. When the asset runs
+// out of time they are returned as they stand, with the ErrTimeout error. Each new rate
+// descends from the resolution of the nearest higher measured rate, as the walk would have.
+func ExtendConvexHull(referenceVideoFilename string, referenceVideoResolution Resolution, referenceVideoRate int, existing ConvexHullResult) ([]ConvexHullPoint, []ConvexHullPoint, error) {
+	measured := append([]ConvexHullPoint(nil), existing.Grid...)
+	if len(measured) == 0 {
+		measured = append(measured, existing.ConvexHull...)
+	}
+	for _, attempt := range existing.Attempts {
+		RecordAttempt(referenceVideoFilename, attempt)
+	}
+	measuredRates := make(map[int]bool)
+	for _, point := range measured {
+		measuredRates[point.Rate] = true
+	}
+
+	added := 0
+	for _, targetRate := range GetTargetRates(referenceVideoRate) {
+		if measuredRates[targetRate] {
+			continue
+		}
+		startResolution := WalkStart(referenceVideoResolution)
+		nearest := 0
+		for _, point := range measured {
+			if point.Rate > targetRate && (nearest == 0 || point.Rate < nearest) {
+				nearest = point.Rate
+				startResolution = point.Resolution
+			}
+		}
+		point, err := DescendToOptimalResolution(referenceVideoFilename, referenceVideoResolution, targetRate, startResolution)
+		if errors.Is(err, ErrTimeout) {
+			sort.Slice(measured, func(i, j int) bool { return measured[i].Rate > measured[j].Rate })
+			return HullOfPoints(measured), measured, err
+		}
+		if err != nil {
+			return nil, nil, fmt.Errorf("rate %d: %w", targetRate, err)
+		}
+		measured = append(measured, point)
+		measuredRates[targetRate] = true
+		added++
+	}
+	videoLog(referenceVideoFilename).Info("Extended hull", "rates", added)
+
+	sort.Slice(measured, func(i, j int) bool { return measured[i].Rate > measured[j].Rate })
+	return HullOfPoints(measured), measured, nil
+}
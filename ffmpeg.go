@@ -0,0 +1,132 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// ffmpegLogLevels are the values ffmpeg accepts for -loglevel.
+var ffmpegLogLevels = []string{"quiet", "panic", "fatal", "error", "warning", "info", "verbose", "debug", "trace"}
+
+// FfmpegCommand returns an ffmpeg command with the global options every invocation shares: no
+// banner, -nostdin so a terminal can never stall it waiting for input, -y so an existing output
+// never blocks it on an overwrite prompt, and the configured -loglevel.
+func FfmpegCommand(args ...string) *exec.Cmd {
+	return ffmpegCommandWithLogLevel(config.FfmpegLogLevel, args...)
+}
+
+// ffmpegCommandWithLogLevel is FfmpegCommand for the commands whose log output is parsed, which
+// needs a fixed log level.
+func ffmpegCommandWithLogLevel(logLevel string, args ...string) *exec.Cmd {
+	global := []string{"-hide_banner", "-nostdin", "-y", "-loglevel", logLevel}
+	return exec.Command(config.FfmpegPath, append(global, args...)...)
+}
+
+// FfprobeCommand returns an ffprobe command of the -ffprobe binary.
+func FfprobeCommand(args ...string) *exec.Cmd {
+	return exec.Command(config.FfprobePath, args...)
+}
+
+// requiredBinaries returns the tools every run executes.
+func requiredBinaries() []string {
+	return []string{config.FfmpegPath, config.FfprobePath}
+}
+
+// FindBinary returns an error telling the user how to fix a binary missing from the PATH.
+func FindBinary(name string) error {
+	if _, err := exec.LookPath(name); err != nil {
+		return fmt.Errorf("%s was not found (%w). Install ffmpeg and ffprobe built with --enable-libvmaf, add the directory containing %s to PATH or point -ffmpeg and -ffprobe at them", name, err, filepath.Base(name))
+	}
+	return nil
+}
+
+// sameFile reports whether the binary at path is the one PATH finds for name.
+func sameFile(path string, name string) bool {
+	found, err := exec.LookPath(name)
+	if err != nil {
+		return false
+	}
+	foundInfo, err := os.Stat(found)
+	if err != nil {
+		return false
+	}
+	info, err := os.Stat(path)
+	return err == nil && os.SameFile(info, foundInfo)
+}
+
+// RequireBinaries checks that every required binary can be found, so a missing ffmpeg fails the
+// run at startup instead of failing every asset of the batch. Vidio runs ffmpeg and ffprobe by
+// name, so the directories of -ffmpeg and -ffprobe paths are put first in PATH for it to find the
+// same build, unless PATH already finds it there.
+func RequireBinaries() error {
+	for _, name := range requiredBinaries() {
+		if err := FindBinary(name); err != nil {
+			return err
+		}
+	}
+	for _, name := range []string{config.FfprobePath, config.FfmpegPath} {
+		if strings.ContainsRune(name, filepath.Separator) && !sameFile(name, filepath.Base(name)) {
+			os.Setenv("PATH", filepath.Dir(name)+string(filepath.ListSeparator)+os.Getenv("PATH"))
+		}
+	}
+	return nil
+}
+
+// capabilityChecks check that ffmpeg was built with what the run uses: libvmaf when it scores and
+// the encoder of the codec when it encodes.
+func capabilityChecks(encodes bool, scores bool) []ConfigCheck {
+	var checks []ConfigCheck
+	if scores {
+		checks = append(checks, ConfigCheck{"libvmaf", func() error {
+			filters, err := ffmpegListing("-filters")
+			if err != nil {
+				return err
+			}
+			if !listsName(filters, "libvmaf") {
+				return fmt.Errorf("%s was built without the libvmaf filter", config.FfmpegPath)
+			}
+			return nil
+		}})
+	}
+	if encodes {
+		encoder := ActiveEncoder().Name()
+		checks = append(checks, ConfigCheck{"encoder " + encoder, func() error {
+			encoders, err := ffmpegListing("-encoders")
+			if err != nil {
+				return err
+			}
+			if !listsName(encoders, encoder) {
+				return fmt.Errorf("%s was built without the %s encoder", config.FfmpegPath, encoder)
+			}
+			return nil
+		}})
+	}
+	return checks
+}
+
+// Preflight checks the binaries and their capabilities at startup, so an ffmpeg built without
+// libvmaf or the encoder fails the run with the reason rather than every video part way.
+func Preflight(encodes bool, scores bool) error {
+	if err := RequireBinaries(); err != nil {
+		return err
+	}
+	for _, check := range capabilityChecks(encodes, scores) {
+		if err := check.Check(); err != nil {
+			return fmt.Errorf("%s: %w", check.Name, err)
+		}
+	}
+	return nil
+}
+
+// ValidateFfmpegLogLevel checks -ffmpeg-loglevel.
+func ValidateFfmpegLogLevel() error {
+	for _, level := range ffmpegLogLevels {
+		if config.FfmpegLogLevel == level {
+			return nil
+		}
+	}
+	return fmt.Errorf("unknown ffmpeg log level %q", config.FfmpegLogLevel)
+}
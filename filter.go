@@ -0,0 +1,79 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// Input filters leave sources out of a corpus run by what they are rather than by name: trailers
+// and stills by -min-duration and -max-duration, sources already encoded with a codec by
+// -exclude-codec, and small or starved sources by -min-height and -min-source-rate. A filtered
+// source is recorded as skipped with the filter that excluded it, right after probing and before
+// any other check or encode, so a source left out is never failed by the checks, and -dry-run plans
+// without it too. A source whose duration cannot be probed, such as a raw stream, is taken to be
+// below -min-duration.
+
+// ValidateInputFilters checks the input filter flags.
+func ValidateInputFilters() error {
+	if config.MinDuration < 0 || config.MaxDuration < 0 {
+		return errors.New("durations must not be negative")
+	}
+	if config.MaxDuration > 0 && config.MinDuration > config.MaxDuration {
+		return fmt.Errorf("-min-duration %g is above -max-duration %g", config.MinDuration, config.MaxDuration)
+	}
+	if config.MinHeight < 0 || config.MinSourceRate < 0 {
+		return errors.New("-min-height and -min-source-rate must not be negative")
+	}
+	if config.MaxHeight > 0 && config.MinHeight > config.MaxHeight {
+		return fmt.Errorf("-min-height %d is above -max-height %d", config.MinHeight, config.MaxHeight)
+	}
+	return nil
+}
+
+// excludedCodecs returns the codec names of -exclude-codec, lower case.
+func excludedCodecs() map[string]bool {
+	codecs := make(map[string]bool)
+	for _, codec := range strings.Split(config.ExcludeCodec, ",") {
+		if codec = strings.ToLower(strings.TrimSpace(codec)); codec != "" {
+			codecs[codec] = true
+		}
+	}
+	return codecs
+}
+
+// InputFilterReason returns why the input filters exclude videoFilename, with the probed stream,
+// the resolution it is walked at and its rate in kbps, or "" when it is walked. It returns an
+// error when a filter is set that the video cannot be checked against.
+func InputFilterReason(videoFilename string, stream ProbeStream, probeErr error, resolution Resolution, rate int) (string, error) {
+	if config.MinHeight > 0 && resolution.ShortSide() < config.MinHeight {
+		return fmt.Sprintf("resolution below %dp", config.MinHeight), nil
+	}
+	if config.MinSourceRate > 0 && rate < config.MinSourceRate {
+		return fmt.Sprintf("rate %d kbps below -min-source-rate %d", rate, config.MinSourceRate), nil
+	}
+	if codecs := excludedCodecs(); len(codecs) > 0 {
+		if probeErr != nil {
+			return "", fmt.Errorf("codec is unknown: %w", probeErr)
+		}
+		if codecs[strings.ToLower(stream.CodecName)] {
+			return fmt.Sprintf("codec %s is excluded", stream.CodecName), nil
+		}
+	}
+	if config.MinDuration > 0 || config.MaxDuration > 0 {
+		duration, err := ProbeDuration(videoFilename)
+		if err != nil && config.MinDuration > 0 {
+			return fmt.Sprintf("duration is unknown, taken as below -min-duration %g", config.MinDuration), nil
+		}
+		if err != nil {
+			return "", fmt.Errorf("duration is unknown: %w", err)
+		}
+		if duration < config.MinDuration {
+			return fmt.Sprintf("duration %.1fs below -min-duration %g", duration, config.MinDuration), nil
+		}
+		if config.MaxDuration > 0 && duration > config.MaxDuration {
+			return fmt.Sprintf("duration %.1fs above -max-duration %g", duration, config.MaxDuration), nil
+		}
+	}
+	return "", nil
+}
@@ -0,0 +1,38 @@
+package main
+
+import "testing"
+
+func TestInputFilterReason(t *testing.T) {
+	previous := config
+	t.Cleanup(func() { config = previous })
+	hd := Resolution{Height: 1080, Width: 1920}
+
+	for _, test := range []struct {
+		name                     string
+		minDuration, maxDuration float64
+		minHeight, minSourceRate int
+		excludeCodec, duration   string
+		resolution               Resolution
+		want                     string
+		err                      bool
+	}{
+		{name: "no filters", resolution: hd},
+		{name: "portrait below -min-height", minHeight: 720, resolution: Resolution{Height: 1280, Width: 576}, want: "resolution below 720p"},
+		{name: "starved", minSourceRate: 5000, resolution: hd, want: "rate 4000 kbps below -min-source-rate 5000"},
+		{name: "excluded codec", excludeCodec: "ProRes, dnxhd", resolution: hd, want: "codec prores is excluded"},
+		{name: "trailer", minDuration: 60, duration: "31.5", resolution: hd, want: "duration 31.5s below -min-duration 60"},
+		{name: "feature", maxDuration: 3600, duration: "5400.0", resolution: hd, want: "duration 5400.0s above -max-duration 3600"},
+		{name: "within durations", minDuration: 60, maxDuration: 3600, duration: "1200", resolution: hd},
+		{name: "unknown duration", minDuration: 60, resolution: hd, want: "duration is unknown, taken as below -min-duration 60"},
+		{name: "unknown duration below a maximum", maxDuration: 3600, resolution: hd, err: true},
+	} {
+		config = DefaultConfig()
+		config.MinDuration, config.MaxDuration = test.minDuration, test.maxDuration
+		config.MinHeight, config.MinSourceRate, config.ExcludeCodec = test.minHeight, test.minSourceRate, test.excludeCodec
+		fakeDuration(t, test.duration)
+		reason, err := InputFilterReason("raw.h264", ProbeStream{CodecName: "prores"}, nil, test.resolution, 4000)
+		if reason != test.want || (err != nil) != test.err {
+			t.Errorf("%s: got %q, %v, want %q", test.name, reason, err, test.want)
+		}
+	}
+}
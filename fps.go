@@ -0,0 +1,76 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// libvmaf pairs frames by position, so a source at 30000/1001 fps scored against an encode at 30
+// fps drifts a frame further out of alignment every 33 seconds, and the score of a long asset sinks
+// with its length. -vmaf-fps puts both inputs through an fps filter at the given rate before any
+// other scoring filter, so frame n of each input covers the same instant over the whole asset.
+// The rate must be within maxFpsDeviation of the source rate: resampling to a rate far from the
+// source duplicates or drops so many frames that the score no longer describes the encode.
+
+// maxFpsDeviation is the largest relative difference between -vmaf-fps and the source rate.
+const maxFpsDeviation = 0.1
+
+// ParseFrameRate parses a frame rate given as a fraction such as 30000/1001 or as a decimal.
+func ParseFrameRate(value string) (float64, error) {
+	numerator, denominator, isFraction := strings.Cut(value, "/")
+	rate, err := strconv.ParseFloat(numerator, 64)
+	if err != nil {
+		return 0, fmt.Errorf("frame rate %q is not a number or fraction", value)
+	}
+	if isFraction {
+		divisor, err := strconv.ParseFloat(denominator, 64)
+		if err != nil || divisor == 0 {
+			return 0, fmt.Errorf("frame rate %q has an invalid denominator", value)
+		}
+		rate /= divisor
+	}
+	if rate <= 0 {
+		return 0, fmt.Errorf("frame rate %q is not positive", value)
+	}
+	return rate, nil
+}
+
+// ValidateVmafFps checks -vmaf-fps.
+func ValidateVmafFps() error {
+	if config.VmafFps == "" {
+		return nil
+	}
+	_, err := ParseFrameRate(config.VmafFps)
+	return err
+}
+
+// CheckVmafFps returns an error when -vmaf-fps is implausible for the source stream.
+func CheckVmafFps(stream ProbeStream) error {
+	if config.VmafFps == "" {
+		return nil
+	}
+	sourceRate, err := ParseFrameRate(stream.AvgFrameRate)
+	if err != nil {
+		sourceRate, err = ParseFrameRate(stream.RFrameRate)
+	}
+	if err != nil {
+		return fmt.Errorf("source frame rate is unknown: %w", err)
+	}
+	vmafRate, err := ParseFrameRate(config.VmafFps)
+	if err != nil {
+		return err
+	}
+	if deviation := (vmafRate - sourceRate) / sourceRate; deviation > maxFpsDeviation || deviation < -maxFpsDeviation {
+		return fmt.Errorf("-vmaf-fps %s is %.0f%% away from the source rate of %.3f fps", config.VmafFps, 100*deviation, sourceRate)
+	}
+	return nil
+}
+
+// FpsFilter returns the fps filter of -vmaf-fps, or an empty string when it is not set.
+func FpsFilter() string {
+	if config.VmafFps == "" {
+		return ""
+	}
+	return "fps=" + config.VmafFps
+}
@@ -1,5 +1,8 @@
 module github.com/neuvideo/vmaf
 
-go 1.18
+go 1.21
 
-require github.com/AlexEidt/Vidio v1.4.2 // indirect
+require (
+	github.com/AlexEidt/Vidio v1.4.2
+	gopkg.in/yaml.v3 v3.0.1
+)
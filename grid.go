@@ -0,0 +1,231 @@
+package main
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// OperatingPoint is one (resolution, rate) pair of a predefined grid.
+type OperatingPoint struct {
+	Resolution Resolution
+	Rate       int
+}
+
+// operatingGrid replaces the walk with a measurement of exactly these points when not empty.
+var operatingGrid []OperatingPoint
+
+// ReadOperatingGrid reads a CSV of WIDTHxHEIGHT,kbps rows. A leading resolution,rate header row and
+// blank lines are skipped.
+func ReadOperatingGrid(filename string) ([]OperatingPoint, error) {
+	file, err := os.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	reader := csv.NewReader(file)
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+	var grid []OperatingPoint
+	seen := make(map[OperatingPoint]int)
+	for row := 1; ; row++ {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		if row == 1 && len(record) > 0 && strings.EqualFold(strings.TrimSpace(record[0]), "resolution") {
+			continue
+		}
+		if len(record) != 2 {
+			return nil, fmt.Errorf("row %d has %d fields, expected resolution,rate", row, len(record))
+		}
+		resolution, err := ParseResolution(record[0])
+		if err != nil {
+			return nil, fmt.Errorf("row %d: %w", row, err)
+		}
+		rate, err := strconv.Atoi(strings.TrimSpace(record[1]))
+		if err != nil || rate <= 0 {
+			return nil, fmt.Errorf("row %d: rate %q is not a positive number of kbps", row, record[1])
+		}
+		point := OperatingPoint{Resolution: resolution, Rate: rate}
+		if previous, ok := seen[point]; ok {
+			return nil, fmt.Errorf("row %d repeats row %d", row, previous)
+		}
+		seen[point] = row
+		grid = append(grid, point)
+	}
+	if len(grid) == 0 {
+		return nil, errors.New("grid has no operating points")
+	}
+	return grid, nil
+}
+
+// measureOperatingPoint measures the operating points of walks and grids. Tests replace it to
+// walk synthetic scores without ffmpeg.
+var measureOperatingPoint = MeasureOperatingPoint
+
+// MeasureOrReuse returns the point of the reference measured at an operating point, reusing the
+// point when an earlier step of the walk measured it, so no point is encoded and scored twice. A
+// measured point is recorded as an attempt.
+func MeasureOrReuse(referenceVideoFilename string, referenceVideoResolution Resolution, point OperatingPoint) (ConvexHullPoint, error) {
+	if measured, ok := MeasuredAttempt(referenceVideoFilename, point); ok {
+		return measured, nil
+	}
+	measured, err := measureOperatingPoint(referenceVideoFilename, referenceVideoResolution, point)
+	if err != nil {
+		return measured, err
+	}
+	attempt := measured
+	attempt.Status = PointOk
+	RecordAttempt(referenceVideoFilename, attempt)
+	return measured, nil
+}
+
+// MeasureOperatingPoint encodes the reference at one operating point and scores the encode.
+func MeasureOperatingPoint(referenceVideoFilename string, referenceVideoResolution Resolution, point OperatingPoint) (ConvexHullPoint, error) {
+	if err := CheckAssetBudget(referenceVideoFilename); err != nil {
+		RecordAttempt(referenceVideoFilename, ConvexHullPoint{Resolution: point.Resolution, Rate: point.Rate, Status: PointTimeout, Reason: err.Error()})
+		return ConvexHullPoint{}, err
+	}
+	encodedFilename := EncodedFilename(referenceVideoFilename, point.Resolution, point.Rate)
+	defer RemoveIntermediate(encodedFilename)
+	defer RemovePrescaled(encodedFilename)
+
+	encodeResult := make(chan error, 1)
+	go EncodeVideo(referenceVideoFilename, encodedFilename, point.Resolution, point.Rate, encodeResult)
+	encodeErr := <-encodeResult
+	encodeUsage := TakeEncodeUsage(encodedFilename)
+	if encodeErr != nil {
+		RecordAttempt(referenceVideoFilename, ConvexHullPoint{Resolution: point.Resolution, Rate: point.Rate, Status: PointFailed, Reason: encodeErr.Error()})
+		return ConvexHullPoint{}, encodeErr
+	}
+
+	encodeTiming := ProbeEncodeTimingOrNil(encodedFilename)
+	model := SelectVmafModelFor(referenceVideoFilename, referenceVideoResolution, point.Resolution)
+	vmafResult := make(chan VmafMetrics, 1)
+	go ComputeVmaf(referenceVideoFilename, referenceVideoResolution, encodedFilename, point.Resolution, model, vmafResult)
+	metrics := <-vmafResult
+	if metrics.Mean < 0 {
+		vmafErr := VmafFailure(metrics)
+		RecordAttempt(referenceVideoFilename, ConvexHullPoint{Resolution: point.Resolution, Rate: point.Rate, VmafModel: model, Status: PointFailed, Reason: vmafErr.Error()})
+		return ConvexHullPoint{}, vmafErr
+	}
+
+	measured := ConvexHullPoint{Resolution: point.Resolution, Rate: point.Rate, VmafScore: metrics.Mean, VmafNegScore: metrics.NegMean, VmafStdDev: metrics.StdDev, Chroma: metrics.Chroma, VmafModel: model, DisplayResolution: DisplayResolutionOf(point.Resolution), VmafCi: metrics.Ci, Profile: config.Profile, Level: config.Level, VmafClamped: metrics.Clamped(), FrameCountMismatch: metrics.FrameCountMismatch, ClipScores: metrics.ClipScores, EncodeTiming: encodeTiming, EncodeUsage: &encodeUsage, VmafUsage: &metrics.Usage}
+	if config.Audit {
+		measured.EncodeCommandHash = CommandHash(BuildEncodeCommand(referenceVideoFilename, encodedFilename, point.Resolution, point.Rate))
+		frameSelection, _ := VmafFrameSelection(referenceVideoFilename)
+		measured.VmafCommandHash = CommandHash(BuildVmafCommand(referenceVideoFilename, referenceVideoResolution, ScoredFilename(encodedFilename), point.Resolution, model, frameSelection))
+	}
+	return measured, nil
+}
+
+// ExhaustiveGrid returns every ladder resolution no larger than the reference at every target rate,
+// highest rate first. Measuring it is the full convex hull approach: no rate depends on another.
+func ExhaustiveGrid(referenceVideoResolution Resolution, referenceVideoRate int) []OperatingPoint {
+	var grid []OperatingPoint
+	for _, rate := range GetTargetRates(referenceVideoRate) {
+		for _, resolution := range LadderFor(referenceVideoResolution) {
+			if resolution.Width <= referenceVideoResolution.Width && resolution.Height <= referenceVideoResolution.Height {
+				grid = append(grid, OperatingPoint{Resolution: resolution, Rate: rate})
+			}
+		}
+	}
+	return grid
+}
+
+// MeasureOperatingGrid measures every point of the grid. The points of one rate are measured in
+// order and a failure skips the rest of that rate. Up to config.ParallelRates rates are measured
+// at once; each writes only its own points' slots, so the measured points keep the grid order
+// whatever order they finish in. The error is that of the first failed point in grid order, and is
+// returned with every point measured.
+func MeasureOperatingGrid(referenceVideoFilename string, referenceVideoResolution Resolution, grid []OperatingPoint) ([]ConvexHullPoint, error) {
+	var rates []int
+	pointsOfRate := make(map[int][]int)
+	for i, point := range grid {
+		if _, ok := pointsOfRate[point.Rate]; !ok {
+			rates = append(rates, point.Rate)
+		}
+		pointsOfRate[point.Rate] = append(pointsOfRate[point.Rate], i)
+	}
+
+	measured := make([]ConvexHullPoint, len(grid))
+	errs := make([]error, len(grid))
+	done := make([]bool, len(grid))
+	slots := make(chan struct{}, IntMax(config.ParallelRates, 1))
+	var wg sync.WaitGroup
+	for _, rate := range rates {
+		wg.Add(1)
+		go func(indices []int) {
+			defer wg.Done()
+			slots <- struct{}{}
+			defer func() { <-slots }()
+			for n, i := range indices {
+				point := grid[i]
+				videoLog(referenceVideoFilename).Info("Measuring", "resolution", point.Resolution.ToFilterString(), "rate", point.Rate)
+				measured[i], errs[i] = measureOperatingPoint(referenceVideoFilename, referenceVideoResolution, point)
+				if errs[i] != nil {
+					for _, skipped := range indices[n+1:] {
+						RecordAttempt(referenceVideoFilename, ConvexHullPoint{Resolution: grid[skipped].Resolution, Rate: grid[skipped].Rate, Status: PointSkipped, Reason: "an earlier point at this rate failed"})
+					}
+					return
+				}
+				done[i] = true
+				attempt := measured[i]
+				attempt.Status = PointOk
+				RecordAttempt(referenceVideoFilename, attempt)
+			}
+		}(pointsOfRate[rate])
+	}
+	wg.Wait()
+
+	points := make([]ConvexHullPoint, 0, len(grid))
+	var err error
+	for i, point := range grid {
+		if errs[i] != nil && err == nil {
+			err = fmt.Errorf("%s at %d kbps: %w", point.Resolution.ToFilterString(), point.Rate, errs[i])
+		}
+		if done[i] {
+			points = append(points, measured[i])
+		}
+	}
+	return points, err
+}
+
+// HullOfPoints returns the points on the rate-quality frontier, highest rate first like a walk: the
+// best resolution at each rate by selection score, ties to the lower resolution, keeping only rates that improve VMAF
+// over every lower rate.
+func HullOfPoints(points []ConvexHullPoint) []ConvexHullPoint {
+	bestAtRate := make(map[int]ConvexHullPoint)
+	for _, point := range points {
+		best, ok := bestAtRate[point.Rate]
+		score, bestScore := point.SelectionScore(), best.SelectionScore()
+		if !ok || score > bestScore || (score == bestScore && point.Resolution.Height < best.Resolution.Height) {
+			bestAtRate[point.Rate] = point
+		}
+	}
+	rates := make([]int, 0, len(bestAtRate))
+	for rate := range bestAtRate {
+		rates = append(rates, rate)
+	}
+	sort.Ints(rates)
+
+	var hull []ConvexHullPoint
+	for _, rate := range rates {
+		point := bestAtRate[rate]
+		if len(hull) == 0 || point.VmafScore > hull[0].VmafScore {
+			hull = append([]ConvexHullPoint{point}, hull...)
+		}
+	}
+	return hull
+}
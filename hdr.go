@@ -0,0 +1,93 @@
+package main
+
+import (
+	"strings"
+	"sync"
+)
+
+// HDR sources are refused unless -hdr is set, since ffmpeg would otherwise convert them to SDR
+// somewhere between the encode and scoring and the scores would describe that conversion rather
+// than the encode. With -hdr nothing is tone-mapped: the encodes are 10-bit and tagged with the
+// source's BT.2020 primaries and PQ or HLG transfer, and both VMAF inputs are converted to the same
+// 10-bit format so libvmaf compares the PQ or HLG signal itself. libvmaf ships no HDR model, so the
+// scores come from the SDR model unless -hdr-vmaf-model names one; they rank encodes of one HDR
+// source against each other but are not comparable with SDR scores.
+
+// hdrPixelFormat is the pixel format HDR encodes and both HDR VMAF inputs are converted to.
+const hdrPixelFormat = "yuv420p10le"
+
+// HdrTransfer returns the HDR transfer characteristics of the stream, or an empty string for SDR.
+func HdrTransfer(stream ProbeStream) string {
+	switch stream.ColorTransfer {
+	case "smpte2084", "arib-std-b67":
+		return stream.ColorTransfer
+	}
+	return ""
+}
+
+// hdrTransfers maps each HDR reference of the run to its transfer characteristics.
+var hdrTransfers = struct {
+	sync.Mutex
+	byFilename map[string]string
+}{byFilename: make(map[string]string)}
+
+// RegisterHdrReference records that referenceFilename is HDR with the given transfer.
+func RegisterHdrReference(referenceFilename string, transfer string) {
+	hdrTransfers.Lock()
+	defer hdrTransfers.Unlock()
+	hdrTransfers.byFilename[referenceFilename] = transfer
+}
+
+// HdrTransferOf returns the transfer registered for referenceFilename, or an empty string for SDR.
+func HdrTransferOf(referenceFilename string) string {
+	hdrTransfers.Lock()
+	defer hdrTransfers.Unlock()
+	return hdrTransfers.byFilename[referenceFilename]
+}
+
+// HdrEncodeArgs returns the output options preserving the HDR signal of referenceFilename in an
+// encode. Color properties set explicitly with -colorspace, -color-primaries and -color-trc win.
+func HdrEncodeArgs(referenceFilename string) []string {
+	transfer := HdrTransferOf(referenceFilename)
+	if transfer == "" {
+		return nil
+	}
+	args := []string{"-pix_fmt", hdrPixelFormat}
+	if config.ColorSpace == "" {
+		args = append(args, "-colorspace", "bt2020nc")
+	}
+	if config.ColorPrimaries == "" {
+		args = append(args, "-color_primaries", "bt2020")
+	}
+	if config.ColorTrc == "" {
+		args = append(args, "-color_trc", transfer)
+	}
+	return args
+}
+
+// HdrScoringFilter returns the filter converting a VMAF input of an HDR reference to the common
+// 10-bit format, or an empty string for SDR.
+func HdrScoringFilter(referenceFilename string) string {
+	if HdrTransferOf(referenceFilename) == "" {
+		return ""
+	}
+	return "format=" + hdrPixelFormat
+}
+
+// SelectVmafModelFor returns the libvmaf model used to score an encode of referenceFilename, at
+// referenceResolution, at the given resolution: the HDR model for HDR references when one is
+// configured, and with -auto-4k-model the 4K model whenever the encode is scored at UHD.
+func SelectVmafModelFor(referenceFilename string, referenceResolution Resolution, resolution Resolution) string {
+	if config.HdrVmafModel != "" && HdrTransferOf(referenceFilename) != "" {
+		return config.HdrVmafModel
+	}
+	if config.AutoUhdModel && config.VmafModel == "" && ScoringResolution(referenceResolution, resolution).ShortSide() >= uhdModelMinShortSide {
+		return UhdVmafModel
+	}
+	return SelectVmafModel(resolution)
+}
+
+// isVmafModelPath reports whether model names a model file rather than a built-in version.
+func isVmafModelPath(model string) bool {
+	return strings.HasSuffix(model, ".json")
+}
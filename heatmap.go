@@ -0,0 +1,172 @@
+package main
+
+import (
+	"encoding/csv"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// -heatmap writes every measured point of an asset as a matrix for quality heatmaps: a row for
+// each resolution, tallest first, and a column for each rate, lowest first. Rows cover the ladder up
+// to the source and columns the target rates, including those above the source rate, plus anything
+// else measured. A cell without a score is null in JSON, with its Status telling why: the attempt
+// status such as failed, infeasible or timeout, or unmeasured for points the walk never tried. CSV
+// cells without a score are NaN.
+
+// Heatmap formats of -heatmap.
+const (
+	JsonHeatmap = "json"
+	CsvHeatmap  = "csv"
+)
+
+// unmeasuredStatus marks heatmap cells no attempt was made for.
+const unmeasuredStatus = "unmeasured"
+
+// Heatmap is the measured grid of an asset as a matrix, Vmaf[row][column] scoring Resolutions[row]
+// at Rates[column].
+type Heatmap struct {
+	Resolutions []Resolution
+	Rates       []int
+	Vmaf        [][]*float64
+	Status      [][]string
+}
+
+// ValidateHeatmap checks -heatmap.
+func ValidateHeatmap() error {
+	if config.Heatmap != "" && config.Heatmap != JsonHeatmap && config.Heatmap != CsvHeatmap {
+		return fmt.Errorf("unknown -heatmap %q, expected %s or %s", config.Heatmap, JsonHeatmap, CsvHeatmap)
+	}
+	return nil
+}
+
+// HeatmapFilename returns the path of the heatmap written next to the hull at convexHullFilename.
+func HeatmapFilename(convexHullFilename string, format string) string {
+	return strings.TrimSuffix(convexHullFilename, filepath.Ext(convexHullFilename)) + ".heatmap." + format
+}
+
+// BuildHeatmap shapes the measured points and attempts of a source at referenceVideoResolution and
+// referenceVideoRate into a heatmap. Attempts without a resolution, which stand for a whole rate,
+// set the status of every cell of that rate without a more specific attempt.
+func BuildHeatmap(referenceVideoResolution Resolution, referenceVideoRate int, points []ConvexHullPoint) Heatmap {
+	resolutionSet := make(map[Resolution]bool)
+	for _, resolution := range LadderFor(referenceVideoResolution) {
+		if resolution.Width <= referenceVideoResolution.Width && resolution.Height <= referenceVideoResolution.Height {
+			resolutionSet[resolution] = true
+		}
+	}
+	rateSet := make(map[int]bool)
+	for _, rate := range append(GetTargetRates(referenceVideoRate), InfeasibleRates(referenceVideoRate)...) {
+		rateSet[rate] = true
+	}
+	for _, point := range points {
+		if point.Resolution != (Resolution{}) {
+			resolutionSet[point.Resolution] = true
+		}
+		rateSet[point.Rate] = true
+	}
+
+	var heatmap Heatmap
+	for resolution := range resolutionSet {
+		heatmap.Resolutions = append(heatmap.Resolutions, resolution)
+	}
+	sort.Slice(heatmap.Resolutions, func(i, j int) bool {
+		if heatmap.Resolutions[i].Height != heatmap.Resolutions[j].Height {
+			return heatmap.Resolutions[i].Height > heatmap.Resolutions[j].Height
+		}
+		return heatmap.Resolutions[i].Width > heatmap.Resolutions[j].Width
+	})
+	for rate := range rateSet {
+		heatmap.Rates = append(heatmap.Rates, rate)
+	}
+	sort.Ints(heatmap.Rates)
+
+	row := make(map[Resolution]int)
+	for i, resolution := range heatmap.Resolutions {
+		row[resolution] = i
+	}
+	column := make(map[int]int)
+	for i, rate := range heatmap.Rates {
+		column[rate] = i
+	}
+	heatmap.Vmaf = make([][]*float64, len(heatmap.Resolutions))
+	heatmap.Status = make([][]string, len(heatmap.Resolutions))
+	for i := range heatmap.Resolutions {
+		heatmap.Vmaf[i] = make([]*float64, len(heatmap.Rates))
+		heatmap.Status[i] = make([]string, len(heatmap.Rates))
+		for j := range heatmap.Rates {
+			heatmap.Status[i][j] = unmeasuredStatus
+		}
+	}
+
+	// Whole-rate attempts first, so attempts at a resolution override them.
+	for _, point := range points {
+		if point.Resolution == (Resolution{}) {
+			for i := range heatmap.Resolutions {
+				if heatmap.Vmaf[i][column[point.Rate]] == nil {
+					heatmap.Status[i][column[point.Rate]] = point.Status
+				}
+			}
+		}
+	}
+	for _, point := range points {
+		if point.Resolution == (Resolution{}) {
+			continue
+		}
+		i, j := row[point.Resolution], column[point.Rate]
+		status := point.Status
+		if status == "" {
+			// Grid and hull points carry no status, they were all measured.
+			status = PointOk
+		}
+		if status == PointOk && point.VmafScore >= 0 {
+			score := point.VmafScore
+			heatmap.Vmaf[i][j] = &score
+		} else if heatmap.Vmaf[i][j] != nil {
+			continue
+		}
+		heatmap.Status[i][j] = status
+	}
+	return heatmap
+}
+
+// WriteHeatmap writes the heatmap of an asset next to its hull in the configured format.
+func WriteHeatmap(result ConvexHullResult, referenceVideoResolution Resolution, referenceVideoRate int, convexHullFilename string) error {
+	points := append(append(append([]ConvexHullPoint(nil), result.Grid...), result.ConvexHull...), result.Attempts...)
+	heatmap := BuildHeatmap(referenceVideoResolution, referenceVideoRate, points)
+	filename := HeatmapFilename(convexHullFilename, config.Heatmap)
+	if config.Heatmap == JsonHeatmap {
+		return WriteJson(heatmap, filename)
+	}
+
+	file, err := os.Create(filename)
+	if err != nil {
+		return err
+	}
+	writer := csv.NewWriter(file)
+	header := []string{"resolution"}
+	for _, rate := range heatmap.Rates {
+		header = append(header, strconv.Itoa(rate))
+	}
+	writer.Write(header)
+	for i, resolution := range heatmap.Resolutions {
+		record := []string{resolution.ToFilterString()}
+		for _, score := range heatmap.Vmaf[i] {
+			if score == nil {
+				record = append(record, "NaN")
+			} else {
+				record = append(record, strconv.FormatFloat(*score, 'f', 6, 64))
+			}
+		}
+		writer.Write(record)
+	}
+	writer.Flush()
+	if err := writer.Error(); err != nil {
+		file.Close()
+		return err
+	}
+	return file.Close()
+}
@@ -0,0 +1,271 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// HlsVariant is one rendition listed in an HLS master playlist.
+type HlsVariant struct {
+	Uri        string
+	Bandwidth  int
+	Resolution Resolution
+}
+
+// Rate returns the declared rate of the variant in kbps.
+func (variant HlsVariant) Rate() int {
+	return variant.Bandwidth / 1000
+}
+
+// parseHlsAttributes splits an attribute list such as BANDWIDTH=1280000,CODECS="avc1,mp4a" into its
+// attributes, keeping quoted commas.
+func parseHlsAttributes(list string) map[string]string {
+	attributes := make(map[string]string)
+	for len(list) > 0 {
+		eq := strings.IndexByte(list, '=')
+		if eq < 0 {
+			break
+		}
+		name := strings.TrimSpace(list[:eq])
+		list = list[eq+1:]
+		var value string
+		if strings.HasPrefix(list, `"`) {
+			end := strings.IndexByte(list[1:], '"')
+			if end < 0 {
+				value, list = list[1:], ""
+			} else {
+				value, list = list[1:end+1], list[end+2:]
+			}
+		} else if comma := strings.IndexByte(list, ','); comma >= 0 {
+			value, list = list[:comma], list[comma:]
+		} else {
+			value, list = list, ""
+		}
+		attributes[name] = value
+		list = strings.TrimPrefix(list, ",")
+	}
+	return attributes
+}
+
+// ParseHlsMasterPlaylist returns the variants of a master playlist, with URIs resolved against
+// base. AVERAGE-BANDWIDTH is used as the rate where present, BANDWIDTH, the peak, otherwise.
+func ParseHlsMasterPlaylist(reader io.Reader, base *url.URL) ([]HlsVariant, error) {
+	scanner := bufio.NewScanner(reader)
+	var variants []HlsVariant
+	var pending *HlsVariant
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		switch {
+		case line == "":
+		case strings.HasPrefix(line, "#EXT-X-STREAM-INF:"):
+			attributes := parseHlsAttributes(strings.TrimPrefix(line, "#EXT-X-STREAM-INF:"))
+			bandwidth, err := strconv.Atoi(attributes["BANDWIDTH"])
+			if average, ok := attributes["AVERAGE-BANDWIDTH"]; ok {
+				bandwidth, err = strconv.Atoi(average)
+			}
+			if err != nil {
+				return nil, fmt.Errorf("variant %q has no valid bandwidth", line)
+			}
+			variant := HlsVariant{Bandwidth: bandwidth}
+			if resolution, ok := attributes["RESOLUTION"]; ok {
+				variant.Resolution, err = ParseResolution(resolution)
+				if err != nil {
+					return nil, err
+				}
+			}
+			pending = &variant
+		case strings.HasPrefix(line, "#"):
+		case pending != nil:
+			uri, err := base.Parse(line)
+			if err != nil {
+				return nil, err
+			}
+			pending.Uri = uri.String()
+			variants = append(variants, *pending)
+			pending = nil
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	if len(variants) == 0 {
+		return nil, errors.New("playlist has no variants, is it a master playlist?")
+	}
+	return variants, nil
+}
+
+// ReadHlsMasterPlaylist reads the variants of the master playlist at an http(s) URL or local path.
+func ReadHlsMasterPlaylist(location string) ([]HlsVariant, error) {
+	base, err := url.Parse(location)
+	if err != nil {
+		return nil, err
+	}
+	if base.Scheme != "http" && base.Scheme != "https" {
+		absolute, err := filepath.Abs(location)
+		if err != nil {
+			return nil, err
+		}
+		file, err := os.Open(absolute)
+		if err != nil {
+			return nil, err
+		}
+		defer file.Close()
+		return ParseHlsMasterPlaylist(file, &url.URL{Scheme: "file", Path: absolute})
+	}
+
+	response, err := http.Get(location)
+	if err != nil {
+		return nil, err
+	}
+	defer response.Body.Close()
+	if response.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("fetching %s: %s", location, response.Status)
+	}
+	return ParseHlsMasterPlaylist(response.Body, base)
+}
+
+// HlsVariantInput returns the ffmpeg input of a variant: the URL itself, or the path of a local
+// file URL, whose escapes, such as the %20 of a space, ffmpeg's file protocol does not decode.
+func HlsVariantInput(variant HlsVariant) (string, error) {
+	uri, err := url.Parse(variant.Uri)
+	if err != nil {
+		return "", err
+	}
+	if uri.Scheme == "file" {
+		return uri.Path, nil
+	}
+	return variant.Uri, nil
+}
+
+// DownloadHlsVariant copies the video of a variant into outputFilename without re-encoding it.
+func DownloadHlsVariant(variant HlsVariant, outputFilename string) error {
+	input, err := HlsVariantInput(variant)
+	if err != nil {
+		return err
+	}
+	cmd := FfmpegCommand("-i", input, "-map", "0:v:0", "-c", "copy", outputFilename)
+	slog.Debug("Executing command", "cmd", cmd.String())
+	if output, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("%w: %s", err, strings.TrimSpace(string(output)))
+	}
+	return nil
+}
+
+// ScoreHlsVariants downloads and scores every variant against the reference. A variant that fails
+// is reported as a failed attempt rather than ending the run.
+func ScoreHlsVariants(referenceFilename string, referenceResolution Resolution, variants []HlsVariant) ([]ConvexHullPoint, []ConvexHullPoint) {
+	directory, err := ScratchDir("vmaf-hls")
+	if err != nil {
+		slog.Error("Error creating download directory", "err", err)
+		return nil, nil
+	}
+	defer os.RemoveAll(directory)
+
+	var measured, attempted []ConvexHullPoint
+	for i, variant := range variants {
+		point := ConvexHullPoint{Resolution: variant.Resolution, Rate: variant.Rate(), Status: PointOk}
+		variantFilename := filepath.Join(directory, fmt.Sprintf("variant%d.mkv", i))
+		if err := DownloadHlsVariant(variant, variantFilename); err != nil {
+			slog.Error("Error downloading variant", "uri", variant.Uri, "err", err)
+			point.Status, point.Reason = PointFailed, "download failed"
+			attempted = append(attempted, point)
+			continue
+		}
+		if point.Resolution == (Resolution{}) {
+			point.Resolution, _ = GetVideoResolutionAndBitrate(variantFilename)
+		}
+
+		point.VmafModel = SelectVmafModelFor(referenceFilename, referenceResolution, point.Resolution)
+		result := make(chan VmafMetrics, 1)
+		ComputeVmaf(referenceFilename, referenceResolution, variantFilename, point.Resolution, point.VmafModel, result)
+		RemovePrescaled(variantFilename)
+		metrics := <-result
+		if metrics.Mean < 0 {
+			point.Status, point.Reason = PointFailed, "VMAF failed"
+			attempted = append(attempted, point)
+			continue
+		}
+		point.VmafScore, point.VmafNegScore, point.VmafStdDev, point.VmafCi, point.VmafClamped, point.FrameCountMismatch = metrics.Mean, metrics.NegMean, metrics.StdDev, metrics.Ci, metrics.Clamped(), metrics.FrameCountMismatch
+		point.Chroma, point.DisplayResolution = metrics.Chroma, DisplayResolutionOf(point.Resolution)
+		attempted = append(attempted, point)
+
+		point.Status = ""
+		measured = append(measured, point)
+	}
+	return measured, attempted
+}
+
+// RunHlsCommand implements the hls subcommand: hls [-o out.json] <master.m3u8> <reference.mp4>. It
+// scores every rendition of a streaming service's ladder against our own master and reports the
+// hull those renditions imply.
+func RunHlsCommand(args []string) int {
+	flags := flag.NewFlagSet("hls", flag.ExitOnError)
+	outputFilename := flags.String("o", "", "write the implied hull as JSON to this file")
+	config.RegisterFlags(flags)
+	ParseFlags(flags, args)
+	if err := Preflight(false, true); err != nil {
+		slog.Error("Error checking ffmpeg", "err", err)
+		return 2
+	}
+	if !CheckScoringFlags() {
+		return 2
+	}
+	ApplyHwaccel()
+
+	if flags.NArg() != 2 {
+		fmt.Fprintf(os.Stderr, "Usage: %s hls [-o out.json] <master.m3u8 URL or file> <reference.mp4>\n", os.Args[0])
+		return 2
+	}
+	playlist, referenceFilename := flags.Arg(0), flags.Arg(1)
+
+	variants, err := ReadHlsMasterPlaylist(playlist)
+	if err != nil {
+		slog.Error("Error reading playlist", "playlist", playlist, "err", err)
+		return 1
+	}
+	for _, variant := range variants {
+		slog.Info("Variant", "resolution", variant.Resolution.ToFilterString(), "rate", variant.Rate(), "uri", variant.Uri)
+	}
+
+	vidioResolution, _ := GetVideoResolutionAndBitrate(referenceFilename)
+	stream, probeErr := SelectVideoStream(referenceFilename)
+	referenceResolution, _ := ReconcileResolution(referenceFilename, vidioResolution, stream, probeErr)
+	if referenceResolution.Height <= 0 || referenceResolution.Width <= 0 {
+		videoLog(referenceFilename).Error("Error reading resolution")
+		return 1
+	}
+	if probeErr == nil {
+		referenceResolution = EffectiveResolution(referenceResolution, stream)
+		if transfer := HdrTransfer(stream); transfer != "" {
+			RegisterHdrReference(referenceFilename, transfer)
+		}
+	}
+
+	runMetadata = NewRunMetadata()
+	measured, attempted := ScoreHlsVariants(referenceFilename, referenceResolution, variants)
+	convexHull := HullOfPoints(measured)
+	for _, point := range convexHull {
+		fmt.Printf("%s at %d kbps: VMAF %f\n", point.Resolution.ToFilterString(), point.Rate, point.VmafScore)
+	}
+
+	if *outputFilename != "" {
+		result := ConvexHullResult{Metadata: runMetadata, ConvexHull: convexHull, Knees: FindKnees(convexHull), Grid: measured, Attempts: attempted}
+		if err := WriteConvexHullToJson(result, *outputFilename); err != nil {
+			return 1
+		}
+	}
+	if len(measured) == 0 {
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,42 @@
+package main
+
+import (
+	"net/url"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+const masterPlaylist = `#EXTM3U
+#EXT-X-STREAM-INF:BANDWIDTH=6000000,AVERAGE-BANDWIDTH=4500000,RESOLUTION=1920x1080
+1080p/index.m3u8
+#EXT-X-STREAM-INF:BANDWIDTH=1500000,RESOLUTION=640x360
+360p%20low/index.m3u8
+`
+
+func TestHlsVariantInput(t *testing.T) {
+	directory := filepath.Join(t.TempDir(), "Season 1", "Episode #2")
+	for _, test := range []struct {
+		name string
+		base *url.URL
+		want []string
+	}{
+		{"local", &url.URL{Scheme: "file", Path: filepath.Join(directory, "master.m3u8")},
+			[]string{filepath.Join(directory, "1080p", "index.m3u8"), filepath.Join(directory, "360p low", "index.m3u8")}},
+		{"remote", &url.URL{Scheme: "https", Host: "cdn.example.com", Path: "/title/master.m3u8"},
+			[]string{"https://cdn.example.com/title/1080p/index.m3u8", "https://cdn.example.com/title/360p%20low/index.m3u8"}},
+	} {
+		variants, err := ParseHlsMasterPlaylist(strings.NewReader(masterPlaylist), test.base)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(variants) != 2 || variants[0].Bandwidth != 4500000 || variants[1].Resolution != (Resolution{Height: 360, Width: 640}) {
+			t.Fatalf("%s: got variants %+v", test.name, variants)
+		}
+		for i, variant := range variants {
+			if input, err := HlsVariantInput(variant); input != test.want[i] || err != nil {
+				t.Errorf("%s: %s is read from %q, %v, want %q", test.name, variant.Uri, input, err, test.want[i])
+			}
+		}
+	}
+}
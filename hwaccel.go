@@ -0,0 +1,70 @@
+package main
+
+import (
+	"log/slog"
+	"os/exec"
+	"strings"
+)
+
+// -hwaccel decodes every input, for the encodes, scoring, prescaling and clip extraction, on the
+// GPU. ffmpeg copies the decoded frames back to system memory for the filters, so the scale filters
+// and libvmaf read ordinary frames and scores are unchanged; the gain is the decode itself, which
+// dominates for 4K masters that are decoded twice per rate. Streams the hardware decoder does not
+// support fall back to software decoding inside ffmpeg, and an accelerator ffmpeg was not built with
+// is dropped at startup. Compare the WallSeconds and CpuSeconds of the encode and VMAF usage with
+// and without -hwaccel to measure the speedup on a given machine and catalog.
+//
+// -hwaccel_output_format is deliberately not set, which is what makes ffmpeg copy the frames back.
+// Frames kept in GPU memory would need a hwdownload ahead of every software filter, encoder and
+// libvmaf input, at each bit depth, and -s scaling has no filter graph to put it in. The copy is
+// therefore part of the measured cost, and GPU scaling or scoring such as scale_cuda and
+// libvmaf_cuda is not supported.
+
+// activeHwaccel is the accelerator every input is decoded with, or empty for software decoding.
+var activeHwaccel string
+
+// AvailableHwaccels returns the hardware accelerators ffmpeg was built with.
+func AvailableHwaccels() ([]string, error) {
+	output, err := exec.Command(config.FfmpegPath, "-hide_banner", "-hwaccels").Output()
+	if err != nil {
+		return nil, err
+	}
+	var hwaccels []string
+	for _, line := range strings.Split(string(output), "\n") {
+		line = strings.TrimSpace(line)
+		if line != "" && !strings.HasSuffix(line, ":") {
+			hwaccels = append(hwaccels, line)
+		}
+	}
+	return hwaccels, nil
+}
+
+// ApplyHwaccel enables config.Hwaccel when ffmpeg supports it and falls back to software decoding
+// otherwise.
+func ApplyHwaccel() {
+	activeHwaccel = ""
+	if config.Hwaccel == "" || config.Hwaccel == "none" {
+		return
+	}
+	hwaccels, err := AvailableHwaccels()
+	if err != nil {
+		slog.Error("Error listing ffmpeg hardware accelerators, decoding in software", "err", err)
+		return
+	}
+	for _, hwaccel := range hwaccels {
+		if hwaccel == config.Hwaccel {
+			activeHwaccel = hwaccel
+			slog.Info("Decoding inputs with hardware acceleration", "hwaccel", hwaccel)
+			return
+		}
+	}
+	slog.Warn("ffmpeg does not support -hwaccel, decoding in software", "hwaccel", config.Hwaccel, "supported", strings.Join(hwaccels, ","))
+}
+
+// HwaccelInputArgs returns the input options decoding the next input with the active accelerator.
+func HwaccelInputArgs() []string {
+	if activeHwaccel == "" {
+		return nil
+	}
+	return []string{"-hwaccel", activeHwaccel}
+}
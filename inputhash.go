@@ -0,0 +1,82 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+)
+
+// A source replaced under the same name would otherwise keep the hull of its old content forever,
+// since a hull that exists is skipped. -input-hash records a digest of every source in its hull,
+// and a rerun recomputes any hull whose source digest no longer matches. The partial digest covers
+// the size and the first and last MiB, enough to notice a re-encode or a retranscode of a catalog
+// asset without reading it; the full digest reads the whole file. Hulls recording no digest, such
+// as those written before -input-hash, are kept as they are, and videos listed in a
+// -completed-manifest are skipped without hashing.
+
+// Input digest modes of -input-hash.
+const (
+	PartialInputHash = "partial"
+	FullInputHash    = "full"
+)
+
+// partialHashBytes is how much of each end of a file the partial digest reads.
+const partialHashBytes = 1 << 20
+
+// InputDigest identifies the content of a source.
+type InputDigest struct {
+	Mode   string
+	Size   int64
+	Sha256 string
+}
+
+// ValidateInputHash checks -input-hash.
+func ValidateInputHash() error {
+	if config.InputHash != "" && config.InputHash != PartialInputHash && config.InputHash != FullInputHash {
+		return fmt.Errorf("unknown -input-hash %q, expected %s or %s", config.InputHash, PartialInputHash, FullInputHash)
+	}
+	return nil
+}
+
+// HashInput returns the digest of filename in the given mode.
+func HashInput(filename string, mode string) (InputDigest, error) {
+	file, err := os.Open(filename)
+	if err != nil {
+		return InputDigest{}, err
+	}
+	defer file.Close()
+	info, err := file.Stat()
+	if err != nil {
+		return InputDigest{}, err
+	}
+
+	hash := sha256.New()
+	switch {
+	case mode == FullInputHash || info.Size() <= 2*partialHashBytes:
+		_, err = io.Copy(hash, file)
+	default:
+		fmt.Fprintf(hash, "%d\n", info.Size())
+		if _, err = io.CopyN(hash, file, partialHashBytes); err == nil {
+			_, err = io.Copy(hash, io.NewSectionReader(file, info.Size()-partialHashBytes, partialHashBytes))
+		}
+	}
+	if err != nil {
+		return InputDigest{}, err
+	}
+	return InputDigest{Mode: mode, Size: info.Size(), Sha256: hex.EncodeToString(hash.Sum(nil))}, nil
+}
+
+// InputChanged reports whether filename no longer matches the digest a previous result recorded,
+// hashing it in the mode that result used. A result without a digest never counts as changed.
+func InputChanged(filename string, previous *InputDigest) (bool, error) {
+	if previous == nil {
+		return false, nil
+	}
+	current, err := HashInput(filename, previous.Mode)
+	if err != nil {
+		return false, err
+	}
+	return current != *previous, nil
+}
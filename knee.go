@@ -0,0 +1,64 @@
+package main
+
+import (
+	"math"
+)
+
+// KneePoint is the rate beyond which VMAF gains flatten on one resolution's rate-VMAF curve.
+type KneePoint struct {
+	Resolution Resolution
+	Rate       int
+	VmafScore  float64
+}
+
+// FindKnee returns the knee of a rate-VMAF curve: after normalizing both axes to 0-1, the point
+// furthest above the chord joining the lowest and highest rate. Curves with fewer than three points,
+// or without a point above the chord, have no knee.
+func FindKnee(curve []ConvexHullPoint) (ConvexHullPoint, bool) {
+	sorted := sortedByRate(curve)
+	if len(sorted) < 3 {
+		return ConvexHullPoint{}, false
+	}
+
+	first, last := sorted[0], sorted[len(sorted)-1]
+	rateSpan := float64(last.Rate - first.Rate)
+	vmafSpan := last.VmafScore - first.VmafScore
+	if rateSpan <= 0 || vmafSpan <= 0 {
+		return ConvexHullPoint{}, false
+	}
+
+	best, bestDistance := -1, 0.0
+	for i := 1; i < len(sorted)-1; i++ {
+		x := float64(sorted[i].Rate-first.Rate) / rateSpan
+		y := (sorted[i].VmafScore - first.VmafScore) / vmafSpan
+		// Distance above the chord y = x.
+		distance := (y - x) / math.Sqrt2
+		if distance > bestDistance {
+			best, bestDistance = i, distance
+		}
+	}
+	if best < 0 {
+		return ConvexHullPoint{}, false
+	}
+	return sorted[best], true
+}
+
+// FindKnees returns the knee of each resolution's curve within the hull, highest resolution first.
+func FindKnees(convexHull []ConvexHullPoint) []KneePoint {
+	curves := make(map[Resolution][]ConvexHullPoint)
+	var order []Resolution
+	for _, point := range convexHull {
+		if _, ok := curves[point.Resolution]; !ok {
+			order = append(order, point.Resolution)
+		}
+		curves[point.Resolution] = append(curves[point.Resolution], point)
+	}
+
+	var knees []KneePoint
+	for _, resolution := range order {
+		if knee, ok := FindKnee(curves[resolution]); ok {
+			knees = append(knees, KneePoint{Resolution: resolution, Rate: knee.Rate, VmafScore: knee.VmafScore})
+		}
+	}
+	return knees
+}
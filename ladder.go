@@ -0,0 +1,266 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// LadderPreset is a named set of candidate resolutions, highest first, with optional rate steps in
+// kbps that replace the linear target rates.
+type LadderPreset struct {
+	Description string
+	Resolutions []Resolution
+	Rates       []int
+}
+
+var ladderPresets = map[string]LadderPreset{
+	"default": {
+		Description: "16:9 ladder from 4320p down to 144p",
+		Resolutions: append([]Resolution(nil), resolutions...),
+	},
+	"apple-hls": {
+		Description: "Apple HLS authoring specification 16:9 ladder",
+		Resolutions: []Resolution{{1080, 1920}, {720, 1280}, {540, 960}, {432, 768}, {360, 640}, {270, 480}, {234, 416}},
+		Rates:       []int{7800, 6000, 4500, 3000, 2000, 1100, 730, 365, 145},
+	},
+	"uhd": {
+		Description: "16:9 ladder for 4K and 8K masters, from 4320p down to 360p",
+		Resolutions: []Resolution{{4320, 7680}, {2160, 3840}, {1440, 2560}, {1080, 1920}, {720, 1280}, {540, 960}, {360, 640}},
+	},
+	"youtube": {
+		Description: "YouTube upload resolutions",
+		Resolutions: []Resolution{{2160, 3840}, {1440, 2560}, {1080, 1920}, {720, 1280}, {480, 854}, {360, 640}, {240, 426}, {144, 256}},
+	},
+	"mobile": {
+		Description: "low resolution ladder for cellular delivery",
+		Resolutions: []Resolution{{720, 1280}, {540, 960}, {432, 768}, {360, 640}, {270, 480}, {180, 320}, {144, 256}},
+		Rates:       []int{3000, 2000, 1500, 1000, 750, 500, 300, 200, 100},
+	},
+}
+
+// ladderRates are the rate steps of -rates or of the active ladder, or nil for linear steps.
+var ladderRates []int
+
+// ParseResolution parses a resolution written as WIDTHxHEIGHT.
+func ParseResolution(value string) (Resolution, error) {
+	parts := strings.Split(strings.TrimSpace(value), "x")
+	if len(parts) != 2 {
+		return Resolution{}, fmt.Errorf("resolution %q is not of the form WIDTHxHEIGHT", value)
+	}
+	width, err := strconv.Atoi(parts[0])
+	if err != nil {
+		return Resolution{}, fmt.Errorf("resolution %q has an invalid width", value)
+	}
+	height, err := strconv.Atoi(parts[1])
+	if err != nil {
+		return Resolution{}, fmt.Errorf("resolution %q has an invalid height", value)
+	}
+	if width <= 0 || height <= 0 || width%2 != 0 || height%2 != 0 {
+		return Resolution{}, fmt.Errorf("resolution %q must have positive, even dimensions", value)
+	}
+	return Resolution{Height: height, Width: width}, nil
+}
+
+// ParseShortSide parses a resolution given by name, such as 720p, or as WIDTHxHEIGHT, and returns
+// its short side, which names it in either orientation.
+func ParseShortSide(value string) (int, error) {
+	if name := strings.TrimSpace(value); strings.HasSuffix(name, "p") {
+		shortSide, err := strconv.Atoi(strings.TrimSuffix(name, "p"))
+		if err != nil || shortSide <= 0 {
+			return 0, fmt.Errorf("resolution %q is not a name such as 720p", value)
+		}
+		return shortSide, nil
+	}
+	resolution, err := ParseResolution(value)
+	if err != nil {
+		return 0, err
+	}
+	return resolution.ShortSide(), nil
+}
+
+// maxResShortSide is the short side of -max-res, or zero when the ladder is not clipped from above.
+var maxResShortSide int
+
+// ApplyResolutionRange clips the ladder to the rungs from -max-res down to -min-res, inclusive. Both
+// must name a rung of the ladder. A source above -max-res is walked from the top of the clipped
+// ladder, see WalkStart.
+func ApplyResolutionRange() error {
+	bounds := []struct {
+		flag      string
+		value     string
+		shortSide int
+	}{{"-max-res", config.MaxRes, 0}, {"-min-res", config.MinRes, 0}}
+	for i := range bounds {
+		if bounds[i].value == "" {
+			continue
+		}
+		shortSide, err := ParseShortSide(bounds[i].value)
+		if err != nil {
+			return err
+		}
+		found := false
+		for _, rung := range resolutions {
+			if rung.ShortSide() == shortSide {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return fmt.Errorf("%s %s is not a resolution of the ladder", bounds[i].flag, bounds[i].value)
+		}
+		bounds[i].shortSide = shortSide
+	}
+	maxShortSide, minShortSide := bounds[0].shortSide, bounds[1].shortSide
+	if maxShortSide > 0 && maxShortSide < minShortSide {
+		return fmt.Errorf("-max-res %s is below -min-res %s", config.MaxRes, config.MinRes)
+	}
+
+	var clipped []Resolution
+	for _, rung := range resolutions {
+		if (maxShortSide == 0 || rung.ShortSide() <= maxShortSide) && rung.ShortSide() >= minShortSide {
+			clipped = append(clipped, rung)
+		}
+	}
+	resolutions = clipped
+	maxResShortSide = maxShortSide
+	return nil
+}
+
+// WalkStart returns the resolution the walk of a source starts from: the source resolution, or the
+// top of the ladder when -max-res clips it below the source.
+func WalkStart(referenceVideoResolution Resolution) Resolution {
+	if maxResShortSide > 0 && referenceVideoResolution.ShortSide() > maxResShortSide {
+		return LadderFor(referenceVideoResolution)[0]
+	}
+	return referenceVideoResolution
+}
+
+// ApplyLadder makes the named preset, or a comma separated list of WIDTHxHEIGHT resolutions, the
+// candidate resolutions of the walk.
+func ApplyLadder(value string) error {
+	if preset, ok := ladderPresets[value]; ok {
+		resolutions = append([]Resolution(nil), preset.Resolutions...)
+		ladderRates = preset.Rates
+		return nil
+	}
+	if !strings.Contains(value, "x") {
+		return fmt.Errorf("unknown ladder %q, expected one of %s or a list of WIDTHxHEIGHT", value, strings.Join(ladderNames(), ", "))
+	}
+
+	var ladder []Resolution
+	for _, entry := range strings.Split(value, ",") {
+		resolution, err := ParseResolution(entry)
+		if err != nil {
+			return err
+		}
+		ladder = append(ladder, resolution)
+	}
+	// GetNextResolution expects the ladder highest first.
+	sort.SliceStable(ladder, func(i, j int) bool { return ladder[i].Height > ladder[j].Height })
+	resolutions = ladder
+	ladderRates = nil
+	return nil
+}
+
+// ReadLadderFile reads a ladder like a preset from YAML: its resolutions as WIDTHxHEIGHT, in any
+// aspect ratio, and optionally the rate steps in kbps replacing the linear target rates.
+//
+//	description: 4:3 archive ladder
+//	resolutions: [1440x1080, 960x720, 640x480]
+//	rates: [5000, 2500, 1200, 600]
+func ReadLadderFile(filename string) (LadderPreset, error) {
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		return LadderPreset{}, err
+	}
+	var document struct {
+		Description string
+		Resolutions []string
+		Rates       []int
+	}
+	if err := yaml.Unmarshal(data, &document); err != nil {
+		return LadderPreset{}, err
+	}
+	if len(document.Resolutions) == 0 {
+		return LadderPreset{}, errors.New("ladder has no resolutions")
+	}
+
+	preset := LadderPreset{Description: document.Description}
+	seen := make(map[Resolution]bool)
+	for _, entry := range document.Resolutions {
+		resolution, err := ParseResolution(entry)
+		if err != nil {
+			return LadderPreset{}, err
+		}
+		if seen[resolution] {
+			return LadderPreset{}, fmt.Errorf("resolution %s is listed twice", entry)
+		}
+		seen[resolution] = true
+		preset.Resolutions = append(preset.Resolutions, resolution)
+	}
+	for _, rate := range document.Rates {
+		if rate <= 0 {
+			return LadderPreset{}, fmt.Errorf("rate %d is not a positive number of kbps", rate)
+		}
+		preset.Rates = append(preset.Rates, rate)
+	}
+	// GetNextResolution and targetRatesBetween expect both highest first.
+	sort.SliceStable(preset.Resolutions, func(i, j int) bool { return preset.Resolutions[i].Height > preset.Resolutions[j].Height })
+	sort.Sort(sort.Reverse(sort.IntSlice(preset.Rates)))
+	return preset, nil
+}
+
+// ApplyLadderFile makes the ladder of a -ladder-file the candidate resolutions of the walk.
+func ApplyLadderFile(filename string) error {
+	preset, err := ReadLadderFile(filename)
+	if err != nil {
+		return err
+	}
+	resolutions = preset.Resolutions
+	ladderRates = preset.Rates
+	return nil
+}
+
+func ladderNames() []string {
+	var names []string
+	for name := range ladderPresets {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// RunLaddersCommand implements the ladders subcommand, which lists the ladder presets.
+func RunLaddersCommand(args []string) int {
+	flags := flag.NewFlagSet("ladders", flag.ExitOnError)
+	flags.Parse(args)
+	if flags.NArg() != 0 {
+		fmt.Fprintf(os.Stderr, "Usage: %s ladders\n", os.Args[0])
+		return 2
+	}
+
+	for _, name := range ladderNames() {
+		preset := ladderPresets[name]
+		fmt.Printf("%s: %s\n", name, preset.Description)
+		var ladder []string
+		for _, resolution := range preset.Resolutions {
+			ladder = append(ladder, resolution.ToFilterString())
+		}
+		fmt.Printf("    resolutions: %s\n", strings.Join(ladder, " "))
+		if preset.Rates != nil {
+			var rates []string
+			for _, rate := range preset.Rates {
+				rates = append(rates, strconv.Itoa(rate))
+			}
+			fmt.Printf("    rates:       %s kbps\n", strings.Join(rates, " "))
+		}
+	}
+	return 0
+}
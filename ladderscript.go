@@ -0,0 +1,271 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log/slog"
+	"math"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// -ladder-script turns each hull into the production encodes it describes: one ffmpeg command per
+// hull point, encoding the whole asset with exactly the settings the point was measured with. A
+// path ending in .json gets the commands as JSON argument lists, any other path a shell script.
+// Both the script path and the encode outputs are templates; the encode output template adds the
+// rung placeholders below to those of -output-template.
+
+// rungTemplatePlaceholders are the placeholders -ladder-output may use besides outputTemplatePlaceholders.
+var rungTemplatePlaceholders = map[string]bool{
+	"width":     true, // width of the rung
+	"height":    true, // height of the rung
+	"rate":      true, // rate of the rung in kbps
+	"container": true, // container extension of the active codec
+}
+
+// LadderCommand is the production encode of one hull point, after FirstPassArgs for a two-pass
+// encoder.
+type LadderCommand struct {
+	Resolution    Resolution
+	Rate          int
+	Output        string
+	FirstPassArgs []string `json:",omitempty"`
+	Args          []string
+}
+
+// ValidateLadderScript checks the -ladder-script and -ladder-output templates.
+func ValidateLadderScript() error {
+	if config.LadderScript == "" {
+		return nil
+	}
+	if err := ValidateOutputTemplate(config.LadderScript); err != nil {
+		return err
+	}
+	for _, match := range outputTemplatePlaceholder.FindAllStringSubmatch(config.LadderOutput, -1) {
+		if !outputTemplatePlaceholders[match[1]] && !rungTemplatePlaceholders[match[1]] {
+			return fmt.Errorf("ladder output template %q has unknown placeholder {%s}", config.LadderOutput, match[1])
+		}
+	}
+	return nil
+}
+
+// ExpandRungTemplate returns the production encode output of videoFilename at the point.
+func ExpandRungTemplate(template string, videoFilename string, point ConvexHullPoint) string {
+	rungValues := map[string]string{
+		"width":     strconv.Itoa(point.Resolution.Width),
+		"height":    strconv.Itoa(point.Resolution.Height),
+		"rate":      strconv.Itoa(point.Rate),
+		"container": ActiveCodecProfile().Container,
+	}
+	template = outputTemplatePlaceholder.ReplaceAllStringFunc(template, func(placeholder string) string {
+		if value, ok := rungValues[strings.Trim(placeholder, "{}")]; ok {
+			return value
+		}
+		return placeholder
+	})
+	return ExpandOutputTemplate(template, videoFilename)
+}
+
+// LadderCommands returns the production encodes of the valid points of the hull.
+func LadderCommands(videoFilename string, convexHull []ConvexHullPoint) []LadderCommand {
+	var commands []LadderCommand
+	for _, point := range convexHull {
+		if point.VmafScore < 0 {
+			continue
+		}
+		output := ExpandRungTemplate(config.LadderOutput, videoFilename, point)
+		command := LadderCommand{Resolution: point.Resolution, Rate: point.Rate, Output: output, Args: BuildEncodeCommand(videoFilename, output, point.Resolution, point.Rate).Args}
+		if firstPass := BuildFirstPassCommand(videoFilename, output, point.Resolution, point.Rate); firstPass != nil {
+			command.FirstPassArgs = firstPass.Args
+		}
+		commands = append(commands, command)
+	}
+	return commands
+}
+
+// shellQuote quotes an argument for a POSIX shell when it needs it.
+func shellQuote(arg string) string {
+	if arg != "" && strings.Trim(arg, "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789-_./:=,+") == "" {
+		return arg
+	}
+	return "'" + strings.ReplaceAll(arg, "'", `'\''`) + "'"
+}
+
+// shellCommand quotes args into one command line.
+func shellCommand(args []string) string {
+	quoted := make([]string, len(args))
+	for i, arg := range args {
+		quoted[i] = shellQuote(arg)
+	}
+	return strings.Join(quoted, " ")
+}
+
+// WriteLadderScript writes the production encodes of the hull of videoFilename. hdrTransfer is the
+// transfer of an HDR source, registered here for the asset itself since the walk may have read a clip.
+func WriteLadderScript(videoFilename string, convexHull []ConvexHullPoint, hdrTransfer string) error {
+	if hdrTransfer != "" {
+		RegisterHdrReference(videoFilename, hdrTransfer)
+	}
+	commands := LadderCommands(videoFilename, convexHull)
+	scriptFilename := ExpandOutputTemplate(config.LadderScript, videoFilename)
+	if err := CreateOutputDirectory(scriptFilename); err != nil {
+		return err
+	}
+	if filepath.Ext(scriptFilename) == ".json" {
+		return WriteJson(commands, scriptFilename)
+	}
+
+	var script strings.Builder
+	script.WriteString("#!/bin/sh\n")
+	fmt.Fprintf(&script, "# Ladder of %s, %d rungs\n", videoFilename, len(commands))
+	script.WriteString("set -e\n")
+	for _, command := range commands {
+		if command.FirstPassArgs != nil {
+			script.WriteString(shellCommand(command.FirstPassArgs) + "\n")
+		}
+		script.WriteString(shellCommand(command.Args) + "\n")
+		if command.FirstPassArgs != nil {
+			script.WriteString(shellCommand([]string{"rm", "-f", PassLogFilename(command.Output)}) + "\n")
+		}
+	}
+	return os.WriteFile(scriptFilename, []byte(script.String()), 0755)
+}
+
+// SelectRungs returns at most count valid points of the hull, highest rate first, keeping its
+// highest and lowest rates and, between them, the points nearest to evenly spaced VMAF targets.
+// A count of zero keeps every valid point.
+func SelectRungs(convexHull []ConvexHullPoint, count int) []ConvexHullPoint {
+	var valid []ConvexHullPoint
+	for _, point := range sortedByRate(convexHull) {
+		if point.VmafScore >= 0 {
+			valid = append(valid, point)
+		}
+	}
+	if count <= 0 || len(valid) <= count {
+		return reverseRungs(valid)
+	}
+	if count == 1 {
+		return valid[len(valid)-1:]
+	}
+
+	low, high := valid[0].VmafScore, valid[len(valid)-1].VmafScore
+	selected := []int{0}
+	for n := 1; n < count-1; n++ {
+		target := low + (high-low)*float64(n)/float64(count-1)
+		nearest := -1
+		for i := selected[len(selected)-1] + 1; i < len(valid)-(count-1-n); i++ {
+			if nearest < 0 || math.Abs(valid[i].VmafScore-target) < math.Abs(valid[nearest].VmafScore-target) {
+				nearest = i
+			}
+		}
+		selected = append(selected, nearest)
+	}
+	selected = append(selected, len(valid)-1)
+
+	rungs := make([]ConvexHullPoint, len(selected))
+	for n, i := range selected {
+		rungs[n] = valid[i]
+	}
+	return reverseRungs(rungs)
+}
+
+// reverseRungs returns points in the opposite order.
+func reverseRungs(points []ConvexHullPoint) []ConvexHullPoint {
+	reversed := make([]ConvexHullPoint, len(points))
+	for i, point := range points {
+		reversed[len(points)-1-i] = point
+	}
+	return reversed
+}
+
+// RunLadderCommand implements the ladder subcommand: ladder [-rungs n] [-json out.json] <hull.json>.
+// It prints the rungs of the final ladder a hull selects.
+func RunLadderCommand(args []string) int {
+	flags := flag.NewFlagSet("ladder", flag.ExitOnError)
+	rungCount := flags.Int("rungs", 0, "most rungs to select, evenly spaced in VMAF; 0 keeps every hull point")
+	jsonFilename := flags.String("json", "", "also write the rungs as JSON to this file")
+	flags.Parse(args)
+
+	if flags.NArg() != 1 || *rungCount < 0 {
+		fmt.Fprintf(os.Stderr, "Usage: %s ladder [-rungs n] [-json out.json] <hull.json>\n", os.Args[0])
+		return 2
+	}
+	result, err := ReadConvexHullFromJson(flags.Arg(0))
+	if err != nil {
+		slog.Error("Error reading hull", "file", flags.Arg(0), "err", err)
+		return 1
+	}
+
+	rungs := SelectRungs(result.ConvexHull, *rungCount)
+	fmt.Printf("%8s  %11s  %10s\n", "kbps", "resolution", "VMAF")
+	for _, rung := range rungs {
+		fmt.Printf("%8d  %11s  %10.3f\n", rung.Rate, rung.Resolution.ToFilterString(), rung.VmafScore)
+	}
+	if *jsonFilename != "" {
+		if err := WriteJson(rungs, *jsonFilename); err != nil {
+			slog.Error("Error writing json file", "file", *jsonFilename, "err", err)
+			return 1
+		}
+	}
+	return 0
+}
+
+// RunApplyCommand implements the apply subcommand: apply [flags] <video> <hull.json>. It encodes
+// the renditions of the hull of video to -ladder-output with the flags of the run that measured it.
+func RunApplyCommand(args []string) int {
+	flags := flag.NewFlagSet("apply", flag.ExitOnError)
+	rungCount := flags.Int("rungs", 0, "most rungs to encode, evenly spaced in VMAF; 0 encodes every hull point")
+	config.RegisterFlags(flags)
+	ParseFlags(flags, args)
+	if flags.NArg() != 2 || *rungCount < 0 {
+		fmt.Fprintf(os.Stderr, "Usage: %s apply [-rungs n] [flags] <video> <hull.json>\n", os.Args[0])
+		return 2
+	}
+	removeTempDir := StartRun(false)
+	defer removeTempDir()
+	videoFilename := flags.Arg(0)
+
+	result, err := ReadConvexHullFromJson(flags.Arg(1))
+	if err != nil {
+		slog.Error("Error reading hull", "file", flags.Arg(1), "err", err)
+		return 1
+	}
+	if result.Metadata.Settings != nil && !reflect.DeepEqual(*result.Metadata.Settings, ActiveEncodeSettings()) {
+		videoLog(videoFilename).Warn("Hull was measured with other settings", "measured", fmt.Sprintf("%+v", *result.Metadata.Settings), "encoding", fmt.Sprintf("%+v", ActiveEncodeSettings()))
+	}
+	if result.HdrTransfer != "" {
+		RegisterHdrReference(videoFilename, result.HdrTransfer)
+	}
+	if result.FieldParity != "" && config.Deinterlace != "" {
+		RegisterInterlacedReference(videoFilename, result.FieldParity)
+	}
+
+	for _, command := range LadderCommands(videoFilename, SelectRungs(result.ConvexHull, *rungCount)) {
+		if err := CreateOutputDirectory(command.Output); err != nil {
+			slog.Error("Error creating output directory", "file", command.Output, "err", err)
+			return 1
+		}
+		if firstPass := BuildFirstPassCommand(videoFilename, command.Output, command.Resolution, command.Rate); firstPass != nil {
+			slog.Debug("Executing command", "cmd", firstPass.String())
+			_, err := RunMeasured(firstPass, ErrEncodeFailed)
+			if err != nil {
+				os.Remove(PassLogFilename(command.Output))
+				videoLog(videoFilename).Error("Error in the first pass", "output", command.Output, "err", err)
+				return 1
+			}
+		}
+		cmd := BuildEncodeCommand(videoFilename, command.Output, command.Resolution, command.Rate)
+		slog.Debug("Executing command", "cmd", cmd.String())
+		usage, err := RunMeasured(cmd, ErrEncodeFailed)
+		os.Remove(PassLogFilename(command.Output))
+		if err != nil {
+			videoLog(videoFilename).Error("Error encoding", "output", command.Output, "err", err)
+			return 1
+		}
+		videoLog(videoFilename).Info("Encoded", "resolution", command.Resolution.ToFilterString(), "rate", command.Rate, "output", command.Output, "seconds", usage.WallSeconds)
+	}
+	return 0
+}
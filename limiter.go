@@ -0,0 +1,71 @@
+package main
+
+import (
+	"sync"
+)
+
+// ReadLimiter caps how many ffmpeg processes read source files at once, both per source file and
+// across all sources. It is separate from any CPU limit because network storage saturates on
+// concurrent reads long before the CPU is busy. A nil ReadLimiter does not limit anything.
+type ReadLimiter struct {
+	mu        sync.Mutex
+	perSource int
+	sources   map[string]*sourceSlots
+	global    chan struct{}
+}
+
+type sourceSlots struct {
+	tokens chan struct{}
+	users  int
+}
+
+// NewReadLimiter returns a limiter allowing perSource concurrent readers of one file and global
+// concurrent readers overall. Zero disables the respective limit.
+func NewReadLimiter(perSource int, global int) *ReadLimiter {
+	limiter := &ReadLimiter{perSource: perSource, sources: make(map[string]*sourceSlots)}
+	if global > 0 {
+		limiter.global = make(chan struct{}, global)
+	}
+	return limiter
+}
+
+// Acquire blocks until filename may be read and returns the function that releases the slot.
+func (l *ReadLimiter) Acquire(filename string) func() {
+	if l == nil {
+		return func() {}
+	}
+
+	// Take the per-source slot first so waiting on a busy source does not hold a global slot.
+	var slots *sourceSlots
+	if l.perSource > 0 {
+		l.mu.Lock()
+		slots = l.sources[filename]
+		if slots == nil {
+			slots = &sourceSlots{tokens: make(chan struct{}, l.perSource)}
+			l.sources[filename] = slots
+		}
+		slots.users++
+		l.mu.Unlock()
+		slots.tokens <- struct{}{}
+	}
+	if l.global != nil {
+		l.global <- struct{}{}
+	}
+
+	return func() {
+		if l.global != nil {
+			<-l.global
+		}
+		if slots != nil {
+			<-slots.tokens
+			l.mu.Lock()
+			slots.users--
+			if slots.users == 0 {
+				delete(l.sources, filename)
+			}
+			l.mu.Unlock()
+		}
+	}
+}
+
+var readLimiter *ReadLimiter
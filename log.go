@@ -0,0 +1,47 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+)
+
+// Progress, warnings and errors are logged with log/slog to standard error, leaving standard
+// output to the reports of the subcommands, such as the dry run plan or the diff table. Records
+// about one video carry it as the video attribute, so the interleaved output of a batch can be
+// split per video. -verbose adds the debug records, such as every ffmpeg command run, -quiet keeps
+// only warnings and errors, and -log-format json writes one JSON object per record.
+
+const (
+	TextLogFormat = "text"
+	JsonLogFormat = "json"
+)
+
+// SetupLogging installs the default logger the -quiet, -verbose and -log-format flags describe.
+func SetupLogging() error {
+	if config.Quiet && config.Verbose {
+		return errors.New("-quiet and -verbose cannot be combined")
+	}
+	level := slog.LevelInfo
+	if config.Quiet {
+		level = slog.LevelWarn
+	} else if config.Verbose {
+		level = slog.LevelDebug
+	}
+	options := &slog.HandlerOptions{Level: level}
+	switch config.LogFormat {
+	case TextLogFormat:
+		slog.SetDefault(slog.New(slog.NewTextHandler(os.Stderr, options)))
+	case JsonLogFormat:
+		slog.SetDefault(slog.New(slog.NewJSONHandler(os.Stderr, options)))
+	default:
+		return fmt.Errorf("unknown log format %q, expected %s or %s", config.LogFormat, TextLogFormat, JsonLogFormat)
+	}
+	return nil
+}
+
+// videoLog returns the logger for records about videoFilename.
+func videoLog(videoFilename string) *slog.Logger {
+	return slog.With("video", videoFilename)
+}
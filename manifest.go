@@ -0,0 +1,114 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// Manifest asset statuses, in addition to the outcomes recorded in the run summary.
+const (
+	AssetPending = "pending"
+	AssetRunning = "running"
+)
+
+// ManifestEntry is the last known state of one asset of the batch.
+type ManifestEntry struct {
+	Status    string
+	Reason    string `json:",omitempty"`
+	UpdatedAt time.Time
+}
+
+// Manifest records the status of every asset of a batch so a batch that dies can be resumed,
+// including the assets that were running at the time. The file is rewritten atomically on every
+// update. A nil Manifest records nothing.
+type Manifest struct {
+	mu   sync.Mutex
+	path string
+	// Metadata describes the run that started the batch, so resuming with other versions is noticed.
+	Metadata *RunMetadata `json:",omitempty"`
+	Assets   map[string]ManifestEntry
+}
+
+var batchManifest *Manifest
+
+// NewManifest returns an empty manifest stored at path.
+func NewManifest(path string) *Manifest {
+	return &Manifest{path: path, Assets: make(map[string]ManifestEntry)}
+}
+
+// LoadManifest reads the manifest at path. A missing file yields an empty manifest.
+func LoadManifest(path string) (*Manifest, error) {
+	manifest := NewManifest(path)
+	byteValue, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return manifest, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(byteValue, manifest); err != nil {
+		return nil, err
+	}
+	if manifest.Assets == nil {
+		manifest.Assets = make(map[string]ManifestEntry)
+	}
+	return manifest, nil
+}
+
+// Schedule marks every video the manifest does not know yet as pending and returns the videos that
+// still need processing. Finished and skipped videos are left out; running, failed and pending ones,
+// which a crashed batch may have left behind, are processed again.
+func (m *Manifest) Schedule(videoFilenames []string) ([]string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var scheduled []string
+	for _, videoFilename := range videoFilenames {
+		entry, ok := m.Assets[videoFilename]
+		if !ok {
+			entry = ManifestEntry{Status: AssetPending, UpdatedAt: time.Now()}
+			m.Assets[videoFilename] = entry
+		}
+		if entry.Status != AssetDone && entry.Status != AssetSkipped {
+			scheduled = append(scheduled, videoFilename)
+		}
+	}
+	return scheduled, m.save()
+}
+
+// Update records the status of a video and rewrites the manifest.
+func (m *Manifest) Update(videoFilename string, status string, reason string) error {
+	if m == nil {
+		return nil
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.Assets[videoFilename] = ManifestEntry{Status: status, Reason: reason, UpdatedAt: time.Now()}
+	return m.save()
+}
+
+// save writes the manifest to a temporary file and renames it into place, so a crash never leaves
+// a truncated manifest behind.
+func (m *Manifest) save() error {
+	byteValue, err := json.MarshalIndent(m, "", "    ")
+	if err != nil {
+		return err
+	}
+	tempFile, err := os.CreateTemp(filepath.Dir(m.path), filepath.Base(m.path)+".tmp")
+	if err != nil {
+		return err
+	}
+	if _, err := tempFile.Write(byteValue); err != nil {
+		tempFile.Close()
+		os.Remove(tempFile.Name())
+		return err
+	}
+	if err := tempFile.Close(); err != nil {
+		os.Remove(tempFile.Name())
+		return err
+	}
+	return os.Rename(tempFile.Name(), m.path)
+}
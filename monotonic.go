@@ -0,0 +1,57 @@
+package main
+
+import "sort"
+
+// EnforceMonotonicResolution corrects a hull, highest rate first, so resolution never drops as the
+// rate rises, which a deployable ladder requires but noisy scores can violate when rates are walked
+// independently or a hull is computed over a grid. Going up in rate, a point below the resolution of
+// the point before it is replaced by the best measured point at its rate that is not, flagged with
+// ResolutionCorrected. When no such point was measured the rate is measured again at the resolution
+// of the point before it; only when that fails is the inverted point dropped, leaving the failed
+// attempt to show the missing rate.
+func EnforceMonotonicResolution(referenceVideoFilename string, referenceVideoResolution Resolution, hull []ConvexHullPoint, measured []ConvexHullPoint) []ConvexHullPoint {
+	ascending := append([]ConvexHullPoint(nil), hull...)
+	sort.SliceStable(ascending, func(i, j int) bool { return ascending[i].Rate < ascending[j].Rate })
+
+	var corrected []ConvexHullPoint
+	var minResolution Resolution
+	for _, point := range ascending {
+		if point.Resolution.Height < minResolution.Height {
+			replacement, ok := bestMeasuredAtRate(measured, point.Rate, minResolution.Height)
+			if !ok {
+				var err error
+				replacement, err = measuredOrMeasure(referenceVideoFilename, referenceVideoResolution, measured, OperatingPoint{Resolution: minResolution, Rate: point.Rate})
+				if err != nil {
+					videoLog(referenceVideoFilename).Warn("Dropping point below the resolution of a lower rate", "resolution", point.Resolution.ToFilterString(), "rate", point.Rate, "min_resolution", minResolution.ToFilterString(), "err", err)
+					continue
+				}
+			}
+			videoLog(referenceVideoFilename).Info("Replacing point to keep resolution monotonic", "resolution", point.Resolution.ToFilterString(), "rate", point.Rate, "replacement", replacement.Resolution.ToFilterString())
+			replacement.Profile, replacement.Level = point.Profile, point.Level
+			replacement.Status, replacement.Reason = "", ""
+			replacement.ResolutionCorrected = true
+			point = replacement
+		}
+		minResolution = point.Resolution
+		corrected = append(corrected, point)
+	}
+
+	for i := 0; i < len(corrected)/2; i++ {
+		corrected[i], corrected[len(corrected)-1-i] = corrected[len(corrected)-1-i], corrected[i]
+	}
+	return corrected
+}
+
+// bestMeasuredAtRate returns the best scoring measured point at rate with at least minHeight lines.
+func bestMeasuredAtRate(measured []ConvexHullPoint, rate int, minHeight int) (ConvexHullPoint, bool) {
+	best, found := ConvexHullPoint{}, false
+	for _, point := range measured {
+		if point.Rate != rate || point.Resolution.Height < minHeight || (point.Status != "" && point.Status != PointOk) {
+			continue
+		}
+		if !found || point.VmafScore > best.VmafScore {
+			best, found = point, true
+		}
+	}
+	return best, found
+}
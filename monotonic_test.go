@@ -0,0 +1,69 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+)
+
+// invertedHull is a hull, highest rate first, whose 3000 kbps point drops below the resolution of
+// the 2000 kbps point, as noisy scores of independently walked rates can.
+func invertedHull() []ConvexHullPoint {
+	hd, sd := Resolution{Height: 1080, Width: 1920}, Resolution{Height: 720, Width: 1280}
+	return []ConvexHullPoint{
+		{Resolution: hd, Rate: 4000, VmafScore: 96},
+		{Resolution: sd, Rate: 3000, VmafScore: 94},
+		{Resolution: hd, Rate: 2000, VmafScore: 90},
+		{Resolution: sd, Rate: 1000, VmafScore: 80},
+	}
+}
+
+func rungs(hull []ConvexHullPoint) string {
+	var rungs string
+	for _, point := range hull {
+		rungs += fmt.Sprintf("%d@%d ", point.Rate, point.Resolution.Height)
+	}
+	return rungs
+}
+
+func TestEnforceMonotonicResolution(t *testing.T) {
+	hd := Resolution{Height: 1080, Width: 1920}
+	reference := fmt.Sprintf("inverted-%s.mp4", t.Name())
+	defer TakeAttempts(reference)
+
+	for _, test := range []struct {
+		name     string
+		measured []ConvexHullPoint
+		measure  error
+		want     string
+		measures int
+	}{
+		{"measured", []ConvexHullPoint{{Resolution: hd, Rate: 3000, VmafScore: 93, Status: PointOk}}, nil, "4000@1080 3000@1080 2000@1080 1000@720 ", 0},
+		{"measured again", nil, nil, "4000@1080 3000@1080 2000@1080 1000@720 ", 1},
+		{"measurement failed", nil, errors.New("encode failed"), "4000@1080 2000@1080 1000@720 ", 1},
+	} {
+		measured := stubMeasurements(t, func(Resolution, int) float64 { return 92 })
+		inner := measureOperatingPoint
+		measureOperatingPoint = func(referenceVideoFilename string, referenceVideoResolution Resolution, point OperatingPoint) (ConvexHullPoint, error) {
+			measuredPoint, err := inner(referenceVideoFilename, referenceVideoResolution, point)
+			if test.measure != nil {
+				return ConvexHullPoint{}, test.measure
+			}
+			return measuredPoint, err
+		}
+
+		hull := EnforceMonotonicResolution(reference, hd, invertedHull(), test.measured)
+		if got := rungs(hull); got != test.want {
+			t.Errorf("%s: got %s, want %s", test.name, got, test.want)
+		}
+		if got := measured[measurement{reference, OperatingPoint{Resolution: hd, Rate: 3000}}]; got != test.measures || len(measured) != test.measures {
+			t.Errorf("%s: measured %v, want 3000 kbps at 1080p %d times", test.name, measured, test.measures)
+		}
+		for _, point := range hull {
+			if point.ResolutionCorrected != (point.Rate == 3000) {
+				t.Errorf("%s: %d kbps ResolutionCorrected is %v", test.name, point.Rate, point.ResolutionCorrected)
+			}
+		}
+		TakeAttempts(reference)
+	}
+}
@@ -0,0 +1,229 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"math"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// -clips samples a long asset with several short clips instead of analysing all of it. The clips
+// are extracted losslessly and joined into one reference, so the walk encodes and scores them as a
+// single asset, and the per-frame scores are split back into the clips afterwards. The pooled VMAF
+// of the joined reference is the mean over all frames, which is the mean of the clip scores weighted
+// by their frame counts. Encoding the clips as one stream lets rate control carry over between
+// clips, so each clip's score is an approximation of encoding it alone.
+
+// Clip placements.
+const (
+	EvenClipPlacement  = "even"
+	SceneClipPlacement = "scene"
+)
+
+// ClipScore is the VMAF of one clip of a multi-clip point.
+type ClipScore struct {
+	Start     float64
+	End       float64
+	Frames    int
+	VmafScore float64
+}
+
+// ClipRange locates one clip in a joined multi-clip reference.
+type ClipRange struct {
+	Start      float64
+	End        float64
+	FirstFrame int
+	Frames     int
+}
+
+// clipRanges maps each joined reference to the ranges of its clips.
+var clipRanges = struct {
+	sync.Mutex
+	byFilename map[string][]ClipRange
+}{byFilename: make(map[string][]ClipRange)}
+
+// ClipRangesOf returns the clip ranges of a joined reference, or nil for any other reference.
+func ClipRangesOf(referenceFilename string) []ClipRange {
+	clipRanges.Lock()
+	defer clipRanges.Unlock()
+	return clipRanges.byFilename[referenceFilename]
+}
+
+// ValidateClips checks the multi-clip settings and their combination with other frame selections,
+// which would renumber the frames the clip ranges refer to.
+func ValidateClips() error {
+	if config.Clips == 0 {
+		return nil
+	}
+	if config.Clips < 0 {
+		return fmt.Errorf("clip count %d must not be negative", config.Clips)
+	}
+	if config.ClipLength <= 0 {
+		return fmt.Errorf("clip length %g must be positive", config.ClipLength)
+	}
+	if config.ClipPlacement != EvenClipPlacement && config.ClipPlacement != SceneClipPlacement {
+		return fmt.Errorf("unknown -clip-placement %q, expected %s or %s", config.ClipPlacement, EvenClipPlacement, SceneClipPlacement)
+	}
+	if config.Clip != "" || config.VmafFrames != "" || config.VmafTime != "" || config.VmafSampling != AllFramesSampling || config.FrameOffset != 0 {
+		return errors.New("-clips cannot be combined with -clip, -vmaf-frames, -vmaf-time, -vmaf-sampling or -frame-offset")
+	}
+	return nil
+}
+
+// PlaceClips returns count clips of length seconds in an asset of duration seconds. Even placement
+// centres the clips in equal parts of the asset. Scene placement starts them at evenly spaced scene
+// changes, falling back to even placement when there are fewer scene changes than clips. Clips never
+// overlap and never extend past the end.
+func PlaceClips(duration float64, count int, length float64, sceneTimes []float64) []ScoringWindow {
+	if length*float64(count) >= duration {
+		return []ScoringWindow{{Unit: "seconds", Start: 0, End: duration}}
+	}
+	starts := make([]float64, count)
+	if len(sceneTimes) >= count {
+		for i := range starts {
+			starts[i] = sceneTimes[i*len(sceneTimes)/count]
+		}
+	} else {
+		for i := range starts {
+			starts[i] = duration*(float64(i)+0.5)/float64(count) - length/2
+		}
+	}
+	sort.Float64s(starts)
+
+	clips := make([]ScoringWindow, 0, count)
+	end := 0.0
+	for i, start := range starts {
+		// Leave room for the clips after this one.
+		latest := duration - length*float64(count-i)
+		start = math.Min(math.Max(start, end), latest)
+		clips = append(clips, ScoringWindow{Unit: "seconds", Start: start, End: start + length})
+		end = start + length
+	}
+	return clips
+}
+
+// ProbeFrameCount counts the video packets of filename, which for the intra-only intermediates is
+// the frame count without decoding them.
+func ProbeFrameCount(filename string) (int, error) {
+	output, err := FfprobeCommand("-v", "error", "-select_streams", "v:0", "-count_packets", "-show_entries", "stream=nb_read_packets", "-of", "default=noprint_wrappers=1:nokey=1", filename).Output()
+	if err != nil {
+		return 0, err
+	}
+	return strconv.Atoi(strings.TrimSpace(string(output)))
+}
+
+// ExtractClips extracts the configured clips of videoFilename, joins them into one reference in a
+// new temporary directory, and returns it with a function removing it.
+func ExtractClips(videoFilename string) (string, func(), error) {
+	duration, err := ProbeDuration(videoFilename)
+	if err != nil {
+		return "", nil, err
+	}
+	var sceneTimes []float64
+	if config.ClipPlacement == SceneClipPlacement {
+		sceneTimes, err = DetectSceneChanges(videoFilename, config.SceneThreshold)
+		if err != nil {
+			return "", nil, err
+		}
+	}
+	clips := PlaceClips(duration, config.Clips, config.ClipLength, sceneTimes)
+
+	directory, err := ScratchDir("vmaf-clips")
+	if err != nil {
+		return "", nil, err
+	}
+	cleanup := func() { os.RemoveAll(directory) }
+
+	var list strings.Builder
+	ranges := make([]ClipRange, len(clips))
+	firstFrame := 0
+	for i := range clips {
+		clipFilename := filepath.Join(directory, fmt.Sprintf("clip%d.mkv", i))
+		if err := extractWindow(videoFilename, clipFilename, &clips[i]); err != nil {
+			cleanup()
+			return "", nil, err
+		}
+		frames, err := ProbeFrameCount(clipFilename)
+		if err != nil {
+			cleanup()
+			return "", nil, err
+		}
+		ranges[i] = ClipRange{Start: clips[i].Start, End: clips[i].End, FirstFrame: firstFrame, Frames: frames}
+		firstFrame += frames
+		fmt.Fprintf(&list, "file '%s'\n", clipFilename)
+	}
+
+	listFilename := filepath.Join(directory, "clips.txt")
+	if err := os.WriteFile(listFilename, []byte(list.String()), 0666); err != nil {
+		cleanup()
+		return "", nil, err
+	}
+	base := strings.TrimSuffix(filepath.Base(videoFilename), filepath.Ext(videoFilename))
+	joinedFilename := filepath.Join(directory, base+".mkv")
+	cmd := FfmpegCommand("-f", "concat", "-safe", "0", "-i", listFilename, "-c", "copy", joinedFilename)
+	slog.Debug("Executing command", "cmd", cmd.String())
+	if _, err := RunMeasured(cmd, ErrEncodeFailed); err != nil {
+		cleanup()
+		return "", nil, err
+	}
+
+	clipRanges.Lock()
+	clipRanges.byFilename[joinedFilename] = ranges
+	clipRanges.Unlock()
+	return joinedFilename, func() {
+		clipRanges.Lock()
+		delete(clipRanges.byFilename, joinedFilename)
+		clipRanges.Unlock()
+		cleanup()
+	}, nil
+}
+
+// ClipScoreAccumulator sums per-frame scores into the clips they belong to.
+type ClipScoreAccumulator struct {
+	ranges []ClipRange
+	sums   []float64
+	counts []int
+}
+
+// NewClipScoreAccumulator returns an accumulator for ranges, or nil when there are none.
+func NewClipScoreAccumulator(ranges []ClipRange) *ClipScoreAccumulator {
+	if len(ranges) == 0 {
+		return nil
+	}
+	return &ClipScoreAccumulator{ranges: ranges, sums: make([]float64, len(ranges)), counts: make([]int, len(ranges))}
+}
+
+// Add records the score of one frame.
+func (accumulator *ClipScoreAccumulator) Add(frameNum int, vmaf float64) {
+	if accumulator == nil {
+		return
+	}
+	for i, clip := range accumulator.ranges {
+		if frameNum >= clip.FirstFrame && frameNum < clip.FirstFrame+clip.Frames {
+			accumulator.sums[i] += vmaf
+			accumulator.counts[i]++
+			return
+		}
+	}
+}
+
+// Scores returns the mean score of every clip.
+func (accumulator *ClipScoreAccumulator) Scores() []ClipScore {
+	if accumulator == nil {
+		return nil
+	}
+	scores := make([]ClipScore, len(accumulator.ranges))
+	for i, clip := range accumulator.ranges {
+		scores[i] = ClipScore{Start: clip.Start, End: clip.End, Frames: accumulator.counts[i]}
+		if accumulator.counts[i] > 0 {
+			scores[i].VmafScore = accumulator.sums[i] / float64(accumulator.counts[i])
+		}
+	}
+	return scores
+}
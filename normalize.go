@@ -0,0 +1,56 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+)
+
+// Absolute VMAF is not comparable across sources: the source scored against itself does not reach
+// exactly 100 once both inputs go through the scoring scale, and how far it falls short depends on
+// the content. -normalize-vmaf scores the source against itself once per asset, through the same
+// scaling, model and frame selection as the encodes, and expresses every score as a percentage of
+// that baseline:
+//
+//	normalized = min(100, 100 * raw / baseline)
+//
+// Raw scores are kept; the normalized score and the baseline are recorded next to them.
+
+// ValidateNormalizeVmaf checks that -normalize-vmaf is not combined with -frame-offset, which
+// shifts the encodes against the source and would misalign the source against itself.
+func ValidateNormalizeVmaf() error {
+	if config.NormalizeVmaf && config.FrameOffset != 0 {
+		return errors.New("it cannot be combined with -frame-offset")
+	}
+	return nil
+}
+
+// ScoreBaseline scores the reference against itself at the scoring resolution.
+func ScoreBaseline(referenceVideoFilename string, referenceVideoResolution Resolution) (float64, error) {
+	model := SelectVmafModelFor(referenceVideoFilename, referenceVideoResolution, referenceVideoResolution)
+	vmafResult := make(chan VmafMetrics, 1)
+	go ComputeVmaf(referenceVideoFilename, referenceVideoResolution, referenceVideoFilename, referenceVideoResolution, model, vmafResult)
+	metrics := <-vmafResult
+	RemovePrescaled(referenceVideoFilename)
+	if metrics.Mean < 0 {
+		return 0, fmt.Errorf("scoring the source against itself: %w", VmafFailure(metrics))
+	}
+	if metrics.Mean == 0 {
+		return 0, errors.New("the source scores 0 against itself")
+	}
+	videoLog(referenceVideoFilename).Info("Baseline VMAF against itself", "vmaf", metrics.Mean)
+	return metrics.Mean, nil
+}
+
+// NormalizeVmaf sets the normalized score of every valid point against the baseline.
+func NormalizeVmaf(points []ConvexHullPoint, baseline float64) {
+	for i := range points {
+		if (points[i].Status != "" && points[i].Status != PointOk) || points[i].VmafScore < 0 {
+			continue
+		}
+		normalized := 100 * points[i].VmafScore / baseline
+		if normalized > 100 {
+			normalized = 100
+		}
+		points[i].VmafNormalized = &normalized
+	}
+}
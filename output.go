@@ -0,0 +1,119 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+)
+
+var outputTemplatePlaceholder = regexp.MustCompile(`\{([^{}]*)\}`)
+
+// outputTemplatePlaceholders lists the placeholders an output template may use.
+var outputTemplatePlaceholders = map[string]bool{
+	"base":   true, // input file name without directory or extension
+	"codec":  true, // encoder used for the walk
+	"date":   true, // day the run started, as 20060102
+	"dir":    true, // directory of the input file
+	"ext":    true, // input file extension without the dot
+	"preset": true, // -preset of the walk
+	"rel":    true, // input path relative to -video-dir, without extension
+}
+
+// runStarted is when the run started, the {date} of its outputs.
+var runStarted = time.Now()
+
+// ValidateOutputTemplate reports an error for templates that are empty or use unknown placeholders.
+func ValidateOutputTemplate(template string) error {
+	if template == "" {
+		return fmt.Errorf("output template is empty")
+	}
+	for _, match := range outputTemplatePlaceholder.FindAllStringSubmatch(template, -1) {
+		if !outputTemplatePlaceholders[match[1]] {
+			return fmt.Errorf("output template %q has unknown placeholder {%s}", template, match[1])
+		}
+	}
+	return nil
+}
+
+// ValidateEncodeTemplate checks -encode-template. The encodes of a walk run side by side, so their
+// names must tell the resolutions and rates apart, and they are placed under the encode directory
+// of the video, so the template cannot choose a directory of its own.
+func ValidateEncodeTemplate() error {
+	template := config.EncodeTemplate
+	if template == "" {
+		return fmt.Errorf("encode template is empty")
+	}
+	if filepath.IsAbs(template) || strings.HasPrefix(filepath.Clean(template), "..") {
+		return fmt.Errorf("encode template %q must be a name relative to the encode directory", template)
+	}
+	used := make(map[string]bool)
+	for _, match := range outputTemplatePlaceholder.FindAllStringSubmatch(template, -1) {
+		if match[1] == "dir" || match[1] == "rel" {
+			return fmt.Errorf("encode template %q cannot choose the directory with {%s}", template, match[1])
+		}
+		if !outputTemplatePlaceholders[match[1]] && !rungTemplatePlaceholders[match[1]] {
+			return fmt.Errorf("encode template %q has unknown placeholder {%s}", template, match[1])
+		}
+		used[match[1]] = true
+	}
+	if !used["rate"] || !(used["width"] || used["height"]) {
+		return fmt.Errorf("encode template %q must include {rate} and {width} or {height}", template)
+	}
+	return nil
+}
+
+// ExpandOutputTemplate returns the output path for videoFilename.
+func ExpandOutputTemplate(template string, videoFilename string) string {
+	ext := filepath.Ext(videoFilename)
+	values := map[string]string{
+		"base":   strings.TrimSuffix(filepath.Base(videoFilename), ext),
+		"codec":  ActiveEncoder().Name(),
+		"date":   runStarted.Format("20060102"),
+		"dir":    filepath.Dir(videoFilename),
+		"ext":    strings.TrimPrefix(ext, "."),
+		"preset": config.Preset,
+		"rel":    strings.TrimSuffix(relativeVideoPath(videoFilename), ext),
+	}
+	return outputTemplatePlaceholder.ReplaceAllStringFunc(template, func(placeholder string) string {
+		return values[strings.Trim(placeholder, "{}")]
+	})
+}
+
+// CreateOutputDirectory creates the directory that will hold filename.
+func CreateOutputDirectory(filename string) error {
+	return os.MkdirAll(filepath.Dir(filename), 0755)
+}
+
+// relativeVideoPath returns videoFilename relative to config.VideoDirectory, or videoFilename itself when it lies outside it.
+func relativeVideoPath(videoFilename string) string {
+	rel, err := filepath.Rel(config.VideoDirectory, videoFilename)
+	if err != nil || strings.HasPrefix(rel, "..") {
+		return videoFilename
+	}
+	return rel
+}
+
+// FindOutputCollisions returns the output paths that more than one of the videos of the groups
+// would be written to, with the videos that collide on each, each group expanded with its overrides
+// applied. Same-named videos in different directories collide when the template does not include
+// {dir} or {rel}.
+func FindOutputCollisions(template string, groups []OverrideGroup) map[string][]string {
+	videosByOutput := make(map[string][]string)
+	EachOverrideGroup(groups, func(videoFilenames []string) {
+		for _, videoFilename := range videoFilenames {
+			output := filepath.Clean(ExpandOutputTemplate(template, videoFilename))
+			videosByOutput[output] = append(videosByOutput[output], videoFilename)
+		}
+	})
+
+	collisions := make(map[string][]string)
+	for output, videos := range videosByOutput {
+		if len(videos) > 1 {
+			collisions[output] = videos
+		}
+	}
+	return collisions
+}
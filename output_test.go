@@ -0,0 +1,74 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+// sameNamedVideos creates two videos named intro.mp4 in different directories of a video directory
+// and makes it -video-dir.
+func sameNamedVideos(t *testing.T) []string {
+	t.Helper()
+	previous := config
+	t.Cleanup(func() { config = previous })
+	config = DefaultConfig()
+	config.VideoDirectory = t.TempDir()
+	var videos []string
+	for _, directory := range []string{"news", "sport"} {
+		video := filepath.Join(config.VideoDirectory, directory, "intro.mp4")
+		if err := os.MkdirAll(filepath.Dir(video), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(video, nil, 0644); err != nil {
+			t.Fatal(err)
+		}
+		videos = append(videos, video)
+	}
+	return videos
+}
+
+func TestSameNamedVideosHaveDistinctPaths(t *testing.T) {
+	videos := sameNamedVideos(t)
+	config.EncodeDirectory = t.TempDir()
+
+	for _, template := range []string{"{dir}/{base}.json", filepath.Join(t.TempDir(), "{rel}.json")} {
+		if collisions := FindOutputCollisions(template, []OverrideGroup{{Videos: videos}}); len(collisions) > 0 {
+			t.Errorf("%s: outputs collide: %v", template, collisions)
+		}
+		if ExpandOutputTemplate(template, videos[0]) == ExpandOutputTemplate(template, videos[1]) {
+			t.Errorf("%s: both videos are written to %s", template, ExpandOutputTemplate(template, videos[0]))
+		}
+	}
+	rung := Resolution{720, 1280}
+	first, second := EncodedFilename(videos[0], rung, 3000), EncodedFilename(videos[1], rung, 3000)
+	if first == second {
+		t.Errorf("both videos are encoded to %s", first)
+	}
+	for _, encoded := range []string{first, second} {
+		if !withinDirectory(config.EncodeDirectory, encoded) {
+			t.Errorf("%s is not under -encode-dir %s", encoded, config.EncodeDirectory)
+		}
+	}
+}
+
+func TestSameNamedVideosCollideWithoutDirectory(t *testing.T) {
+	videos := sameNamedVideos(t)
+	template := filepath.Join(t.TempDir(), "{base}.json")
+	collisions := FindOutputCollisions(template, []OverrideGroup{{Videos: videos}})
+	expected := map[string][]string{filepath.Clean(ExpandOutputTemplate(template, videos[0])): videos}
+	if !reflect.DeepEqual(collisions, expected) {
+		t.Errorf("got collisions %v, expected %v", collisions, expected)
+	}
+}
+
+func TestWithoutExistingHullsIsPathAware(t *testing.T) {
+	videos := sameNamedVideos(t)
+	if err := os.WriteFile(HullFilename(videos[0]), []byte("{}"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if pending := WithoutExistingHulls(videos); !reflect.DeepEqual(pending, videos[1:]) {
+		t.Errorf("pending videos are %v, expected only %s", pending, videos[1])
+	}
+}
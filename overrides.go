@@ -0,0 +1,190 @@
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// VideoOverrides are the settings one video of a batch walks with in place of the run's, read from
+// the -overrides file. Empty and zero fields keep the run's setting. A codec override also drops
+// -preset, -tune, -encoder-args and -container, which suit the run's codec, for those of the
+// overriding codec's profile.
+type VideoOverrides struct {
+	Ladder  string `json:",omitempty"`
+	MinRate int    `json:",omitempty"`
+	MaxRate int    `json:",omitempty"`
+	Codec   string `json:",omitempty"`
+	MaxRes  string `json:",omitempty"`
+}
+
+// overrideColumns are the columns of a CSV -overrides file besides video, by the field they set.
+var overrideColumns = []string{"ladder", "min_rate", "max_rate", "codec", "max_res"}
+
+// ReadVideoOverrides reads an -overrides file: a JSON object from video to its overrides, or, for a
+// .csv file, a table with a video column and any of overrideColumns. Videos are named relative to
+// -video-dir, like the lines of -input-list, or by the path given as an argument.
+func ReadVideoOverrides(filename string) (map[string]VideoOverrides, error) {
+	overrides := make(map[string]VideoOverrides)
+	if filepath.Ext(filename) != ".csv" {
+		data, err := os.ReadFile(filename)
+		if err != nil {
+			return nil, err
+		}
+		if err := json.Unmarshal(data, &overrides); err != nil {
+			return nil, err
+		}
+		return overrides, nil
+	}
+
+	file, err := os.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	reader := csv.NewReader(file)
+	reader.TrimLeadingSpace = true
+	header, err := reader.Read()
+	if err != nil {
+		return nil, err
+	}
+	columns := make(map[string]int)
+	for i, name := range header {
+		columns[strings.ToLower(strings.TrimSpace(name))] = i
+	}
+	if _, ok := columns["video"]; !ok {
+		return nil, fmt.Errorf("header %q has no video column", strings.Join(header, ","))
+	}
+	for row := 2; ; row++ {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		cell := func(column string) string {
+			if i, ok := columns[column]; ok && i < len(record) {
+				return strings.TrimSpace(record[i])
+			}
+			return ""
+		}
+		override := VideoOverrides{Ladder: cell("ladder"), Codec: cell("codec"), MaxRes: cell("max_res")}
+		for _, rate := range []struct {
+			column string
+			value  *int
+		}{{"min_rate", &override.MinRate}, {"max_rate", &override.MaxRate}} {
+			if cell(rate.column) == "" {
+				continue
+			}
+			if *rate.value, err = strconv.Atoi(cell(rate.column)); err != nil {
+				return nil, fmt.Errorf("row %d: %s %q is not a number of kbps", row, rate.column, cell(rate.column))
+			}
+		}
+		overrides[cell("video")] = override
+	}
+	return overrides, nil
+}
+
+// OverrideGroup is the videos of a batch that walk with the same overrides.
+type OverrideGroup struct {
+	Overrides VideoOverrides
+	Videos    []string
+}
+
+// GroupByOverrides splits the videos into groups of equal overrides, in the order each group's
+// first video appears, so every group runs as its own batch with the overrides applied.
+func GroupByOverrides(videoFilenames []string, overrides map[string]VideoOverrides) []OverrideGroup {
+	byPath := make(map[string]VideoOverrides)
+	for video, override := range overrides {
+		byPath[filepath.Clean(video)] = override
+		byPath[filepath.Join(config.VideoDirectory, video)] = override
+	}
+	var groups []OverrideGroup
+	index := make(map[VideoOverrides]int)
+	for _, videoFilename := range videoFilenames {
+		override := byPath[filepath.Clean(videoFilename)]
+		i, ok := index[override]
+		if !ok {
+			i = len(groups)
+			index[override] = i
+			groups = append(groups, OverrideGroup{Overrides: override})
+		}
+		groups[i].Videos = append(groups[i].Videos, videoFilename)
+	}
+	return groups
+}
+
+// EachOverrideGroup calls f with the videos of each group while the group's overrides are applied, so
+// what is derived from the settings, such as the {codec} of an output path, is that of the group. A
+// group with invalid overrides is passed over, to be reported when the batch reaches it.
+func EachOverrideGroup(groups []OverrideGroup, f func(videoFilenames []string)) {
+	for _, group := range groups {
+		restore, err := ApplyVideoOverrides(group.Overrides)
+		if err != nil {
+			continue
+		}
+		f(group.Videos)
+		restore()
+	}
+}
+
+// ApplyVideoOverrides applies overrides to the configuration and the ladder derived from it, and
+// returns the function restoring the run's.
+func ApplyVideoOverrides(overrides VideoOverrides) (func(), error) {
+	savedConfig, savedResolutions, savedLadderRates, savedMaxRes := config, resolutions, ladderRates, maxResShortSide
+	savedSettings := runMetadata.Settings
+	restore := func() {
+		config, resolutions, ladderRates, maxResShortSide = savedConfig, savedResolutions, savedLadderRates, savedMaxRes
+		runMetadata.Settings = savedSettings
+	}
+	if overrides == (VideoOverrides{}) {
+		return restore, nil
+	}
+
+	if overrides.Ladder != "" {
+		config.Ladder, config.LadderFile = overrides.Ladder, ""
+	}
+	if overrides.MinRate != 0 {
+		config.MinRate = overrides.MinRate
+	}
+	if overrides.MaxRate != 0 {
+		config.MaxRate = overrides.MaxRate
+	}
+	if overrides.Codec != "" && overrides.Codec != config.Codec {
+		config.Codec = overrides.Codec
+		config.Preset, config.Tune, config.EncoderArgs, config.Container = "", "", "", ""
+	}
+	if overrides.MaxRes != "" {
+		config.MaxRes = overrides.MaxRes
+	}
+
+	// Rederive what the startup checks derived from the overridden settings, in the same order.
+	checks := []ConfigCheck{
+		{"ladder", func() error {
+			if config.LadderFile != "" {
+				return ApplyLadderFile(config.LadderFile)
+			}
+			return ApplyLadder(config.Ladder)
+		}},
+		{"max-res", ApplyResolutionRange},
+		{"codec", ValidateCodec},
+		{"rate range", ValidateRateBounds},
+		{"rates", ApplyRates},
+		{"encoder settings", ValidateEncoder},
+	}
+	for _, check := range checks {
+		if err := check.Check(); err != nil {
+			restore()
+			return nil, fmt.Errorf("override %s: %w", check.Name, err)
+		}
+	}
+	settings := ActiveEncodeSettings()
+	runMetadata.Settings = &settings
+	return restore, nil
+}
@@ -0,0 +1,55 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+// codecOverridden returns two videos of one directory, the second overridden to libx265, with
+// config writing each result to a template without the base name but with the {codec}.
+func codecOverridden(t *testing.T) ([]string, map[string]VideoOverrides) {
+	t.Helper()
+	previous, previousResolutions, previousRates := config, resolutions, ladderRates
+	t.Cleanup(func() { config, resolutions, ladderRates = previous, previousResolutions, previousRates })
+	config = DefaultConfig()
+	directory := t.TempDir()
+	config.OutputTemplate = filepath.Join(directory, "hull.{codec}.json")
+	videos := []string{filepath.Join(directory, "film.mov"), filepath.Join(directory, "trailer.mov")}
+	return videos, map[string]VideoOverrides{videos[1]: {Codec: "libx265"}}
+}
+
+func TestOutputCollisionsWithOverrides(t *testing.T) {
+	videos, overrides := codecOverridden(t)
+	if collisions := FindOutputCollisions(config.OutputTemplate, GroupByOverrides(videos, overrides)); len(collisions) > 0 {
+		t.Errorf("videos of different codecs collide: %v", collisions)
+	}
+	want := map[string][]string{filepath.Join(filepath.Dir(videos[0]), "hull.libx264.json"): videos}
+	if collisions := FindOutputCollisions(config.OutputTemplate, GroupByOverrides(videos, nil)); !reflect.DeepEqual(collisions, want) {
+		t.Errorf("got collisions %v, want %v", collisions, want)
+	}
+	if config.Codec != "libx264" {
+		t.Errorf("the overrides are left applied: -codec %s", config.Codec)
+	}
+}
+
+func TestSkipExistingWithOverrides(t *testing.T) {
+	videos, overrides := codecOverridden(t)
+	// Only the overridden video has its result written, under its own codec.
+	if err := os.WriteFile(filepath.Join(filepath.Dir(videos[0]), "hull.libx265.json"), []byte("{}"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	if pending := WithoutExistingHullsOverridden(videos, overrides); !reflect.DeepEqual(pending, videos[:1]) {
+		t.Errorf("got pending %v, want %v", pending, videos[:1])
+	}
+	if pending := WithoutExistingHullsOverridden(videos, nil); !reflect.DeepEqual(pending, videos) {
+		t.Errorf("without overrides got pending %v, want %v", pending, videos)
+	}
+	// A group whose overrides are invalid is left for the batch to report.
+	invalid := map[string]VideoOverrides{videos[1]: {Codec: "libx266"}}
+	if pending := WithoutExistingHullsOverridden(videos, invalid); !reflect.DeepEqual(pending, videos) {
+		t.Errorf("with invalid overrides got pending %v, want %v", pending, videos)
+	}
+}
@@ -0,0 +1,82 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os/exec"
+	"strings"
+)
+
+// Every VMAF model is trained on luma only: the chroma planes are never read, so chroma artifacts
+// such as colour bleeding or banding in the chroma planes do not lower the score. -vmaf-planes
+// chroma keeps the VMAF score as it is and adds libvmaf feature extractors that do read chroma:
+// per-plane PSNR, which reports the Cb and Cr planes separately from luma, and CIEDE2000, a colour
+// difference over all three planes where lower is better. Both are computed in the same pass.
+
+// Plane configurations.
+const (
+	LumaPlanes   = "luma"
+	ChromaPlanes = "chroma"
+)
+
+// chromaFeatures are the libvmaf features added for ChromaPlanes.
+var chromaFeatures = []string{"psnr", "ciede"}
+
+// ChromaMetrics are the pooled chroma-aware metrics of a scoring pass with -vmaf-planes chroma.
+type ChromaMetrics struct {
+	PsnrY     float64
+	PsnrCb    float64
+	PsnrCr    float64
+	Ciede2000 float64
+}
+
+// ValidateVmafPlanes checks -vmaf-planes. Chroma metrics need the feature option of the libvmaf
+// filter, which older ffmpeg builds do not have, so the installed filter is asked for it.
+func ValidateVmafPlanes() error {
+	switch config.VmafPlanes {
+	case LumaPlanes:
+		return nil
+	case ChromaPlanes:
+	default:
+		return fmt.Errorf("unknown -vmaf-planes %q, expected %s or %s", config.VmafPlanes, LumaPlanes, ChromaPlanes)
+	}
+	output, err := exec.Command(config.FfmpegPath, "-hide_banner", "-h", "filter=libvmaf").Output()
+	if err != nil {
+		return fmt.Errorf("querying the libvmaf filter: %w", err)
+	}
+	for _, line := range strings.Split(string(output), "\n") {
+		if fields := strings.Fields(line); len(fields) > 0 && fields[0] == "feature" {
+			return nil
+		}
+	}
+	return errors.New("the installed libvmaf filter has no feature option for chroma metrics")
+}
+
+// VmafFeatureFilterOption returns the libvmaf filter option adding the features of the plane
+// configuration, or an empty string for luma.
+func VmafFeatureFilterOption() string {
+	if config.VmafPlanes != ChromaPlanes {
+		return ""
+	}
+	var features []string
+	for _, feature := range chromaFeatures {
+		features = append(features, "name="+feature)
+	}
+	return "feature=" + strings.Join(features, "|")
+}
+
+// chromaMetricsFrom returns the chroma metrics among the pooled metrics of a log, or nil when the
+// log has none.
+func chromaMetricsFrom(pooledMetrics map[string]vmafLogPooledMetric) *ChromaMetrics {
+	cb, hasCb := pooledMetrics["psnr_cb"]
+	cr, hasCr := pooledMetrics["psnr_cr"]
+	if !hasCb || !hasCr {
+		return nil
+	}
+	return &ChromaMetrics{
+		PsnrY:     pooledMetrics["psnr_y"].Mean,
+		PsnrCb:    cb.Mean,
+		PsnrCr:    cr.Mean,
+		Ciede2000: pooledMetrics["ciede2000"].Mean,
+	}
+}
@@ -0,0 +1,64 @@
+package main
+
+import (
+	"path/filepath"
+	"strings"
+)
+
+// VegaLiteFilename returns the path of the plot spec written next to the hull at convexHullFilename.
+func VegaLiteFilename(convexHullFilename string) string {
+	return strings.TrimSuffix(convexHullFilename, filepath.Ext(convexHullFilename)) + ".vl.json"
+}
+
+// VegaLiteSpec returns a self-contained Vega-Lite spec plotting VMAF over bitrate, with the hull
+// drawn as a line and its points colored by resolution.
+func VegaLiteSpec(title string, convexHull []ConvexHullPoint) map[string]interface{} {
+	values := make([]map[string]interface{}, 0, len(convexHull))
+	for _, point := range convexHull {
+		values = append(values, map[string]interface{}{
+			"rate":       point.Rate,
+			"vmaf":       point.VmafScore,
+			"resolution": point.Resolution.ToFilterString(),
+			"height":     point.Resolution.Height,
+		})
+	}
+
+	x := map[string]interface{}{"field": "rate", "type": "quantitative", "title": "Bitrate (kbps)"}
+	y := map[string]interface{}{"field": "vmaf", "type": "quantitative", "title": "VMAF", "scale": map[string]interface{}{"zero": false}}
+	return map[string]interface{}{
+		"$schema": "https://vega.github.io/schema/vega-lite/v5.json",
+		"title":   title,
+		"width":   640,
+		"height":  400,
+		"data":    map[string]interface{}{"values": values},
+		"layer": []interface{}{
+			map[string]interface{}{
+				"mark":     map[string]interface{}{"type": "line", "color": "#444444"},
+				"encoding": map[string]interface{}{"x": x, "y": y, "order": map[string]interface{}{"field": "rate"}},
+			},
+			map[string]interface{}{
+				"mark": map[string]interface{}{"type": "point", "filled": true, "size": 80},
+				"encoding": map[string]interface{}{
+					"x": x,
+					"y": y,
+					"color": map[string]interface{}{
+						"field": "resolution",
+						"type":  "nominal",
+						"sort":  map[string]interface{}{"field": "height", "order": "descending"},
+						"title": "Resolution",
+					},
+					"tooltip": []interface{}{
+						map[string]interface{}{"field": "resolution", "type": "nominal"},
+						map[string]interface{}{"field": "rate", "type": "quantitative"},
+						map[string]interface{}{"field": "vmaf", "type": "quantitative", "format": ".2f"},
+					},
+				},
+			},
+		},
+	}
+}
+
+// WriteVegaLiteSpec writes the plot spec of a hull next to it.
+func WriteVegaLiteSpec(videoFilename string, convexHull []ConvexHullPoint, convexHullFilename string) error {
+	return WriteJson(VegaLiteSpec(filepath.Base(videoFilename), convexHull), VegaLiteFilename(convexHullFilename))
+}
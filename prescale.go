@@ -0,0 +1,62 @@
+package main
+
+import (
+	"log/slog"
+	"os"
+	"os/exec"
+	"time"
+)
+
+// Scoring scales the distorted encode to the scoring resolution inside the libvmaf filter graph,
+// so scoring one encode several times repeats the scale each time. With -prescale the encode
+// is scaled once to a lossless intermediate that every scoring pass reads instead, and the scoring
+// graph no longer scales it.
+
+// PrescaledFilename returns the lossless intermediate holding testFilename scaled to the scoring resolution.
+func PrescaledFilename(testFilename string) string {
+	return testFilename + ".prescaled.mkv"
+}
+
+// ScoredFilename returns the file scored in place of testFilename.
+func ScoredFilename(testFilename string) string {
+	if config.Prescale {
+		return PrescaledFilename(testFilename)
+	}
+	return testFilename
+}
+
+// BuildPrescaleCommand returns the ffmpeg command that losslessly scales testFilename to the scoring resolution.
+func BuildPrescaleCommand(testFilename string, referenceResolution Resolution, testResolution Resolution) *exec.Cmd {
+	args := SourceInputArgs(testFilename)
+	if scale := InputScaleFilter(testResolution, ScoringResolution(referenceResolution, testResolution), config.ScaleDistorted); scale != "" {
+		args = append(args, "-vf", scale)
+	}
+	args = append(args, "-c:v", "ffv1", PrescaledFilename(testFilename))
+	return FfmpegCommand(args...)
+}
+
+// PrescaleVideo creates the pre-scaled intermediate of testFilename unless it already exists, and
+// returns the time that took.
+func PrescaleVideo(testFilename string, referenceResolution Resolution, testResolution Resolution) (ProcessUsage, error) {
+	if _, err := os.Stat(PrescaledFilename(testFilename)); err == nil {
+		return ProcessUsage{}, nil
+	}
+
+	cmd := BuildPrescaleCommand(testFilename, referenceResolution, testResolution)
+	slog.Debug("Executing command", "cmd", cmd.String())
+	usage, err := RunMeasured(cmd, ErrVmafFailed)
+	summary.AddVmafUsage(usage)
+	if err != nil {
+		os.Remove(PrescaledFilename(testFilename))
+		return usage, err
+	}
+	slog.Info("Prescaled", "file", testFilename, "took", time.Duration(usage.WallSeconds*float64(time.Second)))
+	return usage, nil
+}
+
+// RemovePrescaled deletes the pre-scaled intermediate of testFilename, if any.
+func RemovePrescaled(testFilename string) {
+	if config.Prescale {
+		RemoveIntermediate(PrescaledFilename(testFilename))
+	}
+}
@@ -0,0 +1,242 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strconv"
+	"strings"
+)
+
+// ProbeStream is the subset of ffprobe's stream description the tool uses.
+type ProbeStream struct {
+	Index          int    `json:"index"`
+	CodecType      string `json:"codec_type"`
+	CodecName      string `json:"codec_name"`
+	Width          int    `json:"width"`
+	Height         int    `json:"height"`
+	ColorSpace     string `json:"color_space"`
+	ColorPrimaries string `json:"color_primaries"`
+	ColorTransfer  string `json:"color_transfer"`
+	FieldOrder     string `json:"field_order"`
+	NbFrames       string `json:"nb_frames"`
+	BitRate        string `json:"bit_rate"`
+
+	AvgFrameRate string `json:"avg_frame_rate"`
+	RFrameRate   string `json:"r_frame_rate"`
+
+	SampleAspectRatio  string `json:"sample_aspect_ratio"`
+	DisplayAspectRatio string `json:"display_aspect_ratio"`
+
+	Disposition  map[string]int    `json:"disposition"`
+	Tags         map[string]string `json:"tags"`
+	SideDataList []ProbeSideData   `json:"side_data_list"`
+}
+
+// ProbeSideData is a side data entry of a stream, such as the display matrix.
+type ProbeSideData struct {
+	SideDataType string  `json:"side_data_type"`
+	Rotation     float64 `json:"rotation"`
+}
+
+func parseFloatOrZero(value string) float64 {
+	parsed, err := strconv.ParseFloat(value, 64)
+	if err != nil {
+		return 0
+	}
+	return parsed
+}
+
+// FrameCount returns the number of frames in the stream header, or zero when the container does not record it.
+func (stream ProbeStream) FrameCount() int {
+	frames, err := strconv.Atoi(stream.NbFrames)
+	if err != nil {
+		return 0
+	}
+	return frames
+}
+
+// Source resolution provenances.
+const (
+	ResolutionFromFfprobe = "ffprobe"
+	ResolutionFromVidio   = "vidio"
+)
+
+// resolutionTolerance is the difference in pixels between the Vidio and ffprobe resolutions taken
+// to be rounding, such as to even dimensions, rather than a disagreement.
+const resolutionTolerance = 2
+
+// ReconcileResolution returns the source resolution and where it came from. ffprobe's frame
+// dimensions are preferred; Vidio's are used when the stream could not be probed. A difference
+// beyond rounding is reported with both values and the aspect ratio and rotation metadata, which
+// usually explain it.
+func ReconcileResolution(filename string, vidio Resolution, stream ProbeStream, probeErr error) (Resolution, string) {
+	if probeErr != nil || stream.Width <= 0 || stream.Height <= 0 {
+		return vidio, ResolutionFromVidio
+	}
+	probed := Resolution{Width: stream.Width, Height: stream.Height}
+	if vidio.Width > 0 && vidio.Height > 0 && (IntAbs(probed.Width-vidio.Width) > resolutionTolerance || IntAbs(probed.Height-vidio.Height) > resolutionTolerance) {
+		videoLog(filename).Warn("Vidio and ffprobe read different resolutions, using ffprobe", "vidio", vidio.ToFilterString(), "ffprobe", probed.ToFilterString(),
+			"sar", stream.SampleAspectRatio, "dar", stream.DisplayAspectRatio, "rotation", stream.Rotation())
+	}
+	return probed, ResolutionFromFfprobe
+}
+
+type probeOutput struct {
+	Streams []ProbeStream `json:"streams"`
+}
+
+// ProbeVideoStream returns ffprobe's description of the selected video stream of filename, see stream.go.
+func ProbeVideoStream(filename string) (ProbeStream, error) {
+	selector := fmt.Sprintf("v:%d", VideoStreamOf(filename))
+	output, err := FfprobeCommand("-v", "error", "-select_streams", selector, "-show_streams", "-of", "json", filename).Output()
+	if err != nil {
+		return ProbeStream{}, ClassifyFfmpegError(err, stderrOf(err), ErrProbeFailed)
+	}
+
+	var probe probeOutput
+	if err := json.Unmarshal(output, &probe); err != nil {
+		return ProbeStream{}, fmt.Errorf("%w: %s", ErrProbeFailed, err.Error())
+	}
+	if len(probe.Streams) == 0 {
+		return ProbeStream{}, fmt.Errorf("%w: no video stream", ErrProbeFailed)
+	}
+	return probe.Streams[0], nil
+}
+
+// ProbeDuration returns the container duration of filename in seconds.
+func ProbeDuration(filename string) (float64, error) {
+	output, err := FfprobeCommand("-v", "error", "-show_entries", "format=duration", "-of", "default=noprint_wrappers=1:nokey=1", filename).Output()
+	if err != nil {
+		return 0, ClassifyFfmpegError(err, stderrOf(err), ErrProbeFailed)
+	}
+	duration, err := strconv.ParseFloat(strings.TrimSpace(string(output)), 64)
+	if err != nil {
+		return 0, fmt.Errorf("%w: %s", ErrProbeFailed, err.Error())
+	}
+	return duration, nil
+}
+
+// EncodeTiming is what the achieved rate of an encode is computed from, recorded with each point so
+// the rate axis of the hull can be audited. Bytes counts the packets of the video stream alone, so
+// container overhead and any -async audio are left out. A constant frame rate stream lasts exactly
+// Frames over its frame rate; the container duration of a variable frame rate or trimmed stream
+// can be off by a frame duration or an edit list, so the stream's own duration is used instead,
+// and the container's only when the stream has none. The achieved rate of a CFR encode is
+// therefore exact, typically within a few percent of the target for a two-pass encode.
+type EncodeTiming struct {
+	AchievedRate int
+	Duration     float64
+	Frames       int
+	Bytes        int64
+}
+
+// encodeTimingProbe is the part of ffprobe's output ProbeEncodeTiming reads.
+type encodeTimingProbe struct {
+	Packets []struct {
+		Size string `json:"size"`
+	} `json:"packets"`
+	Streams []struct {
+		AvgFrameRate string `json:"avg_frame_rate"`
+		RFrameRate   string `json:"r_frame_rate"`
+		Duration     string `json:"duration"`
+	} `json:"streams"`
+	Format struct {
+		Duration string `json:"duration"`
+	} `json:"format"`
+}
+
+// ProbeEncodeTiming reads the frames, video bytes and duration of the first video stream of filename.
+func ProbeEncodeTiming(filename string) (EncodeTiming, error) {
+	output, err := FfprobeCommand("-v", "error", "-select_streams", "v:0", "-show_entries", "packet=size:stream=avg_frame_rate,r_frame_rate,duration:format=duration", "-of", "json", filename).Output()
+	if err != nil {
+		return EncodeTiming{}, ClassifyFfmpegError(err, stderrOf(err), ErrProbeFailed)
+	}
+	var probe encodeTimingProbe
+	if err := json.Unmarshal(output, &probe); err != nil {
+		return EncodeTiming{}, fmt.Errorf("%w: %s", ErrProbeFailed, err.Error())
+	}
+	if len(probe.Streams) == 0 {
+		return EncodeTiming{}, fmt.Errorf("%w: no video stream", ErrProbeFailed)
+	}
+
+	timing := EncodeTiming{Frames: len(probe.Packets)}
+	for _, packet := range probe.Packets {
+		size, err := strconv.ParseInt(packet.Size, 10, 64)
+		if err != nil {
+			return EncodeTiming{}, fmt.Errorf("%w: packet size %q", ErrProbeFailed, packet.Size)
+		}
+		timing.Bytes += size
+	}
+	stream := probe.Streams[0]
+	if stream.AvgFrameRate == stream.RFrameRate {
+		if frameRate, err := ParseFrameRate(stream.AvgFrameRate); err == nil && timing.Frames > 0 {
+			timing.Duration = float64(timing.Frames) / frameRate
+		}
+	}
+	if timing.Duration <= 0 {
+		timing.Duration = parseFloatOrZero(stream.Duration)
+	}
+	if timing.Duration <= 0 {
+		timing.Duration = parseFloatOrZero(probe.Format.Duration)
+	}
+	if timing.Duration <= 0 {
+		return EncodeTiming{}, errors.New("duration is not positive")
+	}
+	timing.AchievedRate = int(float64(timing.Bytes) * 8 / timing.Duration / 1000)
+	return timing, nil
+}
+
+// ProbeEncodeTimingOrNil returns the timing of an encode, or nil with the error logged when it cannot
+// be probed, which leaves the point without one rather than failing it.
+func ProbeEncodeTimingOrNil(filename string) *EncodeTiming {
+	timing, err := ProbeEncodeTiming(filename)
+	if err != nil {
+		slog.Error("Error probing timing", "file", filename, "err", err)
+		return nil
+	}
+	return &timing
+}
+
+// AchievedRate returns the average video bitrate of filename in kbps, see EncodeTiming.
+func AchievedRate(filename string) (int, error) {
+	timing, err := ProbeEncodeTiming(filename)
+	return timing.AchievedRate, err
+}
+
+// ProbeFormatBitRate returns the overall bitrate of filename in kbps as recorded by the container.
+func ProbeFormatBitRate(filename string) (int, error) {
+	output, err := FfprobeCommand("-v", "error", "-show_entries", "format=bit_rate", "-of", "default=noprint_wrappers=1:nokey=1", filename).Output()
+	if err != nil {
+		return 0, err
+	}
+	bitRate, err := strconv.Atoi(strings.TrimSpace(string(output)))
+	if err != nil {
+		return 0, err
+	}
+	return bitRate / 1000, nil
+}
+
+// ErrUnknownSourceRate is returned when no rate can be determined for a source.
+var ErrUnknownSourceRate = errors.New("source bitrate is unknown, pass -source-bitrate")
+
+// FallbackSourceRate determines the rate of a source Vidio could not read in kbps: the video stream
+// bitrate, the container bitrate, the size over the duration, and finally -source-bitrate.
+func FallbackSourceRate(filename string) (int, error) {
+	if stream, err := ProbeVideoStream(filename); err == nil {
+		if bitRate, err := strconv.Atoi(stream.BitRate); err == nil && bitRate/1000 > 0 {
+			return bitRate / 1000, nil
+		}
+	}
+	if rate, err := ProbeFormatBitRate(filename); err == nil && rate > 0 {
+		return rate, nil
+	}
+	if rate, err := AchievedRate(filename); err == nil && rate > 0 {
+		return rate, nil
+	}
+	if config.SourceBitrate > 0 {
+		return config.SourceBitrate, nil
+	}
+	return 0, ErrUnknownSourceRate
+}
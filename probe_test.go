@@ -0,0 +1,206 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// requireFfmpeg skips a test that runs ffmpeg and ffprobe when either is not on the PATH.
+func requireFfmpeg(t *testing.T) {
+	t.Helper()
+	if testing.Short() {
+		t.Skip("runs ffmpeg")
+	}
+	for _, binary := range requiredBinaries() {
+		if _, err := exec.LookPath(binary); err != nil {
+			t.Skipf("%s is not on the PATH", binary)
+		}
+	}
+}
+
+// testSource encodes a synthetic lossless source of the given size, noisy so an encode needs every
+// bit it is given.
+func testSource(t *testing.T, size string, seconds int) string {
+	t.Helper()
+	source := filepath.Join(t.TempDir(), "source.mkv")
+	lavfi := fmt.Sprintf("testsrc2=size=%s:rate=25:duration=%d,noise=alls=20:allf=t,format=yuv420p", size, seconds)
+	if output, err := FfmpegCommand("-f", "lavfi", "-i", lavfi, "-c:v", "ffv1", source).CombinedOutput(); err != nil {
+		t.Fatalf("generating the source: %v: %s", err, output)
+	}
+	return source
+}
+
+// fakeBinary writes an executable shell script of the given name running script and returns its
+// path.
+func fakeBinary(t *testing.T, name, script string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, []byte("#!/bin/sh\n"+script), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+// fakeFfprobe writes an ffprobe answering the stream, container bitrate and packet probes with the
+// given output, and points config.FfprobePath at it.
+func fakeFfprobe(t *testing.T, stream, formatBitRate, packets string) {
+	t.Helper()
+	config.FfprobePath = fakeBinary(t, "ffprobe", fmt.Sprintf(`case "$*" in
+*-show_streams*) echo '%s' ;;
+*format=bit_rate*) echo '%s' ;;
+*packet=size*) echo '%s' ;;
+*) exit 1 ;;
+esac
+`, stream, formatBitRate, packets))
+}
+
+// fakeDuration writes an ffprobe printing duration as the container duration, or failing when it
+// is empty, and points config.FfprobePath at it.
+func fakeDuration(t *testing.T, duration string) {
+	t.Helper()
+	script := "echo '" + duration + "'\n"
+	if duration == "" {
+		script = "echo 'raw.h264: Invalid data found when processing input' >&2\nexit 1\n"
+	}
+	config.FfprobePath = fakeBinary(t, "ffprobe", script)
+}
+
+func TestFallbackSourceRate(t *testing.T) {
+	previous := config
+	t.Cleanup(func() { config = previous })
+
+	// An elementary stream or a live capture: ffprobe knows neither bitrate nor duration.
+	unknownStream, unknownFormat, unknownPackets := `{"streams":[{"codec_type":"video"}]}`, "N/A", `{"packets":[],"streams":[{}],"format":{}}`
+	for _, test := range []struct {
+		name                           string
+		stream, formatBitRate, packets string
+		sourceBitrate                  int
+		rate                           int
+		err                            error
+	}{
+		{"stream bitrate", `{"streams":[{"bit_rate":"5000000"}]}`, "6000000", unknownPackets, 0, 5000, nil},
+		{"container bitrate", unknownStream, "6000000", unknownPackets, 0, 6000, nil},
+		{"size over duration", unknownStream, unknownFormat,
+			`{"packets":[{"size":"250000"},{"size":"250000"}],"streams":[{"avg_frame_rate":"1/1","r_frame_rate":"1/1"}],"format":{}}`, 0, 2000, nil},
+		{"source-bitrate", unknownStream, unknownFormat, unknownPackets, 4000, 4000, nil},
+		{"unknown", unknownStream, unknownFormat, unknownPackets, 0, 0, ErrUnknownSourceRate},
+	} {
+		config = DefaultConfig()
+		config.SourceBitrate = test.sourceBitrate
+		fakeFfprobe(t, test.stream, test.formatBitRate, test.packets)
+		rate, err := FallbackSourceRate("capture.h264")
+		if rate != test.rate || !errors.Is(err, test.err) {
+			t.Errorf("%s: got %d kbps, %v, want %d kbps, %v", test.name, rate, err, test.rate, test.err)
+		}
+	}
+
+	// Without ffprobe at all only -source-bitrate is left.
+	config = DefaultConfig()
+	config.FfprobePath = filepath.Join(t.TempDir(), "missing")
+	if _, err := FallbackSourceRate("capture.h264"); !errors.Is(err, ErrUnknownSourceRate) {
+		t.Errorf("without ffprobe: got %v, want ErrUnknownSourceRate", err)
+	}
+	config.SourceBitrate = 3500
+	if rate, err := FallbackSourceRate("capture.h264"); rate != 3500 || err != nil {
+		t.Errorf("without ffprobe: got %d kbps, %v, want the -source-bitrate", rate, err)
+	}
+}
+
+// A walk is never started for a source of unknown rate, which would otherwise target no rates or
+// rates above the source.
+func TestWalkConvexHullRejectsUnknownRate(t *testing.T) {
+	for _, rate := range []int{0, -1} {
+		hull, err := WalkConvexHull("capture.h264", Resolution{Width: 1920, Height: 1080}, rate)
+		if hull != nil || !errors.Is(err, ErrUnknownSourceRate) {
+			t.Errorf("rate %d: got %v, %v, want ErrUnknownSourceRate", rate, hull, err)
+		}
+	}
+}
+
+// A duration ffprobe fails to print or prints as N/A is a probe failure like any other.
+func TestProbeDuration(t *testing.T) {
+	previous := config
+	t.Cleanup(func() { config = previous })
+
+	for _, test := range []struct {
+		name, output string
+		duration     float64
+		err          error
+	}{
+		{"known", "12.5", 12.5, nil},
+		{"unknown", "N/A", 0, ErrProbeFailed},
+		{"unreadable", "", 0, ErrProbeFailed},
+	} {
+		config = DefaultConfig()
+		fakeDuration(t, test.output)
+		duration, err := ProbeDuration("raw.h264")
+		if duration != test.duration || !errors.Is(err, test.err) {
+			t.Errorf("%s: got %gs, %v, want %gs, %v", test.name, duration, err, test.duration, test.err)
+		}
+	}
+}
+
+func TestProbeEncodeTiming(t *testing.T) {
+	previous := config
+	t.Cleanup(func() { config = previous })
+	config = DefaultConfig()
+	packets := `{"size":"2500"}` + strings.Repeat(`,{"size":"2500"}`, 49)
+
+	for _, test := range []struct {
+		name     string
+		streams  string
+		duration float64
+		rate     int
+	}{
+		// 50 frames at 25 fps last 2s whatever the stream and container durations say.
+		{"constant frame rate", `{"avg_frame_rate":"25/1","r_frame_rate":"25/1","duration":"2.1"}`, 2, 500},
+		{"variable frame rate", `{"avg_frame_rate":"100/4","r_frame_rate":"50/1","duration":"2.5"}`, 2.5, 400},
+		{"container duration only", `{"avg_frame_rate":"100/4","r_frame_rate":"50/1"}`, 4, 250},
+	} {
+		fakeFfprobe(t, "", "", fmt.Sprintf(`{"packets":[%s],"streams":[%s],"format":{"duration":"4.0"}}`, packets, test.streams))
+		timing, err := ProbeEncodeTiming("encode.mp4")
+		if err != nil {
+			t.Fatalf("%s: %v", test.name, err)
+		}
+		if timing.Frames != 50 || timing.Bytes != 125000 || timing.Duration != test.duration || timing.AchievedRate != test.rate {
+			t.Errorf("%s: got %+v, want 50 frames of 125000 bytes over %gs at %d kbps", test.name, timing, test.duration, test.rate)
+		}
+	}
+}
+
+// A two-pass encode of a constant frame rate source achieves its target rate within 10%.
+func TestTwoPassAchievedRate(t *testing.T) {
+	requireFfmpeg(t)
+	previous := config
+	t.Cleanup(func() { config = previous })
+	config = DefaultConfig()
+	config.Codec = "libvpx-vp9"
+	config.Preset = "5"
+	source := testSource(t, "640x360", 4)
+	const rate, tolerance = 600, 0.1
+
+	encoded := filepath.Join(t.TempDir(), "encode.webm")
+	result := make(chan error, 1)
+	EncodeVideo(source, encoded, Resolution{Height: 360, Width: 640}, rate, result)
+	if err := <-result; errors.Is(err, ErrEncoderUnavailable) {
+		t.Skipf("ffmpeg has no %s: %v", config.Codec, err)
+	} else if err != nil {
+		t.Fatal(err)
+	}
+	timing, err := ProbeEncodeTiming(encoded)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if timing.Frames != 100 || timing.Duration != 4 {
+		t.Errorf("got %d frames over %gs, want 100 over 4s", timing.Frames, timing.Duration)
+	}
+	if math.Abs(float64(timing.AchievedRate-rate)) > tolerance*rate {
+		t.Errorf("achieved %d kbps, want %d kbps within %g%%", timing.AchievedRate, rate, tolerance*100)
+	}
+}
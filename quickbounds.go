@@ -0,0 +1,84 @@
+package main
+
+import (
+	"errors"
+	"path/filepath"
+	"strings"
+)
+
+// -quick-bounds is a cheap first pass over a large catalog. Instead of walking the ladder it
+// measures two operating points per asset: the resolution the walk starts from, usually the
+// source's, at the highest target rate and the lowest rung at the lowest target rate. A top point
+// scoring below -quick-bounds-min-vmaf even with the most bits the ladder offers usually means a
+// bad master, and the asset is flagged for the full walk. The result is written next to the hull,
+// so a later full run is not skipped.
+
+// QuickBounds is the result of -quick-bounds for one asset.
+type QuickBounds struct {
+	Top    ConvexHullPoint
+	Bottom ConvexHullPoint
+	// MinVmaf is the -quick-bounds-min-vmaf Top was checked against.
+	MinVmaf float64
+	// Flagged is set when Top scores below MinVmaf.
+	Flagged bool `json:",omitempty"`
+}
+
+// QuickBoundsFilename returns the file the quick bounds of the asset with the given hull file are written to.
+func QuickBoundsFilename(convexHullFilename string) string {
+	return strings.TrimSuffix(convexHullFilename, filepath.Ext(convexHullFilename)) + ".bounds.json"
+}
+
+// ValidateQuickBounds checks that -quick-bounds is not combined with modes that measure other points.
+func ValidateQuickBounds() error {
+	if config.QuickBounds && (config.GridFile != "" || config.Extend || config.EncodeOnly) {
+		return errors.New("it cannot be combined with -grid, -extend or -encode-only")
+	}
+	return nil
+}
+
+// MeasureQuickBounds measures the top and bottom operating points of the reference.
+func MeasureQuickBounds(referenceVideoFilename string, referenceVideoResolution Resolution, referenceVideoRate int) (QuickBounds, error) {
+	targetRates := GetTargetRates(referenceVideoRate)
+	if len(targetRates) == 0 {
+		return QuickBounds{}, errors.New("no target rates")
+	}
+	ladder := LadderFor(referenceVideoResolution)
+	points := []OperatingPoint{
+		{Resolution: WalkStart(referenceVideoResolution), Rate: targetRates[0]},
+		{Resolution: ladder[len(ladder)-1], Rate: targetRates[len(targetRates)-1]},
+	}
+	measured, err := MeasureOperatingGrid(referenceVideoFilename, referenceVideoResolution, points)
+	if err != nil {
+		return QuickBounds{}, err
+	}
+	bounds := QuickBounds{Top: measured[0], Bottom: measured[1], MinVmaf: config.QuickBoundsMinVmaf}
+	bounds.Flagged = bounds.Top.VmafScore < config.QuickBoundsMinVmaf
+	return bounds, nil
+}
+
+// WriteQuickBounds measures the quick bounds of the asset and writes them with the run metadata and
+// the digest of the source, if any.
+func WriteQuickBounds(videoFilename string, referenceFilename string, resolution Resolution, rate int, inputHash *InputDigest, boundsFilename string) {
+	bounds, err := MeasureQuickBounds(referenceFilename, resolution, rate)
+	if err != nil {
+		videoLog(videoFilename).Error("Error measuring quick bounds", "err", err)
+		summary.Record(videoFilename, AssetFailed, err.Error())
+		return
+	}
+	if bounds.Flagged {
+		videoLog(videoFilename).Warn("Top point scores below the quick bounds minimum, run the full walk on it", "vmaf", bounds.Top.VmafScore, "resolution", bounds.Top.Resolution.ToFilterString(), "rate", bounds.Top.Rate, "min_vmaf", bounds.MinVmaf)
+	}
+
+	if err := CreateOutputDirectory(boundsFilename); err != nil {
+		videoLog(videoFilename).Error("Error creating output directory", "file", boundsFilename, "err", err)
+		summary.Record(videoFilename, AssetFailed, err.Error())
+		return
+	}
+	result := ConvexHullResult{Metadata: runMetadata, QuickBounds: &bounds, Attempts: TakeAttempts(referenceFilename), InputHash: inputHash}
+	err = WriteJson(result, boundsFilename)
+	if err != nil {
+		summary.Record(videoFilename, AssetFailed, err.Error())
+		return
+	}
+	summary.RecordResult(videoFilename, AssetDone, "", &result)
+}
@@ -0,0 +1,72 @@
+package main
+
+// Phone footage is often stored landscape with a display matrix rotating it to portrait. ffmpeg
+// applies the rotation when decoding, so every input is opened with -autorotate to make that
+// explicit and identical for the encode and for both scoring inputs. The walk then has to work in
+// the rotated, effective resolution: a 1920x1080 stream rotated by 90 degrees is a 1080x1920 source,
+// walked down a portrait copy of the ladder (1080x1920, 720x1280, ...).
+
+// Transposed returns the resolution with width and height swapped.
+func (resolution Resolution) Transposed() Resolution {
+	return Resolution{Height: resolution.Width, Width: resolution.Height}
+}
+
+// IsPortrait reports whether the resolution is taller than it is wide.
+func (resolution Resolution) IsPortrait() bool {
+	return resolution.Height > resolution.Width
+}
+
+// ShortSide returns the smaller dimension, which is what "1080p" refers to in either orientation.
+func (resolution Resolution) ShortSide() int {
+	return IntMin(resolution.Height, resolution.Width)
+}
+
+// LadderFor returns the candidate resolutions in the orientation of resolution.
+func LadderFor(resolution Resolution) []Resolution {
+	if !resolution.IsPortrait() {
+		return resolutions
+	}
+	ladder := make([]Resolution, len(resolutions))
+	for i, rung := range resolutions {
+		if rung.IsPortrait() {
+			ladder[i] = rung
+		} else {
+			ladder[i] = rung.Transposed()
+		}
+	}
+	return ladder
+}
+
+// Rotation returns the display rotation of the stream in degrees, normalized to 0, 90, 180 or 270.
+func (stream ProbeStream) Rotation() int {
+	rotation := 0
+	for _, sideData := range stream.SideDataList {
+		if sideData.Rotation != 0 {
+			rotation = int(sideData.Rotation)
+		}
+	}
+	if rotation == 0 {
+		if rotate, ok := stream.Tags["rotate"]; ok {
+			rotation = int(parseFloatOrZero(rotate))
+		}
+	}
+	rotation %= 360
+	if rotation < 0 {
+		rotation += 360
+	}
+	return rotation
+}
+
+// EffectiveResolution returns the resolution of the stream after its display rotation is applied.
+func EffectiveResolution(coded Resolution, stream ProbeStream) Resolution {
+	if rotation := stream.Rotation(); rotation == 90 || rotation == 270 {
+		return coded.Transposed()
+	}
+	return coded
+}
+
+// SourceInputArgs returns the ffmpeg options opening filename as an input.
+func SourceInputArgs(filename string) []string {
+	args := HwaccelInputArgs()
+	return append(args, "-autorotate", "-i", filename)
+}
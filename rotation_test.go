@@ -0,0 +1,58 @@
+package main
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestEffectiveResolutionOfRotatedSource(t *testing.T) {
+	coded := Resolution{1080, 1920}
+	for _, test := range []struct {
+		name      string
+		probe     string
+		rotation  int
+		effective Resolution
+	}{
+		{"no rotation", `{}`, 0, coded},
+		{"display matrix 90", `{"side_data_list": [{"side_data_type": "Display Matrix", "rotation": -90}]}`, 270, coded.Transposed()},
+		{"display matrix -90", `{"side_data_list": [{"side_data_type": "Display Matrix", "rotation": 90}]}`, 90, coded.Transposed()},
+		{"display matrix 180", `{"side_data_list": [{"side_data_type": "Display Matrix", "rotation": 180}]}`, 180, coded},
+		{"rotate tag", `{"tags": {"rotate": "90"}}`, 90, coded.Transposed()},
+		{"full turn", `{"tags": {"rotate": "360"}}`, 0, coded},
+	} {
+		var stream ProbeStream
+		if err := json.Unmarshal([]byte(test.probe), &stream); err != nil {
+			t.Fatal(err)
+		}
+		if rotation := stream.Rotation(); rotation != test.rotation {
+			t.Errorf("%s: rotation %d, expected %d", test.name, rotation, test.rotation)
+		}
+		if effective := EffectiveResolution(coded, stream); effective != test.effective {
+			t.Errorf("%s: effective resolution %s, expected %s", test.name, effective.ToFilterString(), test.effective.ToFilterString())
+		}
+	}
+}
+
+func TestRotatedSourceWalksPortraitLadder(t *testing.T) {
+	previous := config
+	t.Cleanup(func() { config = previous })
+	config = DefaultConfig()
+
+	var stream ProbeStream
+	if err := json.Unmarshal([]byte(`{"side_data_list": [{"side_data_type": "Display Matrix", "rotation": -90}]}`), &stream); err != nil {
+		t.Fatal(err)
+	}
+	source := EffectiveResolution(Resolution{1080, 1920}, stream)
+	if source != (Resolution{1920, 1080}) {
+		t.Fatalf("rotated 1920x1080 stream is %s, expected 1080x1920", source.ToFilterString())
+	}
+	for _, rung := range LadderFor(source) {
+		if rung.Width > rung.Height {
+			t.Errorf("portrait source has landscape rung %s", rung.ToFilterString())
+		}
+	}
+	args := BuildEncodeCommand("phone.mp4", "phone_720.mp4", Resolution{1280, 720}, 2000).Args
+	if !containsString(args, "-autorotate") || !containsString(args, "720x1280") {
+		t.Errorf("encode %v does not autorotate to a 720x1280 portrait rung", args)
+	}
+}
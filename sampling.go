@@ -0,0 +1,137 @@
+package main
+
+import (
+	"fmt"
+	"log/slog"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	vidio "github.com/AlexEidt/Vidio"
+)
+
+// VMAF frame sampling strategies.
+const (
+	// AllFramesSampling scores every frame.
+	AllFramesSampling = "all"
+	// SceneSampling scores only the frames around scene changes in the reference, which are the
+	// hardest frames to encode, as a cheaper approximation for exploratory runs.
+	SceneSampling = "scene"
+)
+
+var sceneChangePtsTime = regexp.MustCompile(`pts_time:\s*([0-9.]+)`)
+
+// DetectSceneChanges returns the timestamps, in seconds, of the scene changes ffmpeg detects in filename.
+func DetectSceneChanges(filename string, threshold float64) ([]float64, error) {
+	args := append(SourceInputArgs(filename), "-map", VideoStreamSpecifier(0, filename), "-vf", fmt.Sprintf("select='gt(scene,%g)',showinfo", threshold), "-f", "null", "-")
+	// showinfo logs at info level, whatever -ffmpeg-loglevel is.
+	cmd := ffmpegCommandWithLogLevel("info", args...)
+	slog.Debug("Executing command", "cmd", cmd.String())
+	output, err := cmd.CombinedOutput()
+	if err != nil {
+		return nil, err
+	}
+
+	var times []float64
+	for _, line := range strings.Split(string(output), "\n") {
+		if !strings.Contains(line, "Parsed_showinfo") {
+			continue
+		}
+		if match := sceneChangePtsTime.FindStringSubmatch(line); match != nil {
+			time, err := strconv.ParseFloat(match[1], 64)
+			if err == nil {
+				times = append(times, time)
+			}
+		}
+	}
+	return times, nil
+}
+
+// SceneSelectExpression returns a select filter expression keeping window frames from the start
+// of each scene. The first scene always starts at frame zero.
+func SceneSelectExpression(sceneTimes []float64, fps float64, window int) string {
+	starts := []int{0}
+	for _, time := range sceneTimes {
+		starts = append(starts, int(time*fps+0.5))
+	}
+	sort.Ints(starts)
+
+	// Merge windows that overlap so each frame is selected once.
+	var ranges [][2]int
+	for _, start := range starts {
+		end := start + window - 1
+		if len(ranges) > 0 && start <= ranges[len(ranges)-1][1]+1 {
+			ranges[len(ranges)-1][1] = IntMax(ranges[len(ranges)-1][1], end)
+			continue
+		}
+		ranges = append(ranges, [2]int{start, end})
+	}
+
+	terms := make([]string, len(ranges))
+	for i, frameRange := range ranges {
+		terms[i] = fmt.Sprintf("between(n,%d,%d)", frameRange[0], frameRange[1])
+	}
+	return strings.Join(terms, "+")
+}
+
+type sceneSelection struct {
+	once       sync.Once
+	expression string
+	err        error
+}
+
+var sceneSelections = struct {
+	sync.Mutex
+	byFilename map[string]*sceneSelection
+}{byFilename: make(map[string]*sceneSelection)}
+
+// VmafFrameSelection returns the select expression applied to both inputs when scoring against
+// referenceFilename, or an empty string to score every frame. Scene detection runs once per reference.
+func VmafFrameSelection(referenceFilename string) (string, error) {
+	if config.VmafSampling != SceneSampling {
+		return "", nil
+	}
+
+	sceneSelections.Lock()
+	selection, ok := sceneSelections.byFilename[referenceFilename]
+	if !ok {
+		selection = &sceneSelection{}
+		sceneSelections.byFilename[referenceFilename] = selection
+	}
+	sceneSelections.Unlock()
+
+	selection.once.Do(func() {
+		video, err := vidio.NewVideo(referenceFilename)
+		if err != nil {
+			selection.err = err
+			return
+		}
+		sceneTimes, err := DetectSceneChanges(referenceFilename, config.SceneThreshold)
+		if err != nil {
+			selection.err = err
+			return
+		}
+		selection.expression = SceneSelectExpression(sceneTimes, video.FPS(), config.SceneWindow)
+		videoLog(referenceFilename).Info("Scoring scenes", "scenes", len(sceneTimes)+1)
+	})
+	return selection.expression, selection.err
+}
+
+// ValidateVmafSampling reports an error for unknown sampling strategies or invalid scene settings.
+func ValidateVmafSampling() error {
+	switch config.VmafSampling {
+	case AllFramesSampling:
+	case SceneSampling:
+		if config.SceneThreshold <= 0 || config.SceneThreshold >= 1 {
+			return fmt.Errorf("scene threshold %g must be between 0 and 1", config.SceneThreshold)
+		}
+		if config.SceneWindow <= 0 {
+			return fmt.Errorf("scene window %d must be positive", config.SceneWindow)
+		}
+	default:
+		return fmt.Errorf("unknown VMAF sampling %q, expected %s or %s", config.VmafSampling, AllFramesSampling, SceneSampling)
+	}
+	return nil
+}
@@ -0,0 +1,145 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"sort"
+)
+
+// PointImplausible points were encoded and scored, but their score is implausible for their rate,
+// the mark of an encode that silently corrupted, so they are kept out of the hull.
+const PointImplausible = "implausible"
+
+// ValidateSanityCheck checks the -sanity-check thresholds.
+func ValidateSanityCheck() error {
+	if config.SanityTolerance <= 0 {
+		return fmt.Errorf("tolerance %g must be positive", config.SanityTolerance)
+	}
+	if config.SanityMinBpp < 0 {
+		return fmt.Errorf("bits per pixel %g must not be negative", config.SanityMinBpp)
+	}
+	return nil
+}
+
+// ImplausiblePoints returns the reason each implausible point of measured is implausible, by
+// index. VMAF rises with the rate at a fixed resolution, so a point is implausible when, among the
+// points of its resolution, it is more than config.SanityTolerance VMAF away from the score
+// interpolated on the logarithm of the rate between its lower and higher rate neighbours, or, at
+// the lowest or highest rate, more than the tolerance above its higher or below its lower
+// neighbour. The worst point is flagged first and leaves the comparison before the others are
+// measured against their neighbours again, so one spike does not condemn the points around it.
+// With config.SanityMinBpp a point is also implausible when it scores at least
+// config.SanityMaxVmaf at fewer bits per pixel, as a frozen or blank encode does.
+func ImplausiblePoints(measured []ConvexHullPoint) map[int]string {
+	reasons := make(map[int]string)
+	byResolution := make(map[Resolution][]int)
+	for i, point := range measured {
+		if (point.Status != "" && point.Status != PointOk) || point.VmafScore < 0 {
+			continue
+		}
+		if bpp, ok := bitsPerPixel(point); ok && config.SanityMinBpp > 0 && bpp < config.SanityMinBpp && point.VmafScore >= config.SanityMaxVmaf {
+			reasons[i] = fmt.Sprintf("VMAF %.2f at %.4f bits per pixel", point.VmafScore, bpp)
+			continue
+		}
+		byResolution[point.Resolution] = append(byResolution[point.Resolution], i)
+	}
+
+	for _, indices := range byResolution {
+		sort.Slice(indices, func(a, b int) bool { return measured[indices[a]].Rate < measured[indices[b]].Rate })
+		for len(indices) > 1 {
+			worst, worstDeviation, worstReason := -1, config.SanityTolerance, ""
+			for n := range indices {
+				deviation, reason := rateDeviation(measured, indices, n)
+				if deviation > worstDeviation {
+					worst, worstDeviation, worstReason = n, deviation, reason
+				}
+			}
+			if worst < 0 {
+				break
+			}
+			reasons[indices[worst]] = worstReason
+			indices = append(indices[:worst], indices[worst+1:]...)
+		}
+	}
+	return reasons
+}
+
+// rateDeviation returns how far the n-th point of indices, ascending in rate, is from what its
+// neighbours at the same resolution predict, with the reason it would be flagged for.
+func rateDeviation(measured []ConvexHullPoint, indices []int, n int) (float64, string) {
+	point := measured[indices[n]]
+	switch {
+	case n == 0:
+		higher := measured[indices[1]]
+		return point.VmafScore - higher.VmafScore, fmt.Sprintf("VMAF %.2f above %.2f at %d kbps", point.VmafScore, higher.VmafScore, higher.Rate)
+	case n == len(indices)-1:
+		lower := measured[indices[n-1]]
+		return lower.VmafScore - point.VmafScore, fmt.Sprintf("VMAF %.2f below %.2f at %d kbps", point.VmafScore, lower.VmafScore, lower.Rate)
+	}
+	lower, higher := measured[indices[n-1]], measured[indices[n+1]]
+	position := (math.Log(float64(point.Rate)) - math.Log(float64(lower.Rate))) / (math.Log(float64(higher.Rate)) - math.Log(float64(lower.Rate)))
+	expected := lower.VmafScore + position*(higher.VmafScore-lower.VmafScore)
+	return math.Abs(point.VmafScore - expected), fmt.Sprintf("VMAF %.2f where %d and %d kbps predict %.2f", point.VmafScore, lower.Rate, higher.Rate, expected)
+}
+
+// bitsPerPixel returns the bits per pixel of a point's encode, when its timing was probed.
+func bitsPerPixel(point ConvexHullPoint) (float64, bool) {
+	timing := point.EncodeTiming
+	if timing == nil || timing.Frames <= 0 || timing.Duration <= 0 || point.Resolution.Width <= 0 || point.Resolution.Height <= 0 {
+		return 0, false
+	}
+	fps := float64(timing.Frames) / timing.Duration
+	return float64(point.Rate) * 1000 / (float64(point.Resolution.Width*point.Resolution.Height) * fps), true
+}
+
+// SanityCheckHull flags the implausible points among the attempts of referenceFilename and the
+// grid, recording them as PointImplausible with the reason, and returns the hull without them. A
+// flagged hull point is replaced by the best plausible point measured at its rate, or dropped when
+// there is none.
+func SanityCheckHull(referenceFilename string, hull []ConvexHullPoint, grid []ConvexHullPoint) []ConvexHullPoint {
+	measured := AttemptsOf(referenceFilename)
+	for _, point := range grid {
+		if !containsOperatingPoint(measured, point) {
+			measured = append(measured, point)
+		}
+	}
+	implausible := make(map[OperatingPoint]string)
+	for i, reason := range ImplausiblePoints(measured) {
+		point := measured[i]
+		videoLog(referenceFilename).Warn("Flagging point as implausible", "resolution", point.Resolution.ToFilterString(), "rate", point.Rate, "reason", reason)
+		implausible[OperatingPoint{Resolution: point.Resolution, Rate: point.Rate}] = reason
+		point.Status, point.Reason = PointImplausible, reason
+		RecordAttempt(referenceFilename, point)
+		measured[i] = point
+	}
+	for i := range grid {
+		if reason, ok := implausible[OperatingPoint{Resolution: grid[i].Resolution, Rate: grid[i].Rate}]; ok {
+			grid[i].Status, grid[i].Reason = PointImplausible, reason
+		}
+	}
+
+	var checked []ConvexHullPoint
+	for _, point := range hull {
+		if _, ok := implausible[OperatingPoint{Resolution: point.Resolution, Rate: point.Rate}]; ok {
+			replacement, found := bestMeasuredAtRate(measured, point.Rate, 0)
+			if !found {
+				videoLog(referenceFilename).Info("Dropping point with no plausible point measured at its rate", "resolution", point.Resolution.ToFilterString(), "rate", point.Rate)
+				continue
+			}
+			replacement.Status, replacement.Reason = "", ""
+			point = replacement
+		}
+		checked = append(checked, point)
+	}
+	return checked
+}
+
+// containsOperatingPoint reports whether points holds a point at the resolution and rate of point.
+func containsOperatingPoint(points []ConvexHullPoint, point ConvexHullPoint) bool {
+	for _, other := range points {
+		if other.Resolution == point.Resolution && other.Rate == point.Rate {
+			return true
+		}
+	}
+	return false
+}
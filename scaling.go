@@ -0,0 +1,115 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// libvmaf compares frames of equal size, so scoring brings both inputs to one scoring resolution,
+// the reference resolution unless -vmaf-resolution is set. How each input gets there changes the
+// score: the encode is what a player shows after its own upscale, often bilinear, so scoring it
+// after a sharper bicubic upscale flatters low rungs. -scale-distorted and -scale-reference pick the
+// scale algorithm of each input independently, or none to leave an input untouched, which is only
+// valid when that input is already at the scoring resolution.
+
+// NoScale leaves an input unscaled.
+const NoScale = "none"
+
+// scaleAlgorithms are the scale filter flags accepted by -scale-distorted and -scale-reference.
+var scaleAlgorithms = []string{"fast_bilinear", "bilinear", "bicubic", "experimental", "neighbor", "area", "bicublin", "gauss", "sinc", "lanczos", "spline"}
+
+// ScoringResolution returns the resolution both inputs are compared at when scoring an encode at
+// testResolution, which is zero when the rung is unknown.
+func ScoringResolution(referenceResolution Resolution, testResolution Resolution) Resolution {
+	if display := DisplayResolutionOf(testResolution); display != nil {
+		return *display
+	}
+	if config.VmafResolution == "" {
+		return referenceResolution
+	}
+	resolution, err := ParseResolution(config.VmafResolution)
+	if err != nil {
+		return referenceResolution
+	}
+	return resolution
+}
+
+// ScaleFilter returns the filter bringing an input to resolution with algorithm, including the
+// color options, or an empty string when there is nothing to do.
+func ScaleFilter(resolution Resolution, algorithm string) string {
+	var options []string
+	if algorithm != NoScale {
+		options = append(options, resolution.ToFilterString(), "flags="+algorithm)
+	}
+	if colorOptions := ColorScaleOptions(); colorOptions != "" {
+		options = append(options, colorOptions)
+	}
+	if len(options) == 0 {
+		return ""
+	}
+	return "scale=" + strings.Join(options, ":")
+}
+
+// InputScaleFilter returns ScaleFilter for an input at resolution from, which is zero when unknown,
+// brought to the scoring resolution to. An input already at the scoring resolution, such as the top
+// rung of a source below the ladder's top, is not scaled at all: an identity scale still sends its
+// frames through swscale, and scoring the source against a lossless remux of itself must not see any
+// difference but 100.
+func InputScaleFilter(from Resolution, to Resolution, algorithm string) string {
+	if from == to {
+		algorithm = NoScale
+	}
+	return ScaleFilter(to, algorithm)
+}
+
+func validScaleAlgorithm(algorithm string) bool {
+	if algorithm == NoScale {
+		return true
+	}
+	for _, known := range scaleAlgorithms {
+		if algorithm == known {
+			return true
+		}
+	}
+	return false
+}
+
+// ValidateScaling checks the scale algorithms and the scoring resolution.
+func ValidateScaling() error {
+	if !validScaleAlgorithm(config.ScaleDistorted) {
+		return fmt.Errorf("unknown -scale-distorted %q, expected none or one of %s", config.ScaleDistorted, strings.Join(scaleAlgorithms, ", "))
+	}
+	if !validScaleAlgorithm(config.ScaleReference) {
+		return fmt.Errorf("unknown -scale-reference %q, expected none or one of %s", config.ScaleReference, strings.Join(scaleAlgorithms, ", "))
+	}
+	if config.VmafResolution != "" {
+		if _, err := ParseResolution(config.VmafResolution); err != nil {
+			return err
+		}
+	}
+	if config.Prescale && config.ScaleDistorted == NoScale {
+		return errors.New("-prescale has nothing to do with -scale-distorted none")
+	}
+	return nil
+}
+
+// CheckScaling returns an error when an input left unscaled is not at the scoring resolution,
+// which libvmaf would otherwise reject or, after an implicit conversion, score meaninglessly.
+func CheckScaling(testFilename string, referenceResolution Resolution, testResolution Resolution) error {
+	scoringResolution := ScoringResolution(referenceResolution, testResolution)
+	if config.ScaleReference == NoScale && referenceResolution != scoringResolution {
+		return fmt.Errorf("reference is %s but scored at %s with -scale-reference none", referenceResolution.ToFilterString(), scoringResolution.ToFilterString())
+	}
+	if config.ScaleDistorted == NoScale {
+		stream, err := ProbeVideoStream(testFilename)
+		if err != nil {
+			return err
+		}
+		testResolution := Resolution{Height: stream.Height, Width: stream.Width}
+		if testResolution != scoringResolution {
+			return fmt.Errorf("%s is %s but scored at %s with -scale-distorted none", testFilename, testResolution.ToFilterString(), scoringResolution.ToFilterString())
+		}
+	}
+	return nil
+}
@@ -0,0 +1,79 @@
+package main
+
+import (
+	"errors"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestInputScaleFilter(t *testing.T) {
+	previous := config
+	t.Cleanup(func() { config = previous })
+	config = DefaultConfig()
+	sd, qhd := Resolution{Height: 540, Width: 960}, Resolution{Height: 360, Width: 640}
+
+	for _, test := range []struct {
+		name      string
+		from, to  Resolution
+		algorithm string
+		want      string
+	}{
+		{"identity", sd, sd, "bicubic", ""},
+		{"upscale", qhd, sd, "bicubic", "scale=960x540:flags=bicubic"},
+		{"unknown rung", Resolution{}, sd, "bilinear", "scale=960x540:flags=bilinear"},
+		{"no scale", qhd, sd, NoScale, ""},
+	} {
+		if got := InputScaleFilter(test.from, test.to, test.algorithm); got != test.want {
+			t.Errorf("%s: got %q, want %q", test.name, got, test.want)
+		}
+	}
+}
+
+// The top rung of a source below the ladder's top is scored without a scale on either input, with
+// or without -prescale, while a lower rung is still upscaled.
+func TestVmafGraphOmitsIdentityScale(t *testing.T) {
+	previous := config
+	t.Cleanup(func() { config = previous })
+	sd, qhd := Resolution{Height: 540, Width: 960}, Resolution{Height: 360, Width: 640}
+
+	for _, prescale := range []bool{false, true} {
+		config = DefaultConfig()
+		config.Prescale = prescale
+		args := BuildVmafCommand("source.mp4", sd, "encode.mp4", sd, "", "").Args
+		if graph := args[argIndex(args, "-filter_complex")+1]; strings.Contains(graph, "scale=") {
+			t.Errorf("prescale %v: %q scales an input already at the scoring resolution", prescale, graph)
+		}
+	}
+	config = DefaultConfig()
+	args := BuildVmafCommand("source.mp4", sd, "encode.mp4", qhd, "", "").Args
+	if graph := args[argIndex(args, "-filter_complex")+1]; strings.Count(graph, "scale=960x540") != 1 {
+		t.Errorf("%q does not upscale the 360p encode alone", graph)
+	}
+}
+
+// libvmaf scores a source against a lossless remux of itself at about 100.
+func TestSourceScoresAgainstItself(t *testing.T) {
+	requireFfmpeg(t)
+	previous := config
+	t.Cleanup(func() { config = previous })
+	config = DefaultConfig()
+	sd := Resolution{Height: 540, Width: 960}
+	source := testSource(t, sd.ToFilterString(), 2)
+	remux := filepath.Join(t.TempDir(), "remux.mkv")
+	if output, err := FfmpegCommand("-i", source, "-c", "copy", remux).CombinedOutput(); err != nil {
+		t.Fatalf("remuxing the source: %v: %s", err, output)
+	}
+
+	result := make(chan VmafMetrics, 1)
+	ComputeVmaf(source, sd, remux, sd, SelectVmafModel(sd), result)
+	metrics := <-result
+	if errors.Is(metrics.Err, ErrLibvmafMissing) {
+		t.Skipf("ffmpeg has no libvmaf: %v", metrics.Err)
+	} else if metrics.Err != nil {
+		t.Fatal(metrics.Err)
+	}
+	if metrics.Mean < 99 {
+		t.Errorf("source scored %v against itself, want about 100", metrics.Mean)
+	}
+}
@@ -0,0 +1,92 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+)
+
+// GenerateSyntheticClip encodes a short testsrc clip at the given resolution and rate.
+func GenerateSyntheticClip(filename string, resolution Resolution, rate int, seconds int) error {
+	source := fmt.Sprintf("testsrc=size=%s:rate=30:duration=%d", resolution.ToFilterString(), seconds)
+	cmd := FfmpegCommand("-f", "lavfi", "-i", source, "-c:v", "libx264", "-b:v", fmt.Sprintf("%dk", rate), "-pix_fmt", "yuv420p", filename)
+	slog.Debug("Executing command", "cmd", cmd.String())
+	output, err := cmd.CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("%s: %s", err.Error(), output)
+	}
+	return nil
+}
+
+// ValidateConvexHull checks that a hull is non-empty, that every score is a valid VMAF, and that
+// VMAF does not increase as the rate decreases.
+func ValidateConvexHull(convexHull []ConvexHullPoint) []string {
+	var problems []string
+	if len(convexHull) == 0 {
+		return append(problems, "convex hull is empty")
+	}
+	for i, point := range convexHull {
+		if point.VmafScore < 0 || point.VmafScore > 100 {
+			problems = append(problems, fmt.Sprintf("point %d at %d kbps has VMAF %f outside 0-100", i, point.Rate, point.VmafScore))
+		}
+		if i == 0 {
+			continue
+		}
+		previous := convexHull[i-1]
+		if point.Rate >= previous.Rate {
+			problems = append(problems, fmt.Sprintf("point %d at %d kbps does not follow %d kbps in decreasing rate order", i, point.Rate, previous.Rate))
+		}
+		if point.VmafScore > previous.VmafScore {
+			problems = append(problems, fmt.Sprintf("point %d at %d kbps has VMAF %f above %f at %d kbps", i, point.Rate, point.VmafScore, previous.VmafScore, previous.Rate))
+		}
+	}
+	return problems
+}
+
+// RunSelftestCommand implements the selftest subcommand. It generates a synthetic clip, walks its
+// convex hull and checks the result, exiting non-zero on any failure.
+func RunSelftestCommand(args []string) int {
+	flags := flag.NewFlagSet("selftest", flag.ExitOnError)
+	config.RegisterFlags(flags)
+	ParseFlags(flags, args)
+
+	dir, err := os.MkdirTemp("", "vmaf-selftest")
+	if err != nil {
+		fmt.Printf("FAIL: creating temp directory: %s\n", err.Error())
+		return 1
+	}
+	defer os.RemoveAll(dir)
+
+	clipResolution := Resolution{Height: 360, Width: 640}
+	clipFilename := filepath.Join(dir, "selftest.mp4")
+	slog.Info("Generating synthetic clip", "file", clipFilename)
+	if err := GenerateSyntheticClip(clipFilename, clipResolution, 1500, 2); err != nil {
+		fmt.Printf("FAIL: generating synthetic clip, check that ffmpeg is installed with libx264: %s\n", err.Error())
+		return 1
+	}
+
+	resolution, rate := GetVideoResolutionAndBitrate(clipFilename)
+	if resolution != clipResolution || rate <= 0 {
+		fmt.Printf("FAIL: synthetic clip probed as %s at %d kbps, expected %s\n", resolution.ToFilterString(), rate, clipResolution.ToFilterString())
+		return 1
+	}
+
+	convexHull, err := WalkConvexHull(clipFilename, resolution, rate)
+	if err != nil {
+		fmt.Printf("FAIL: walking convex hull, check that ffmpeg is built with libvmaf: %s\n", err.Error())
+		return 1
+	}
+
+	problems := ValidateConvexHull(convexHull)
+	for _, problem := range problems {
+		fmt.Printf("FAIL: %s\n", problem)
+	}
+	if len(problems) > 0 {
+		return 1
+	}
+
+	fmt.Printf("PASS: %d hull points for a %s clip at %d kbps\n", len(convexHull), resolution.ToFilterString(), rate)
+	return 0
+}
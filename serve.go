@@ -0,0 +1,160 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+// The serve subcommand walks hulls on request instead of from -input-list. A POST to /hulls with
+// {"Video": "path"}, relative to -video-dir, queues the walk of that video and answers 202 with the
+// path its result is written to; at most -batch-size videos, or as many as -cpu-budget plans, are
+// walked at once. A GET of /hulls?video=path answers 202 while the video is queued or walking and
+// the result once it is written. Videos outside -video-dir are refused.
+
+// HullRequest is the body of a POST to /hulls.
+type HullRequest struct {
+	Video string
+}
+
+// HullResponse answers a request for a hull that is not written yet.
+type HullResponse struct {
+	Video  string
+	Output string
+	Status string
+}
+
+// hullServer walks the hulls requested of the serve subcommand.
+type hullServer struct {
+	mu      sync.Mutex
+	running map[string]bool
+	slots   chan struct{}
+}
+
+// resolveVideo returns the path of a requested video, or an error when it lies outside -video-dir.
+func resolveVideo(video string) (string, error) {
+	if video == "" || filepath.IsAbs(video) {
+		return "", fmt.Errorf("video %q is not a path relative to the video directory", video)
+	}
+	videoFilename := filepath.Join(config.VideoDirectory, video)
+	if rel, err := filepath.Rel(config.VideoDirectory, videoFilename); err != nil || strings.HasPrefix(rel, "..") {
+		return "", fmt.Errorf("video %q is outside the video directory", video)
+	}
+	return videoFilename, nil
+}
+
+func (s *hullServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodPost:
+		var request HullRequest
+		if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		s.start(w, request.Video)
+	case http.MethodGet:
+		s.status(w, r.URL.Query().Get("video"))
+	default:
+		http.Error(w, "expected GET or POST", http.StatusMethodNotAllowed)
+	}
+}
+
+// start queues the walk of video.
+func (s *hullServer) start(w http.ResponseWriter, video string) {
+	videoFilename, err := resolveVideo(video)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if _, err := os.Stat(videoFilename); err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+
+	s.mu.Lock()
+	if !s.running[videoFilename] {
+		s.running[videoFilename] = true
+		go s.walk(videoFilename)
+	}
+	s.mu.Unlock()
+	writeHullResponse(w, http.StatusAccepted, HullResponse{Video: video, Output: HullFilename(videoFilename), Status: "running"})
+}
+
+// walk walks the hull of videoFilename once a slot is free.
+func (s *hullServer) walk(videoFilename string) {
+	s.slots <- struct{}{}
+	defer func() { <-s.slots }()
+	var wg sync.WaitGroup
+	wg.Add(1)
+	EstimateVmafConvexHull(videoFilename, &wg)
+	s.mu.Lock()
+	delete(s.running, videoFilename)
+	s.mu.Unlock()
+}
+
+// status answers with the result of video, or with its state when there is none yet.
+func (s *hullServer) status(w http.ResponseWriter, video string) {
+	videoFilename, err := resolveVideo(video)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	output := HullFilename(videoFilename)
+	s.mu.Lock()
+	running := s.running[videoFilename]
+	s.mu.Unlock()
+	if running {
+		writeHullResponse(w, http.StatusAccepted, HullResponse{Video: video, Output: output, Status: "running"})
+		return
+	}
+	data, err := os.ReadFile(output)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("no hull of %s at %s", video, output), http.StatusNotFound)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(data)
+}
+
+// writeHullResponse writes response as JSON with the status code.
+func writeHullResponse(w http.ResponseWriter, code int, response HullResponse) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	json.NewEncoder(w).Encode(response)
+}
+
+// RunServeCommand implements the serve subcommand: serve [-listen addr] [flags].
+func RunServeCommand(args []string) int {
+	flags := flag.NewFlagSet("serve", flag.ExitOnError)
+	listen := flags.String("listen", ":8080", "address to serve hull requests on")
+	config.RegisterFlags(flags)
+	ParseFlags(flags, args)
+	if flags.NArg() != 0 {
+		fmt.Fprintf(os.Stderr, "Usage: %s serve [-listen addr] [flags]\n", os.Args[0])
+		return 2
+	}
+	removeTempDir := StartRun(!config.EncodeOnly)
+	defer removeTempDir()
+	if config.WebhookUrl != "" {
+		webhook = NewWebhook(config.WebhookUrl)
+	}
+
+	walks := cpuPlan.Walks
+	if config.BatchSize > 0 {
+		walks = config.BatchSize
+	}
+	server := &hullServer{running: make(map[string]bool), slots: make(chan struct{}, walks)}
+	http.Handle("/hulls", server)
+	slog.Info("Serving hull requests", "listen", *listen, "walks", walks)
+	if err := http.ListenAndServe(*listen, nil); err != nil {
+		slog.Error("Error serving", "listen", *listen, "err", err)
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,81 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// SpeedProfile bundles the settings trading run time for accuracy into one -speed choice: the
+// encoder preset, how densely libvmaf samples frames, whether each asset is sampled with clips and
+// whether rates are walked or the whole grid is measured. Flags given on the command line or in the
+// -config file win over the profile. The whole grid is only measured for a hull walk: a run given
+// -target-vmaf, -quick-bounds, -grid, -extend or -encode-only keeps its own search.
+type SpeedProfile struct {
+	Description string
+	// Flags are the flag values the profile sets.
+	Flags map[string]string
+	// Presets are the encoder presets of the profile by codec. Codecs without one keep their own.
+	Presets map[string]string
+}
+
+var speedProfiles = map[string]SpeedProfile{
+	"fast": {
+		Description: "exploratory runs: fast presets, three 5s clips per asset, every 5th frame scored",
+		Flags:       map[string]string{"clips": "3", "clip-length": "5", "vmaf-subsample": "5", "exhaustive": "false"},
+		Presets:     map[string]string{"libx264": "veryfast", "libx265": "veryfast", "libvpx-vp9": "5", "libaom-av1": "8", "libsvtav1": "10", "h264_nvenc": "p2", "hevc_nvenc": "p2", "av1_nvenc": "p2", "h264_qsv": "veryfast", "hevc_qsv": "veryfast"},
+	},
+	"balanced": {
+		Description: "the whole asset, every 2nd frame scored, walking rates",
+		Flags:       map[string]string{"clips": "0", "vmaf-subsample": "2", "exhaustive": "false"},
+		Presets:     map[string]string{"libx264": "medium", "libx265": "medium", "libvpx-vp9": "2", "libaom-av1": "6", "libsvtav1": "8", "h264_nvenc": "p5", "hevc_nvenc": "p5", "av1_nvenc": "p5", "h264_qsv": "medium", "hevc_qsv": "medium"},
+	},
+	"accurate": {
+		Description: "final ladders: slow presets, every frame scored, the whole grid measured",
+		Flags:       map[string]string{"clips": "0", "vmaf-subsample": "1", "exhaustive": "true"},
+		Presets:     map[string]string{"libx264": "slow", "libx265": "slow", "libvpx-vp9": "1", "libaom-av1": "4", "libsvtav1": "5", "h264_nvenc": "p7", "hevc_nvenc": "p7", "av1_nvenc": "p7", "h264_qsv": "slow", "hevc_qsv": "slow"},
+	},
+}
+
+func speedProfileNames() []string {
+	var names []string
+	for name := range speedProfiles {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// ApplySpeedProfile sets the flags of the -speed profile that were not set otherwise.
+func ApplySpeedProfile(flags *flag.FlagSet) error {
+	if config.Speed == "" {
+		return nil
+	}
+	profile, ok := speedProfiles[config.Speed]
+	if !ok {
+		return fmt.Errorf("unknown speed profile %q, expected one of %s", config.Speed, strings.Join(speedProfileNames(), ", "))
+	}
+	explicit := make(map[string]bool)
+	flags.Visit(func(f *flag.Flag) { explicit[f.Name] = true })
+
+	values := make(map[string]string)
+	for name, value := range profile.Flags {
+		values[name] = value
+	}
+	if preset, ok := profile.Presets[config.Codec]; ok {
+		values["preset"] = preset
+	}
+	if config.TargetVmaf > 0 || config.QuickBounds || config.GridFile != "" || config.Extend || config.EncodeOnly {
+		delete(values, "exhaustive")
+	}
+	for name, value := range values {
+		if explicit[name] {
+			continue
+		}
+		if err := flags.Set(name, value); err != nil {
+			return fmt.Errorf("%s: %w", name, err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,41 @@
+package main
+
+import (
+	"flag"
+	"testing"
+)
+
+func TestApplySpeedProfile(t *testing.T) {
+	previous := config
+	t.Cleanup(func() { config = previous })
+
+	for _, test := range []struct {
+		args       []string
+		exhaustive bool
+		subsample  int
+	}{
+		{[]string{"-speed", "accurate"}, true, 1},
+		{[]string{"-speed", "accurate", "-vmaf-subsample", "3"}, true, 3},
+		{[]string{"-speed", "accurate", "-exhaustive=false"}, false, 1},
+		// Searches other than the hull walk keep their own, so the profile cannot make them invalid.
+		{[]string{"-speed", "accurate", "-target-vmaf", "93"}, false, 1},
+		{[]string{"-speed", "accurate", "-quick-bounds"}, false, 1},
+		{[]string{"-speed", "accurate", "-grid", "points.csv"}, false, 1},
+		{[]string{"-speed", "accurate", "-extend"}, false, 1},
+		{[]string{"-speed", "accurate", "-encode-only"}, false, 1},
+		{[]string{"-speed", "fast"}, false, 5},
+	} {
+		config = DefaultConfig()
+		flags := flag.NewFlagSet("hull", flag.ContinueOnError)
+		config.RegisterFlags(flags)
+		if err := flags.Parse(test.args); err != nil {
+			t.Fatal(err)
+		}
+		if err := ApplySpeedProfile(flags); err != nil {
+			t.Fatalf("%v: %v", test.args, err)
+		}
+		if config.Exhaustive != test.exhaustive || config.VmafSubsample != test.subsample {
+			t.Errorf("%v: got -exhaustive %v and -vmaf-subsample %d, want %v and %d", test.args, config.Exhaustive, config.VmafSubsample, test.exhaustive, test.subsample)
+		}
+	}
+}
@@ -0,0 +1,54 @@
+package main
+
+import "math"
+
+// The mean hides how consistent an encode is: an encode steady at 85 and one swinging between 70
+// and 100 pool to similar scores but look very different. Every scoring pass therefore also
+// reports the population standard deviation of the per-frame VMAF, and -vmaf-stddev-penalty lets
+// the hull prefer steady encodes by ranking points on mean - penalty * stddev. Scores themselves
+// are never changed by the penalty.
+//
+// A series alternating between 80 and 90 has mean 85 and a standard deviation of exactly 5.
+
+// FrameStats accumulates the mean and variance of a series of frame scores in one pass, with
+// Welford's method, so the per-frame array never needs to be held in memory.
+type FrameStats struct {
+	count int
+	mean  float64
+	m2    float64
+}
+
+// Add adds a frame score.
+func (stats *FrameStats) Add(score float64) {
+	stats.count++
+	delta := score - stats.mean
+	stats.mean += delta / float64(stats.count)
+	stats.m2 += delta * (score - stats.mean)
+}
+
+// StdDev returns the population standard deviation of the scores added, or nil when there are none.
+func (stats *FrameStats) StdDev() *float64 {
+	if stats.count == 0 {
+		return nil
+	}
+	stdDev := math.Sqrt(stats.m2 / float64(stats.count))
+	return &stdDev
+}
+
+// penalizedScore returns mean less the configured penalty for each unit of frame standard deviation.
+func penalizedScore(mean float64, stdDev *float64) float64 {
+	if stdDev == nil || config.VmafStdDevPenalty == 0 {
+		return mean
+	}
+	return mean - config.VmafStdDevPenalty**stdDev
+}
+
+// SelectionScore returns the score the walk ranks resolutions by.
+func (metrics VmafMetrics) SelectionScore() float64 {
+	return penalizedScore(metrics.Mean, metrics.StdDev)
+}
+
+// SelectionScore returns the score the hull ranks points by.
+func (point ConvexHullPoint) SelectionScore() float64 {
+	return penalizedScore(point.VmafScore, point.VmafStdDev)
+}
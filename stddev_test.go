@@ -0,0 +1,84 @@
+package main
+
+import (
+	"io"
+	"math"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestFrameStatsStdDev(t *testing.T) {
+	for _, test := range []struct {
+		name   string
+		scores []float64
+		want   float64
+	}{
+		{"alternating", []float64{80, 90, 80, 90, 80, 90}, 5},
+		{"steady", []float64{85, 85, 85}, 0},
+		{"single frame", []float64{42}, 0},
+		{"known variance", []float64{2, 4, 4, 4, 5, 5, 7, 9}, 2},
+		// A large offset loses the variance to cancellation in the naive sum of squares.
+		{"large offset", []float64{1e9 + 4, 1e9 + 7, 1e9 + 13, 1e9 + 16}, math.Sqrt(22.5)},
+	} {
+		var stats FrameStats
+		for _, score := range test.scores {
+			stats.Add(score)
+		}
+		if stdDev := stats.StdDev(); stdDev == nil || math.Abs(*stdDev-test.want) > 1e-9 {
+			t.Errorf("%s: got %v, want %v", test.name, stdDev, test.want)
+		}
+	}
+
+	var empty FrameStats
+	if stdDev := empty.StdDev(); stdDev != nil {
+		t.Errorf("no frames: got %v, want nil", *stdDev)
+	}
+}
+
+// The standard deviation is that of the per-frame scores of the log, which cycle through 90.5 to
+// 99.5: the population variance of 0 to 9 is 8.25.
+func TestParseVmafMetricsStdDev(t *testing.T) {
+	previous := config
+	t.Cleanup(func() { config = previous })
+	config = DefaultConfig()
+	logPath := filepath.Join(t.TempDir(), "vmaf.json")
+	log, err := os.Create(logPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := io.Copy(log, syntheticVmafLog(1000)); err != nil {
+		t.Fatal(err)
+	}
+	log.Close()
+
+	metrics := ParseVmafMetricsFromLogFile(logPath, nil)
+	if metrics.Err != nil {
+		t.Fatal(metrics.Err)
+	}
+	if metrics.StdDev == nil || math.Abs(*metrics.StdDev-math.Sqrt(8.25)) > 1e-9 {
+		t.Errorf("got standard deviation %v, want %v", metrics.StdDev, math.Sqrt(8.25))
+	}
+}
+
+// The penalty ranks a steady encode above a swinging encode of a higher mean, and leaves points
+// without a standard deviation ranked by their mean.
+func TestSelectionScorePenalty(t *testing.T) {
+	previous := config
+	t.Cleanup(func() { config = previous })
+	config = DefaultConfig()
+	steadyStdDev, swingingStdDev := 1.0, 15.0
+	steady := ConvexHullPoint{VmafScore: 85, VmafStdDev: &steadyStdDev}
+	swinging := ConvexHullPoint{VmafScore: 87, VmafStdDev: &swingingStdDev}
+
+	if steady.SelectionScore() >= swinging.SelectionScore() {
+		t.Errorf("without a penalty %v ranks above %v", steady.SelectionScore(), swinging.SelectionScore())
+	}
+	config.VmafStdDevPenalty = 0.5
+	if steady.SelectionScore() != 84.5 || swinging.SelectionScore() != 79.5 {
+		t.Errorf("with a 0.5 penalty got %v and %v, want 84.5 and 79.5", steady.SelectionScore(), swinging.SelectionScore())
+	}
+	if unscored := (ConvexHullPoint{VmafScore: 87}); unscored.SelectionScore() != 87 {
+		t.Errorf("without a standard deviation got %v, want 87", unscored.SelectionScore())
+	}
+}
@@ -0,0 +1,66 @@
+package main
+
+import (
+	"sort"
+)
+
+// LimitResolutionStep corrects a hull, highest rate first, so the resolution moves at most maxStep
+// ladder rungs between consecutive rates. Walking rates independently or computing the hull over a
+// grid can jump from 1080p at one rate to 360p at the next, which leaves an awkward gap in the
+// ladder. A point too many rungs away from the point before it is replaced by the point maxStep
+// rungs from that point towards it at the same rate, measured now unless it already was, and
+// flagged with StepLimited. Points off the ladder, as on a -grid, are left alone.
+func LimitResolutionStep(referenceVideoFilename string, referenceVideoResolution Resolution, hull []ConvexHullPoint, measured []ConvexHullPoint, maxStep int) []ConvexHullPoint {
+	descending := append([]ConvexHullPoint(nil), hull...)
+	sort.SliceStable(descending, func(i, j int) bool { return descending[i].Rate > descending[j].Rate })
+
+	ladder := LadderFor(referenceVideoResolution)
+	rungOf := func(resolution Resolution) int {
+		for i, rung := range ladder {
+			if rung == resolution {
+				return i
+			}
+		}
+		return -1
+	}
+
+	for i := 1; i < len(descending); i++ {
+		previous, current := rungOf(descending[i-1].Resolution), rungOf(descending[i].Resolution)
+		if previous < 0 || current < 0 || IntAbs(current-previous) <= maxStep {
+			continue
+		}
+		target := previous + maxStep
+		if current < previous {
+			target = previous - maxStep
+		}
+		point := descending[i]
+		replacement, err := measuredOrMeasure(referenceVideoFilename, referenceVideoResolution, measured, OperatingPoint{Resolution: ladder[target], Rate: point.Rate})
+		if err != nil {
+			videoLog(referenceVideoFilename).Error("Error limiting the resolution step, keeping the point", "resolution", point.Resolution.ToFilterString(), "rate", point.Rate, "err", err)
+			continue
+		}
+		videoLog(referenceVideoFilename).Info("Replacing point to limit the resolution step", "resolution", point.Resolution.ToFilterString(), "rate", point.Rate, "replacement", replacement.Resolution.ToFilterString(), "max_step", maxStep)
+		replacement.Profile, replacement.Level = point.Profile, point.Level
+		replacement.Status, replacement.Reason = "", ""
+		replacement.StepLimited = true
+		descending[i] = replacement
+	}
+	return descending
+}
+
+// measuredOrMeasure returns the measured point at the operating point, measuring it when it was not.
+func measuredOrMeasure(referenceVideoFilename string, referenceVideoResolution Resolution, measured []ConvexHullPoint, operatingPoint OperatingPoint) (ConvexHullPoint, error) {
+	for _, point := range measured {
+		if point.Resolution == operatingPoint.Resolution && point.Rate == operatingPoint.Rate && (point.Status == "" || point.Status == PointOk) {
+			return point, nil
+		}
+	}
+	point, err := measureOperatingPoint(referenceVideoFilename, referenceVideoResolution, operatingPoint)
+	if err != nil {
+		return ConvexHullPoint{}, err
+	}
+	attempt := point
+	attempt.Status = PointOk
+	RecordAttempt(referenceVideoFilename, attempt)
+	return point, nil
+}
@@ -0,0 +1,93 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"sync"
+)
+
+// Masters can carry more than one video stream, such as a thumbnail track or an attached cover
+// image, and ffmpeg's default stream selection may pick the wrong one. Every source is therefore
+// read through an explicit video stream: -video-stream when set, otherwise the primary video
+// stream, the one with the most pixels that is not an attached picture. The choice is made once
+// per source and every encode, scoring pass, clip, probe and scene detection maps that stream.
+// Files the tool writes itself have one video stream and always use the first.
+
+// AutoVideoStream selects the primary video stream.
+const AutoVideoStream = -1
+
+// videoStreams maps each source to the position of its selected stream among its video streams.
+var videoStreams = struct {
+	sync.Mutex
+	byFilename map[string]int
+}{byFilename: make(map[string]int)}
+
+// ProbeVideoStreams returns ffprobe's description of every video stream of filename, in order.
+func ProbeVideoStreams(filename string) ([]ProbeStream, error) {
+	output, err := FfprobeCommand("-v", "error", "-select_streams", "v", "-show_streams", "-of", "json", filename).Output()
+	if err != nil {
+		return nil, ClassifyFfmpegError(err, stderrOf(err), ErrProbeFailed)
+	}
+	var probe probeOutput
+	if err := json.Unmarshal(output, &probe); err != nil {
+		return nil, fmt.Errorf("%w: %s", ErrProbeFailed, err.Error())
+	}
+	if len(probe.Streams) == 0 {
+		return nil, fmt.Errorf("%w: no video stream", ErrProbeFailed)
+	}
+	return probe.Streams, nil
+}
+
+// PrimaryVideoStream returns the position of the stream with the most pixels that is not an
+// attached picture, the first on ties, or of the first stream when all are attached pictures.
+func PrimaryVideoStream(streams []ProbeStream) int {
+	primary := -1
+	for i, stream := range streams {
+		if stream.Disposition["attached_pic"] == 1 {
+			continue
+		}
+		if primary < 0 || stream.Width*stream.Height > streams[primary].Width*streams[primary].Height {
+			primary = i
+		}
+	}
+	if primary < 0 {
+		return 0
+	}
+	return primary
+}
+
+// SelectVideoStream selects the video stream of the source filename and registers it for every
+// command reading the source. It returns the selected stream.
+func SelectVideoStream(filename string) (ProbeStream, error) {
+	streams, err := ProbeVideoStreams(filename)
+	if err != nil {
+		return ProbeStream{}, err
+	}
+	selected := config.VideoStream
+	if selected == AutoVideoStream {
+		selected = PrimaryVideoStream(streams)
+	} else if selected >= len(streams) {
+		return ProbeStream{}, fmt.Errorf("-video-stream %d selects a stream beyond the %d video streams of %s", selected, len(streams), filename)
+	}
+	if len(streams) > 1 {
+		videoLog(filename).Info("Reading one of several video streams", "streams", len(streams), "stream", streams[selected].Index)
+	}
+	videoStreams.Lock()
+	defer videoStreams.Unlock()
+	videoStreams.byFilename[filename] = selected
+	return streams[selected], nil
+}
+
+// VideoStreamOf returns the position of the selected video stream of filename, the first stream
+// when none was selected.
+func VideoStreamOf(filename string) int {
+	videoStreams.Lock()
+	defer videoStreams.Unlock()
+	return videoStreams.byFilename[filename]
+}
+
+// VideoStreamSpecifier returns the stream specifier of the selected video stream of filename as
+// input number input, such as 0:v:1.
+func VideoStreamSpecifier(input int, filename string) string {
+	return fmt.Sprintf("%d:v:%d", input, VideoStreamOf(filename))
+}
@@ -0,0 +1,150 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+)
+
+// Asset outcomes recorded in the run summary.
+const (
+	AssetDone    = "done"
+	AssetFailed  = "failed"
+	AssetSkipped = "skipped"
+	// AssetPartial videos ran out of -asset-timeout and have a hull of the points measured in time.
+	AssetPartial = "partial"
+	// AssetAborted videos were not started because -on-error aborted the batch. -resume runs them.
+	AssetAborted = "aborted"
+)
+
+// Policies for the rest of the batch once a video fails.
+const (
+	ContinueOnError = "continue"
+	// AbortOnError starts no further videos, and records those not started as AssetAborted.
+	AbortOnError = "abort"
+)
+
+// ValidateOnError checks -on-error.
+func ValidateOnError() error {
+	if config.OnError != ContinueOnError && config.OnError != AbortOnError {
+		return fmt.Errorf("unknown policy %q, expected %s or %s", config.OnError, ContinueOnError, AbortOnError)
+	}
+	return nil
+}
+
+// AssetOutcome is how processing one video ended.
+type AssetOutcome struct {
+	Video  string
+	Status string
+	Reason string `json:",omitempty"`
+}
+
+// RunSummary collects the outcome of every video in a run. It is safe for concurrent use.
+type RunSummary struct {
+	mu       sync.Mutex
+	outcomes []AssetOutcome
+
+	// encodeUsage and vmafUsage sum the time of every encode and every scoring pass of the run.
+	encodeUsage ProcessUsage
+	vmafUsage   ProcessUsage
+}
+
+var summary RunSummary
+
+// Record adds the outcome of a video, and updates the batch manifest with it.
+func (s *RunSummary) Record(video string, status string, reason string) {
+	s.RecordResult(video, status, reason, nil)
+}
+
+// RecordResult records the outcome of a video like Record, and notifies the webhook of it together
+// with the result written for the video, if any.
+func (s *RunSummary) RecordResult(video string, status string, reason string, result *ConvexHullResult) {
+	s.mu.Lock()
+	s.outcomes = append(s.outcomes, AssetOutcome{Video: video, Status: status, Reason: reason})
+	s.mu.Unlock()
+
+	if err := batchManifest.Update(video, status, reason); err != nil {
+		videoLog(video).Error("Error updating manifest", "err", err)
+	}
+	webhook.Notify(WebhookNotification{AssetOutcome: AssetOutcome{Video: video, Status: status, Reason: reason}, Result: result})
+}
+
+// AddEncodeUsage adds the time of one encode to the run totals.
+func (s *RunSummary) AddEncodeUsage(usage ProcessUsage) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.encodeUsage = s.encodeUsage.Add(usage)
+}
+
+// AddVmafUsage adds the time of one scoring pass, or prescale, to the run totals.
+func (s *RunSummary) AddVmafUsage(usage ProcessUsage) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.vmafUsage = s.vmafUsage.Add(usage)
+}
+
+// Outcomes returns the recorded outcomes ordered by video.
+func (s *RunSummary) Outcomes() []AssetOutcome {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	outcomes := append([]AssetOutcome(nil), s.outcomes...)
+	sort.Slice(outcomes, func(i, j int) bool { return outcomes[i].Video < outcomes[j].Video })
+	return outcomes
+}
+
+// Failures returns the outcomes of the videos that failed.
+func (s *RunSummary) Failures() []AssetOutcome {
+	var failures []AssetOutcome
+	for _, outcome := range s.Outcomes() {
+		if outcome.Status == AssetFailed {
+			failures = append(failures, outcome)
+		}
+	}
+	return failures
+}
+
+// Aborted reports whether the batch should start no further videos under -on-error.
+func (s *RunSummary) Aborted() bool {
+	return config.OnError == AbortOnError && len(s.Failures()) > 0
+}
+
+// WriteFailureReport writes the failed videos, with the reason of each, to filename as JSON.
+func (s *RunSummary) WriteFailureReport(filename string) error {
+	failures := s.Failures()
+	if failures == nil {
+		failures = []AssetOutcome{}
+	}
+	if err := CreateOutputDirectory(filename); err != nil {
+		return err
+	}
+	return WriteJson(failures, filename)
+}
+
+// Print writes the count of each outcome, followed by every failure.
+func (s *RunSummary) Print() {
+	counts := make(map[string]int)
+	for _, outcome := range s.Outcomes() {
+		counts[outcome.Status]++
+	}
+	fmt.Printf("Summary: %d done, %d partial, %d skipped, %d failed\n", counts[AssetDone], counts[AssetPartial], counts[AssetSkipped], counts[AssetFailed])
+	if counts[AssetAborted] > 0 {
+		fmt.Printf("Aborted: %d videos not started after a failure\n", counts[AssetAborted])
+	}
+	s.mu.Lock()
+	encodeUsage, vmafUsage := s.encodeUsage, s.vmafUsage
+	s.mu.Unlock()
+	fmt.Printf("Encoding: %.1fs wall, %.1fs CPU. VMAF: %.1fs wall, %.1fs CPU\n", encodeUsage.WallSeconds, encodeUsage.CpuSeconds(), vmafUsage.WallSeconds, vmafUsage.CpuSeconds())
+	for _, failure := range s.Failures() {
+		fmt.Printf("Failed: %s: %s\n", failure.Video, failure.Reason)
+	}
+}
+
+// HasValidPoint reports whether the hull has at least one point with a valid VMAF score.
+func HasValidPoint(convexHull []ConvexHullPoint) bool {
+	for _, point := range convexHull {
+		if point.VmafScore >= 0 {
+			return true
+		}
+	}
+	return false
+}
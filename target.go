@@ -0,0 +1,168 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+	"strings"
+)
+
+// -target-vmaf answers "what is the cheapest way to deliver this quality" for each asset instead of
+// walking the hull. Every ladder resolution up to the source is binary searched, assuming VMAF grows
+// with the rate, for the lowest rate within -target-vmaf-precision kbps that reaches the target. A
+// resolution is only searched below the cheapest rate found so far, so resolutions that cannot win
+// cost a single encode. The result, the cheapest point reaching the target across all resolutions
+// together with the cost of finding it, is written next to the hull.
+
+// TargetSearch is the result of -target-vmaf for one asset.
+type TargetSearch struct {
+	TargetVmaf float64
+	// Point is the cheapest point reaching TargetVmaf, nil when no rate up to the top of the rate
+	// range reaches it at any resolution.
+	Point *ConvexHullPoint `json:",omitempty"`
+	// Measurements is the number of points encoded and scored, and Usage their total time.
+	Measurements int
+	Usage        ProcessUsage
+}
+
+// TargetVmafFilename returns the file the target search of the asset with the given hull file is written to.
+func TargetVmafFilename(convexHullFilename string) string {
+	return strings.TrimSuffix(convexHullFilename, filepath.Ext(convexHullFilename)) + ".target.json"
+}
+
+// ValidateTargetVmaf checks -target-vmaf and its precision.
+func ValidateTargetVmaf() error {
+	if config.TargetVmaf == 0 {
+		return nil
+	}
+	if config.TargetVmaf < 0 || config.TargetVmaf > 100 {
+		return fmt.Errorf("%g is not a VMAF score between 0 and 100", config.TargetVmaf)
+	}
+	if config.TargetVmafPrecision <= 0 {
+		return errors.New("-target-vmaf-precision must be positive")
+	}
+	if config.GridFile != "" || config.Extend || config.EncodeOnly || config.QuickBounds || config.Exhaustive {
+		return errors.New("it cannot be combined with -grid, -extend, -encode-only, -quick-bounds or -exhaustive")
+	}
+	return nil
+}
+
+// targetSearcher measures points for one target search and adds up their cost.
+type targetSearcher struct {
+	referenceVideoFilename   string
+	referenceVideoResolution Resolution
+	search                   *TargetSearch
+}
+
+// measure encodes and scores one point, recording it as an attempt.
+func (s targetSearcher) measure(resolution Resolution, rate int) (ConvexHullPoint, error) {
+	point, err := MeasureOperatingPoint(s.referenceVideoFilename, s.referenceVideoResolution, OperatingPoint{Resolution: resolution, Rate: rate})
+	if err != nil {
+		return point, err
+	}
+	s.search.Measurements++
+	if point.EncodeUsage != nil {
+		s.search.Usage = s.search.Usage.Add(*point.EncodeUsage)
+	}
+	if point.VmafUsage != nil {
+		s.search.Usage = s.search.Usage.Add(*point.VmafUsage)
+	}
+	attempt := point
+	attempt.Status = PointOk
+	RecordAttempt(s.referenceVideoFilename, attempt)
+	return point, nil
+}
+
+// lowestRateReaching binary searches rates between lower and upper kbps at resolution for the lowest
+// one reaching the target, to within the configured precision. It returns nil when upper does not
+// reach it.
+func (s targetSearcher) lowestRateReaching(resolution Resolution, lower int, upper int) (*ConvexHullPoint, error) {
+	best, err := s.measure(resolution, upper)
+	if err != nil || best.VmafScore < s.search.TargetVmaf {
+		return nil, err
+	}
+	if lower < upper {
+		point, err := s.measure(resolution, lower)
+		if err != nil {
+			return nil, err
+		}
+		if point.VmafScore >= s.search.TargetVmaf {
+			return &point, nil
+		}
+	}
+	for upper-lower > config.TargetVmafPrecision {
+		middle := lower + (upper-lower)/2
+		point, err := s.measure(resolution, middle)
+		if err != nil {
+			return nil, err
+		}
+		if point.VmafScore >= s.search.TargetVmaf {
+			best, upper = point, middle
+		} else {
+			lower = middle
+		}
+	}
+	return &best, nil
+}
+
+// SearchTargetVmaf returns the cheapest point reaching targetVmaf over every ladder resolution no
+// larger than the reference, searching rates in the -min-rate to -max-rate range of the source.
+func SearchTargetVmaf(referenceVideoFilename string, referenceVideoResolution Resolution, referenceVideoRate int, targetVmaf float64) (TargetSearch, error) {
+	lower, upper := RateBounds(referenceVideoRate)
+	if lower > upper || upper <= 0 {
+		return TargetSearch{}, fmt.Errorf("no rates between %d and %d kbps for a %d kbps source", lower, upper, referenceVideoRate)
+	}
+	lower = IntMax(IntMax(lower, config.MinRate), 1)
+	search := TargetSearch{TargetVmaf: targetVmaf}
+	searcher := targetSearcher{referenceVideoFilename: referenceVideoFilename, referenceVideoResolution: referenceVideoResolution, search: &search}
+	for _, resolution := range LadderFor(referenceVideoResolution) {
+		if resolution.Width > referenceVideoResolution.Width || resolution.Height > referenceVideoResolution.Height {
+			continue
+		}
+		ceiling := upper
+		if search.Point != nil {
+			// Only a rate below the cheapest found so far can win.
+			ceiling = search.Point.Rate - 1
+		}
+		if ceiling < lower {
+			break
+		}
+		point, err := searcher.lowestRateReaching(resolution, lower, ceiling)
+		if err != nil {
+			return search, fmt.Errorf("%s: %w", resolution.ToFilterString(), err)
+		}
+		if point != nil {
+			search.Point = point
+		}
+	}
+	return search, nil
+}
+
+// WriteTargetVmaf runs the target search of the asset and writes it with the run metadata and the
+// digest of the source, if any.
+func WriteTargetVmaf(videoFilename string, referenceFilename string, resolution Resolution, rate int, inputHash *InputDigest, targetFilename string) {
+	search, err := SearchTargetVmaf(referenceFilename, resolution, rate, config.TargetVmaf)
+	if err != nil {
+		videoLog(videoFilename).Error("Error searching target VMAF", "err", err)
+		summary.Record(videoFilename, AssetFailed, err.Error())
+		return
+	}
+	if search.Point == nil {
+		videoLog(videoFilename).Warn("No point reaches the target VMAF", "target_vmaf", search.TargetVmaf)
+	} else {
+		videoLog(videoFilename).Info("Found the cheapest point reaching the target VMAF", "target_vmaf", search.TargetVmaf, "resolution", search.Point.Resolution.ToFilterString(), "rate", search.Point.Rate, "measurements", search.Measurements)
+	}
+
+	if err := CreateOutputDirectory(targetFilename); err != nil {
+		videoLog(videoFilename).Error("Error creating output directory", "file", targetFilename, "err", err)
+		summary.Record(videoFilename, AssetFailed, err.Error())
+		return
+	}
+	result := ConvexHullResult{Metadata: runMetadata, TargetVmaf: &search, Attempts: TakeAttempts(referenceFilename), InputHash: inputHash}
+	err = WriteJson(result, targetFilename)
+	if err != nil {
+		summary.Record(videoFilename, AssetFailed, err.Error())
+		return
+	}
+	summary.RecordResult(videoFilename, AssetDone, "", &result)
+}
@@ -0,0 +1,163 @@
+package main
+
+import (
+	"log/slog"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"strings"
+	"sync"
+	"syscall"
+)
+
+// Every scratch directory of a run, such as extracted clips and downloaded variants, is created
+// inside one per-run temporary directory under -workdir, the system temporary directory by default.
+// Intermediate encodes and their VMAF logs are written there too unless -encode-dir is set, so
+// nothing is left next to the sources. Each user removes its own directory when done, but a run
+// that fails or is interrupted part way leaves them behind, so the run directory itself is removed
+// when the run returns, exits early through ExitRun or receives SIGINT or SIGTERM. -keep-temp keeps
+// it for debugging. The path is logged at startup.
+//
+// On a signal the running encodes and scoring passes are killed and waited for first, so none is
+// still writing into the directory as it is removed, and the webhook deliveries, the combined CSV
+// and the completed manifest are finished as at the end of a batch, see CloseRunOutputs.
+//
+// -keep-intermediates keeps the encodes and logs instead, mirroring the source directories under the
+// encode directory. Without -encode-dir that is the encodes directory of the run, which is then kept when
+// the rest of the run directory is removed.
+
+// runTempDir is the temporary directory of the run, empty before StartRunTempDir or in subcommands,
+// which then create their scratch directories in the system temporary directory.
+var runTempDir string
+
+var removeRunTempDir = func() {}
+
+// StartRunTempDir creates the run temporary directory and arranges for its removal on signals. The
+// returned function removes it, and may be called any number of times.
+func StartRunTempDir() (func(), error) {
+	if config.WorkDir != "" {
+		if err := os.MkdirAll(config.WorkDir, 0755); err != nil {
+			return nil, err
+		}
+	}
+	directory, err := os.MkdirTemp(config.WorkDir, "vmaf-run")
+	if err != nil {
+		return nil, err
+	}
+	runTempDir = directory
+	slog.Info("Temporary directory", "dir", directory)
+
+	var once sync.Once
+	removeRunTempDir = func() {
+		once.Do(func() {
+			if config.KeepTemp {
+				slog.Info("Keeping temporary directory", "dir", directory)
+				return
+			}
+			if err := removeRunTempDirContents(directory); err != nil {
+				slog.Error("Error removing temporary directory", "dir", directory, "err", err)
+			}
+		})
+	}
+
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGINT, syscall.SIGTERM)
+	go func() {
+		received := <-signals
+		slog.Warn("Received signal, stopping", "signal", received.String())
+		StopChildren()
+		CloseRunOutputs()
+		removeRunTempDir()
+		code := 1
+		if number, ok := received.(syscall.Signal); ok {
+			code = 128 + int(number)
+		}
+		os.Exit(code)
+	}()
+	return removeRunTempDir, nil
+}
+
+// removeRunTempDirContents removes the run temporary directory, or with -keep-intermediates everything
+// in it but the intermediates.
+func removeRunTempDirContents(directory string) error {
+	encodes := filepath.Join(directory, "encodes")
+	if !config.KeepIntermediates || config.EncodeDirectory != "" {
+		return os.RemoveAll(directory)
+	}
+	entries, err := os.ReadDir(directory)
+	if err != nil {
+		return err
+	}
+	for _, entry := range entries {
+		if path := filepath.Join(directory, entry.Name()); path != encodes {
+			if err := os.RemoveAll(path); err != nil {
+				return err
+			}
+		}
+	}
+	slog.Info("Keeping intermediate encodes and VMAF logs", "dir", encodes)
+	return nil
+}
+
+// RemoveIntermediate deletes an intermediate encode or libvmaf log unless -keep-intermediates is set.
+func RemoveIntermediate(filename string) {
+	if !config.KeepIntermediates {
+		os.Remove(filename)
+	}
+}
+
+// encodeRoot returns the directory the intermediate encodes are written under: -encode-dir, else
+// the run temporary directory, else none.
+func encodeRoot() string {
+	if config.EncodeDirectory != "" {
+		return config.EncodeDirectory
+	}
+	if runTempDir != "" {
+		return filepath.Join(runTempDir, "encodes")
+	}
+	return ""
+}
+
+// encodeDirectory returns the directory the intermediate encodes of the reference are written to:
+// under the encode root, mirroring -video-dir, and next to references already in a scratch
+// directory, such as clips, or when there is no root. With -keep-intermediates the encodes of
+// scratch references, which are removed with their directory, are mirrored under the root too.
+func encodeDirectory(referenceFilename string) string {
+	root := encodeRoot()
+	if root == "" || (inRunTempDir(referenceFilename) && !config.KeepIntermediates) {
+		return filepath.Dir(referenceFilename)
+	}
+	rel := relativeVideoPath(referenceFilename)
+	if inRunTempDir(referenceFilename) {
+		rel, _ = filepath.Rel(runTempDir, referenceFilename)
+	} else if rel == referenceFilename {
+		// References outside -video-dir, given as arguments, are mirrored by their absolute path.
+		absolute, err := filepath.Abs(referenceFilename)
+		if err == nil {
+			rel = strings.TrimPrefix(filepath.ToSlash(absolute), "/")
+		}
+	}
+	return filepath.Join(root, filepath.Dir(rel))
+}
+
+// withinDirectory reports whether filename lies inside directory.
+func withinDirectory(directory string, filename string) bool {
+	rel, err := filepath.Rel(directory, filename)
+	return err == nil && !strings.HasPrefix(rel, "..")
+}
+
+// inRunTempDir reports whether filename lies inside the run temporary directory.
+func inRunTempDir(filename string) bool {
+	return runTempDir != "" && withinDirectory(runTempDir, filename)
+}
+
+// ScratchDir creates a scratch directory for one use inside the run temporary directory.
+func ScratchDir(pattern string) (string, error) {
+	return os.MkdirTemp(runTempDir, pattern)
+}
+
+// ExitRun removes the run temporary directory and exits with code.
+func ExitRun(code int) {
+	removeRunTempDir()
+	os.Exit(code)
+}
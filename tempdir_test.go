@@ -0,0 +1,109 @@
+package main
+
+import (
+	"errors"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// startTestRunTempDir starts a run temporary directory under a fresh -workdir and returns the
+// workdir and the function removing the run directory.
+func startTestRunTempDir(t *testing.T) (string, func()) {
+	t.Helper()
+	previous, previousDir, previousRemove := config, runTempDir, removeRunTempDir
+	t.Cleanup(func() { config, runTempDir, removeRunTempDir = previous, previousDir, previousRemove })
+	config = DefaultConfig()
+	config.WorkDir = t.TempDir()
+	remove, err := StartRunTempDir()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return config.WorkDir, remove
+}
+
+// writeScratch leaves a clip and an intermediate encode in the run directory, as a walk does.
+func writeScratch(t *testing.T) string {
+	t.Helper()
+	scratch, err := ScratchDir("clip")
+	if err != nil {
+		t.Fatal(err)
+	}
+	encodes := filepath.Join(encodeRoot(), "videos")
+	if err := os.MkdirAll(encodes, 0755); err != nil {
+		t.Fatal(err)
+	}
+	for _, file := range []string{filepath.Join(scratch, "clip.mkv"), filepath.Join(encodes, "1920x1080_3000.mp4")} {
+		if err := os.WriteFile(file, []byte("frames"), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return encodes
+}
+
+func TestRunTempDirRemovedAfterRun(t *testing.T) {
+	workDir, remove := startTestRunTempDir(t)
+	writeScratch(t)
+
+	remove()
+	remove()
+	if entries, err := os.ReadDir(workDir); err != nil || len(entries) != 0 {
+		t.Errorf("-workdir holds %v after the run, %v", entries, err)
+	}
+}
+
+func TestRunTempDirKeepsIntermediates(t *testing.T) {
+	_, remove := startTestRunTempDir(t)
+	config.KeepIntermediates = true
+	encodes := writeScratch(t)
+
+	remove()
+	if entries, err := os.ReadDir(runTempDir); err != nil || len(entries) != 1 || entries[0].Name() != "encodes" {
+		t.Errorf("run directory holds %v, want the encodes alone, %v", entries, err)
+	}
+	if _, err := os.Stat(filepath.Join(encodes, "1920x1080_3000.mp4")); err != nil {
+		t.Error(err)
+	}
+}
+
+// An interrupted run kills and waits for its children, and starts none afterwards.
+func TestStopChildren(t *testing.T) {
+	if _, err := exec.LookPath("sleep"); err != nil {
+		t.Skip(err)
+	}
+	t.Cleanup(func() {
+		children.Lock()
+		children.stopped = false
+		children.Unlock()
+	})
+
+	failed := make(chan error, 1)
+	go func() {
+		_, err := RunMeasured(exec.Command("sleep", "60"), ErrEncodeFailed)
+		failed <- err
+	}()
+	for started := false; !started; time.Sleep(time.Millisecond) {
+		children.Lock()
+		started = len(children.running) > 0
+		children.Unlock()
+	}
+
+	stopped := time.Now()
+	StopChildren()
+	if elapsed := time.Since(stopped); elapsed > 10*time.Second {
+		t.Errorf("stopping took %s", elapsed)
+	}
+	select {
+	case err := <-failed:
+		if !errors.Is(err, ErrEncodeFailed) {
+			t.Errorf("killed process returned %v", err)
+		}
+	case <-time.After(10 * time.Second):
+		t.Fatal("killed process did not return")
+	}
+	if _, err := RunMeasured(exec.Command("sleep", "60"), ErrEncodeFailed); !errors.Is(err, ErrInterrupted) {
+		t.Errorf("process started after stopping returned %v", err)
+	}
+}
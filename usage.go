@@ -0,0 +1,122 @@
+package main
+
+import (
+	"errors"
+	"os/exec"
+	"sync"
+	"time"
+)
+
+// ProcessUsage is the wall-clock and CPU time consumed by one or more ffmpeg processes, for cost
+// modelling. CPU time is user plus system time as reported by the operating system after the
+// process exits.
+type ProcessUsage struct {
+	WallSeconds   float64
+	UserSeconds   float64
+	SystemSeconds float64
+}
+
+// CpuSeconds returns the total CPU time.
+func (usage ProcessUsage) CpuSeconds() float64 {
+	return usage.UserSeconds + usage.SystemSeconds
+}
+
+// Add returns the sum of both usages.
+func (usage ProcessUsage) Add(other ProcessUsage) ProcessUsage {
+	return ProcessUsage{
+		WallSeconds:   usage.WallSeconds + other.WallSeconds,
+		UserSeconds:   usage.UserSeconds + other.UserSeconds,
+		SystemSeconds: usage.SystemSeconds + other.SystemSeconds,
+	}
+}
+
+// RunMeasured runs cmd and returns the time it consumed, also when it fails. A failure is
+// classified from the end of stderr by ClassifyFfmpegError, falling back to the given kind.
+func RunMeasured(cmd *exec.Cmd, failure error) (ProcessUsage, error) {
+	var stderr stderrTail
+	if cmd.Stderr == nil {
+		cmd.Stderr = &stderr
+	}
+	start := time.Now()
+	err := ClassifyFfmpegError(runChild(cmd), stderr.String(), failure)
+	usage := ProcessUsage{WallSeconds: time.Since(start).Seconds()}
+	if cmd.ProcessState != nil {
+		usage.UserSeconds = cmd.ProcessState.UserTime().Seconds()
+		usage.SystemSeconds = cmd.ProcessState.SystemTime().Seconds()
+	}
+	return usage, err
+}
+
+// ErrInterrupted is returned for processes not started because the run received a signal.
+var ErrInterrupted = errors.New("run interrupted")
+
+// children holds the processes RunMeasured is running, each with a channel closed once it has
+// exited, so an interrupted run can stop them before it exits instead of leaving them running.
+var children = struct {
+	sync.Mutex
+	stopped bool
+	running map[*exec.Cmd]chan struct{}
+}{running: make(map[*exec.Cmd]chan struct{})}
+
+// runChild runs cmd like cmd.Run, unless StopChildren was called.
+func runChild(cmd *exec.Cmd) error {
+	children.Lock()
+	if children.stopped {
+		children.Unlock()
+		return ErrInterrupted
+	}
+	if err := cmd.Start(); err != nil {
+		children.Unlock()
+		return err
+	}
+	exited := make(chan struct{})
+	children.running[cmd] = exited
+	children.Unlock()
+
+	err := cmd.Wait()
+	children.Lock()
+	delete(children.running, cmd)
+	children.Unlock()
+	close(exited)
+	return err
+}
+
+// StopChildren kills the processes RunMeasured is running and waits for them to exit. No process
+// is started afterwards. The short ffprobe and probe encodes run outside of RunMeasured are left to
+// finish on their own.
+func StopChildren() {
+	children.Lock()
+	children.stopped = true
+	var exited []chan struct{}
+	for cmd, done := range children.running {
+		cmd.Process.Kill()
+		exited = append(exited, done)
+	}
+	children.Unlock()
+	for _, done := range exited {
+		<-done
+	}
+}
+
+// encodeUsages holds the usage of every encode by output filename until the point it belongs to
+// takes it.
+var encodeUsages = struct {
+	sync.Mutex
+	byFilename map[string]ProcessUsage
+}{byFilename: make(map[string]ProcessUsage)}
+
+// RecordEncodeUsage records the usage of the encode written to outputFilename.
+func RecordEncodeUsage(outputFilename string, usage ProcessUsage) {
+	encodeUsages.Lock()
+	defer encodeUsages.Unlock()
+	encodeUsages.byFilename[outputFilename] = usage
+}
+
+// TakeEncodeUsage returns and forgets the usage of the encode written to outputFilename.
+func TakeEncodeUsage(outputFilename string) ProcessUsage {
+	encodeUsages.Lock()
+	defer encodeUsages.Unlock()
+	usage := encodeUsages.byFilename[outputFilename]
+	delete(encodeUsages.byFilename, outputFilename)
+	return usage
+}
@@ -0,0 +1,291 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"log/slog"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// ConfigCheck is one check of the configuration, run before a batch and by validate-config.
+type ConfigCheck struct {
+	Name  string
+	Check func() error
+}
+
+// configChecks validate the flags. Some also apply them, such as -ladder and -grid, so they run
+// once at startup before anything else reads the configuration.
+var configChecks = []ConfigCheck{
+	{"-output-template", func() error { return ValidateOutputTemplate(config.OutputTemplate) }},
+	{"-encode-template", ValidateEncodeTemplate},
+	{"input discovery", ValidateInputDiscovery},
+	{"-dry-run", ValidateDryRun},
+	{"-on-error", ValidateOnError},
+	{"-vmaf-subsample", func() error {
+		if config.VmafSubsample < 1 {
+			return fmt.Errorf("subsample %d must be at least 1", config.VmafSubsample)
+		}
+		return nil
+	}},
+	{"-max-height", func() error {
+		if config.MaxHeight < 0 {
+			return fmt.Errorf("height %d must not be negative", config.MaxHeight)
+		}
+		return nil
+	}},
+	{"input filters", ValidateInputFilters},
+	{"-batch-size", func() error {
+		if config.BatchSize < 0 {
+			return fmt.Errorf("batch size %d must not be negative", config.BatchSize)
+		}
+		return nil
+	}},
+	{"-ladder-script", ValidateLadderScript},
+	{"-ladder or -ladder-file", func() error {
+		if config.LadderFile != "" {
+			return ApplyLadderFile(config.LadderFile)
+		}
+		return ApplyLadder(config.Ladder)
+	}},
+	{"-max-res or -min-res", ApplyResolutionRange},
+	{"-codec", ValidateCodec},
+	{"rate bounds", ValidateRateBounds},
+	{"target rates", ApplyRates},
+	{"encoder settings", ValidateEncoder},
+	{"-tiles", ValidateTiles},
+	{"-crf", ValidateCrf},
+	{"scoring window", func() error {
+		_, err := ActiveScoringWindow()
+		return err
+	}},
+	{"-ffmpeg-loglevel", ValidateFfmpegLogLevel},
+	{"-clip", ValidateClip},
+	{"-clips", ValidateClips},
+	{"scaling", ValidateScaling},
+	{"-display-resolutions", LoadDisplayResolutions},
+	{"-video-stream", func() error {
+		if config.VideoStream < AutoVideoStream {
+			return fmt.Errorf("stream %d is neither a stream position nor %d", config.VideoStream, AutoVideoStream)
+		}
+		return nil
+	}},
+	{"-vmaf-neg-gap", func() error {
+		if config.VmafNegGap && SelectVmafModel(Resolution{}) == NegVmafModel {
+			return fmt.Errorf("it compares against %s, which -vmaf-model already selects", NegVmafModel)
+		}
+		return nil
+	}},
+	{"-vmaf-sampling", ValidateVmafSampling},
+	{"-vmaf-planes", ValidateVmafPlanes},
+	{"-normalize-vmaf", ValidateNormalizeVmaf},
+	{"-vmaf-stddev-penalty", func() error {
+		if config.VmafStdDevPenalty < 0 {
+			return fmt.Errorf("%v is negative", config.VmafStdDevPenalty)
+		}
+		return nil
+	}},
+	{"-vmaf-fps", ValidateVmafFps},
+	{"-vsync or -async", ValidateSync},
+	{"-input-hash", ValidateInputHash},
+	{"-webhook", ValidateWebhook},
+	{"-heatmap", ValidateHeatmap},
+	{"-deinterlace", ValidateDeinterlace},
+	{"-sanity-check", ValidateSanityCheck},
+	{"-extend", func() error {
+		if config.Extend && (config.GridFile != "" || config.EncodeOnly) {
+			return errors.New("it cannot be combined with -grid or -encode-only")
+		}
+		return nil
+	}},
+	{"-quick-bounds", ValidateQuickBounds},
+	{"-target-vmaf", ValidateTargetVmaf},
+	{"-exhaustive", func() error {
+		if config.Exhaustive && (config.GridFile != "" || config.Extend || config.EncodeOnly || config.QuickBounds) {
+			return errors.New("it cannot be combined with -grid, -extend, -encode-only or -quick-bounds")
+		}
+		return nil
+	}},
+	{"-max-resolution-step", func() error {
+		if config.MaxResolutionStep < 0 {
+			return fmt.Errorf("step %d must not be negative", config.MaxResolutionStep)
+		}
+		return nil
+	}},
+	{"-grid", func() error {
+		if config.GridFile == "" {
+			return nil
+		}
+		grid, err := ReadOperatingGrid(config.GridFile)
+		operatingGrid = grid
+		return err
+	}},
+}
+
+// scoringChecks are the configChecks of the scoring flags, run by the hls and ab subcommands, which
+// score without a batch.
+var scoringChecks = []ConfigCheck{
+	{"scaling", ValidateScaling},
+	{"-display-resolutions", LoadDisplayResolutions},
+	{"-vmaf-sampling", ValidateVmafSampling},
+	{"-vmaf-planes", ValidateVmafPlanes},
+	{"-vmaf-fps", ValidateVmafFps},
+}
+
+// CheckScoringFlags runs the scoringChecks and reports whether they all passed, logging the first
+// that failed.
+func CheckScoringFlags() bool {
+	for _, check := range scoringChecks {
+		if err := check.Check(); err != nil {
+			slog.Error("Invalid "+check.Name, "err", err)
+			return false
+		}
+	}
+	return true
+}
+
+// ffmpegListing returns the output of an ffmpeg listing such as -encoders or -filters.
+func ffmpegListing(option string) (string, error) {
+	output, err := exec.Command(config.FfmpegPath, "-hide_banner", option).Output()
+	return string(output), err
+}
+
+// listsName reports whether an ffmpeg listing has an entry called name.
+func listsName(listing string, name string) bool {
+	for _, line := range strings.Split(listing, "\n") {
+		fields := strings.Fields(line)
+		if len(fields) >= 2 && fields[1] == name {
+			return true
+		}
+	}
+	return false
+}
+
+// writableDirectory reports whether files can be created in directory, or in the closest existing
+// parent directory when it does not exist yet.
+func writableDirectory(directory string) error {
+	for {
+		if info, err := os.Stat(directory); err == nil {
+			if !info.IsDir() {
+				return fmt.Errorf("%s is not a directory", directory)
+			}
+			file, err := os.CreateTemp(directory, ".vmaf-write-check")
+			if err != nil {
+				return err
+			}
+			file.Close()
+			return os.Remove(file.Name())
+		}
+		parent := filepath.Dir(directory)
+		if parent == directory {
+			return fmt.Errorf("no existing parent of %s", directory)
+		}
+		directory = parent
+	}
+}
+
+// environmentChecks check that the configured tools, models, inputs and outputs are usable. args are
+// the videos given as arguments, see InputFilenames.
+func environmentChecks(args []string) []ConfigCheck {
+	var checks []ConfigCheck
+	for _, name := range requiredBinaries() {
+		name := name
+		checks = append(checks, ConfigCheck{name, func() error { return FindBinary(name) }})
+	}
+	checks = append(checks, capabilityChecks(true, !config.EncodeOnly)...)
+	for _, model := range []string{config.VmafModel, config.HdrVmafModel} {
+		if isVmafModelPath(model) {
+			model := model
+			checks = append(checks, ConfigCheck{"model " + model, func() error {
+				_, err := os.Stat(model)
+				return err
+			}})
+		}
+	}
+	if config.Hwaccel != "" && config.Hwaccel != "none" {
+		checks = append(checks, ConfigCheck{"-hwaccel " + config.Hwaccel, func() error {
+			hwaccels, err := AvailableHwaccels()
+			if err != nil {
+				return err
+			}
+			for _, hwaccel := range hwaccels {
+				if hwaccel == config.Hwaccel {
+					return nil
+				}
+			}
+			return fmt.Errorf("ffmpeg only supports %s", strings.Join(hwaccels, ", "))
+		}})
+	}
+
+	filenames, err := InputFilenames(args)
+	inputs := "-input-list " + config.InputList
+	if len(args) > 0 {
+		inputs = "video arguments"
+	} else if config.Scan {
+		inputs = "-scan " + config.VideoDirectory
+	}
+	checks = append(checks, ConfigCheck{inputs, func() error { return err }})
+	if config.Overrides != "" {
+		checks = append(checks, ConfigCheck{"-overrides " + config.Overrides, func() error {
+			overrides, err := ReadVideoOverrides(config.Overrides)
+			if err != nil {
+				return err
+			}
+			for video, override := range overrides {
+				restore, err := ApplyVideoOverrides(override)
+				if err != nil {
+					return fmt.Errorf("%s: %w", video, err)
+				}
+				restore()
+			}
+			return nil
+		}})
+	}
+	outputDirectories := make(map[string]bool)
+	for _, videoFilename := range filenames {
+		videoFilename := videoFilename
+		checks = append(checks, ConfigCheck{"video " + videoFilename, func() error {
+			_, err := os.Stat(videoFilename)
+			return err
+		}})
+		outputDirectories[filepath.Dir(ExpandOutputTemplate(config.OutputTemplate, videoFilename))] = true
+	}
+	for directory := range outputDirectories {
+		directory := directory
+		checks = append(checks, ConfigCheck{"output directory " + directory, func() error { return writableDirectory(directory) }})
+	}
+	return checks
+}
+
+// RunValidateConfigCommand implements the validate-config subcommand: validate-config [flags]
+// [video ...]. It checks the flags and the environment a batch with them would run in without
+// processing any video.
+func RunValidateConfigCommand(args []string) int {
+	flags := flag.NewFlagSet("validate-config", flag.ExitOnError)
+	config.RegisterFlags(flags)
+	ParseFlags(flags, args)
+
+	failures := 0
+	run := func(checks []ConfigCheck) {
+		for _, check := range checks {
+			if err := check.Check(); err != nil {
+				fmt.Printf("FAIL %s: %s\n", check.Name, err.Error())
+				failures++
+			} else {
+				fmt.Printf("PASS %s\n", check.Name)
+			}
+		}
+	}
+	run(configChecks)
+	run(environmentChecks(flags.Args()))
+
+	if failures > 0 {
+		fmt.Printf("%d checks failed\n", failures)
+		return 1
+	}
+	fmt.Printf("All checks passed\n")
+	return 0
+}
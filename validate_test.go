@@ -0,0 +1,59 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// Each video check stats its own video, so a missing video fails wherever it is in the list.
+func TestEnvironmentChecksVideos(t *testing.T) {
+	previous := config
+	t.Cleanup(func() { config = previous })
+	config = DefaultConfig()
+	directory := t.TempDir()
+	videos := []string{filepath.Join(directory, "first.mp4"), filepath.Join(directory, "missing.mp4"), filepath.Join(directory, "last.mp4")}
+	for _, video := range []string{videos[0], videos[2]} {
+		if err := os.WriteFile(video, []byte("frames"), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	checked := 0
+	for _, check := range environmentChecks(videos) {
+		video, ok := strings.CutPrefix(check.Name, "video ")
+		if !ok || video == "arguments" {
+			continue
+		}
+		checked++
+		if err := check.Check(); (err != nil) != (video == videos[1]) {
+			t.Errorf("%s: got %v", check.Name, err)
+		}
+	}
+	if checked != len(videos) {
+		t.Errorf("checked %d videos, want %d", checked, len(videos))
+	}
+}
+
+// The scoring subcommands load -display-resolutions and reject the scoring flags a batch would.
+func TestCheckScoringFlags(t *testing.T) {
+	previous, previousResolutions := config, displayResolutions
+	t.Cleanup(func() { config, displayResolutions = previous, previousResolutions })
+	config = DefaultConfig()
+	config.DisplayResolutions = filepath.Join(t.TempDir(), "display.csv")
+	if err := os.WriteFile(config.DisplayResolutions, []byte("rung,display\n640x360,1920x1080\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	if !CheckScoringFlags() {
+		t.Fatal("valid scoring flags rejected")
+	}
+	if display := DisplayResolutionOf(Resolution{Width: 640, Height: 360}); display == nil || *display != (Resolution{Width: 1920, Height: 1080}) {
+		t.Errorf("360p rung is displayed at %v, want 1920x1080", display)
+	}
+	config.VmafSampling = "keyframes"
+	if CheckScoringFlags() {
+		t.Error("-vmaf-sampling keyframes accepted")
+	}
+}
@@ -0,0 +1,127 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// The libvmaf JSON log is decoded as a stream so that the per-frame array of a long asset, which
+// can run to hundreds of megabytes, is never held in memory at once.
+
+type vmafLogPooledMetric struct {
+	Mean float64 `json:"mean"`
+}
+
+// VmafLogFrame is one entry of the per-frame array of a libvmaf log.
+type VmafLogFrame struct {
+	FrameNum int                `json:"frameNum"`
+	Metrics  map[string]float64 `json:"metrics"`
+}
+
+// ParseVmafLog reads the pooled metrics from a libvmaf JSON log. Frames are decoded one at a time
+// and passed to onFrame when it is not nil. Errors wrap ErrVmafParse.
+func ParseVmafLog(reader io.Reader, onFrame func(frame VmafLogFrame)) (VmafMetrics, error) {
+	metrics, err := parseVmafLog(reader, onFrame)
+	if err != nil {
+		return VmafMetrics{}, fmt.Errorf("%w: %s", ErrVmafParse, err.Error())
+	}
+	return metrics, nil
+}
+
+func parseVmafLog(reader io.Reader, onFrame func(frame VmafLogFrame)) (VmafMetrics, error) {
+	decoder := json.NewDecoder(reader)
+	if err := expectDelim(decoder, '{'); err != nil {
+		return VmafMetrics{}, err
+	}
+
+	var pooledMetrics map[string]vmafLogPooledMetric
+	for decoder.More() {
+		token, err := decoder.Token()
+		if err != nil {
+			return VmafMetrics{}, err
+		}
+		key, _ := token.(string)
+
+		switch key {
+		case "pooled_metrics":
+			if err := decoder.Decode(&pooledMetrics); err != nil {
+				return VmafMetrics{}, fmt.Errorf("decoding pooled_metrics: %w", err)
+			}
+		case "frames":
+			if err := decodeFrames(decoder, onFrame); err != nil {
+				return VmafMetrics{}, fmt.Errorf("decoding frames: %w", err)
+			}
+		default:
+			if err := skipValue(decoder); err != nil {
+				return VmafMetrics{}, err
+			}
+		}
+	}
+
+	vmaf, ok := pooledMetrics["vmaf"]
+	if !ok {
+		return VmafMetrics{}, errors.New("log has no pooled vmaf metric")
+	}
+
+	metrics := VmafMetrics{Mean: vmaf.Mean}
+	ciLow, hasLow := pooledMetrics["vmaf_ci_p95_lo"]
+	ciHigh, hasHigh := pooledMetrics["vmaf_ci_p95_hi"]
+	if hasLow && hasHigh {
+		metrics.Ci = &ConfidenceInterval{Low: ciLow.Mean, High: ciHigh.Mean}
+	}
+	if neg, ok := pooledMetrics[negVmafMetric]; ok {
+		metrics.NegMean = &neg.Mean
+	}
+	metrics.Chroma = chromaMetricsFrom(pooledMetrics)
+	return metrics, nil
+}
+
+func decodeFrames(decoder *json.Decoder, onFrame func(frame VmafLogFrame)) error {
+	if onFrame == nil {
+		return skipValue(decoder)
+	}
+	if err := expectDelim(decoder, '['); err != nil {
+		return err
+	}
+	for decoder.More() {
+		var frame VmafLogFrame
+		if err := decoder.Decode(&frame); err != nil {
+			return err
+		}
+		onFrame(frame)
+	}
+	return expectDelim(decoder, ']')
+}
+
+func expectDelim(decoder *json.Decoder, delim json.Delim) error {
+	token, err := decoder.Token()
+	if err != nil {
+		return err
+	}
+	if token != delim {
+		return fmt.Errorf("expected %q, found %v", delim, token)
+	}
+	return nil
+}
+
+// skipValue consumes the next value token by token, so nested arrays are skipped without buffering them.
+func skipValue(decoder *json.Decoder) error {
+	depth := 0
+	for {
+		token, err := decoder.Token()
+		if err != nil {
+			return err
+		}
+		switch token {
+		case json.Delim('{'), json.Delim('['):
+			depth++
+		case json.Delim('}'), json.Delim(']'):
+			depth--
+		}
+		if depth == 0 {
+			return nil
+		}
+	}
+}
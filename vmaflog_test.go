@@ -0,0 +1,100 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"runtime"
+	"strings"
+	"testing"
+)
+
+// frameLogReader generates the per-frame array of a libvmaf log without holding it in memory.
+type frameLogReader struct {
+	frames, next int
+	pending      bytes.Buffer
+}
+
+func (r *frameLogReader) Read(p []byte) (int, error) {
+	for r.pending.Len() < len(p) && r.next < r.frames {
+		if r.next > 0 {
+			r.pending.WriteByte(',')
+		}
+		fmt.Fprintf(&r.pending, `{"frameNum": %d, "metrics": {"integer_adm2": 0.981, "integer_motion": 3.5, "vmaf": %d.5}}`, r.next, 90+r.next%10)
+		r.next++
+	}
+	if r.pending.Len() == 0 {
+		return 0, io.EOF
+	}
+	return r.pending.Read(p)
+}
+
+// syntheticVmafLog returns a libvmaf log of frames frames, in the order libvmaf writes it.
+func syntheticVmafLog(frames int) io.Reader {
+	return io.MultiReader(
+		strings.NewReader(`{"version": "3.0.0", "fps": 24.0, "frames": [`),
+		&frameLogReader{frames: frames},
+		strings.NewReader(`], "pooled_metrics": {"vmaf": {"min": 90.5, "max": 99.5, "mean": 95.0, "harmonic_mean": 94.9}, "vmaf_ci_p95_lo": {"mean": 94.1}, "vmaf_ci_p95_hi": {"mean": 95.8}}, "aggregate_metrics": {}}`),
+	)
+}
+
+func TestParseVmafLog(t *testing.T) {
+	frames := 0
+	metrics, err := ParseVmafLog(syntheticVmafLog(100), func(frame VmafLogFrame) {
+		if frame.FrameNum != frames {
+			t.Errorf("frame %d read as frame %d", frames, frame.FrameNum)
+		}
+		frames++
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if metrics.Mean != 95.0 || metrics.Ci == nil || metrics.Ci.Low != 94.1 || metrics.Ci.High != 95.8 {
+		t.Errorf("got %+v, expected mean 95 in [94.1, 95.8]", metrics)
+	}
+	if frames != 100 {
+		t.Errorf("read %d frames, expected 100", frames)
+	}
+}
+
+func TestParseVmafLogErrors(t *testing.T) {
+	for _, log := range []string{``, `[]`, `{"frames": []}`, `{"pooled_metrics": {"vmaf": {"mean": 9`} {
+		if _, err := ParseVmafLog(strings.NewReader(log), nil); err == nil {
+			t.Errorf("%q parsed without error", log)
+		}
+	}
+}
+
+func TestParseVmafLogMemoryIsBounded(t *testing.T) {
+	if testing.Short() {
+		t.Skip("decodes a large log")
+	}
+	// About 50 MB of frames, at almost 6 hours of 24 fps video, more than the heap may grow by.
+	const frames = 500000
+	const maxHeapGrowth = 32 << 20
+
+	var stats runtime.MemStats
+	runtime.GC()
+	runtime.ReadMemStats(&stats)
+	baseline := stats.HeapAlloc
+	var peak uint64
+	sampled := 0
+	metrics, err := ParseVmafLog(syntheticVmafLog(frames), func(frame VmafLogFrame) {
+		sampled++
+		if sampled%100000 == 0 {
+			runtime.ReadMemStats(&stats)
+			if stats.HeapAlloc > peak {
+				peak = stats.HeapAlloc
+			}
+		}
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if metrics.Mean != 95.0 || sampled != frames {
+		t.Fatalf("got mean %f over %d frames, expected 95 over %d", metrics.Mean, sampled, frames)
+	}
+	if peak > baseline+maxHeapGrowth {
+		t.Errorf("heap grew by %d MB while decoding, expected at most %d MB", (peak-baseline)>>20, maxHeapGrowth>>20)
+	}
+}
@@ -4,13 +4,20 @@ import (
 	"bufio"
 	"encoding/json"
 	"errors"
+	"flag"
 	"fmt"
 	vidio "github.com/AlexEidt/Vidio"
-	"io/ioutil"
+	"io"
+	"log/slog"
+	"math"
 	"os"
 	"os/exec"
+	"path/filepath"
+	"sort"
+	"strconv"
 	"strings"
 	"sync"
+	"time"
 )
 
 type Resolution struct {
@@ -22,7 +29,8 @@ func (resolution *Resolution) ToFilterString() string {
 	return fmt.Sprintf("%dx%d", resolution.Width, resolution.Height)
 }
 
-var resolutions = []Resolution{{2160, 3840},
+var resolutions = []Resolution{{4320, 7680},
+	{2160, 3840},
 	{1440, 2560},
 	{1080, 1920},
 	{720, 1280},
@@ -38,10 +46,87 @@ type ConvexHullPoint struct {
 	Resolution Resolution
 	Rate       int
 	VmafScore  float64
+	VmafModel  string              `json:",omitempty"`
+	VmafCi     *ConfidenceInterval `json:",omitempty"`
+
+	// VmafNegScore is the NEG model score with -vmaf-neg-gap. A score well below VmafScore means the
+	// encode gains VMAF through enhancement, such as sharpening, rather than fidelity.
+	VmafNegScore *float64 `json:",omitempty"`
+
+	// VmafStdDev is the standard deviation of the frame scores, see stddev.go.
+	VmafStdDev *float64 `json:",omitempty"`
+
+	// DisplayResolution is the resolution the point was scored at with -display-resolutions.
+	DisplayResolution *Resolution `json:",omitempty"`
+
+	// VmafNormalized is VmafScore relative to the source scored against itself with -normalize-vmaf.
+	VmafNormalized *float64 `json:",omitempty"`
+
+	// Chroma are the chroma-aware metrics of the point with -vmaf-planes chroma.
+	Chroma *ChromaMetrics `json:",omitempty"`
+
+	// Profile and Level are the constraints the point was encoded under, when set.
+	Profile string `json:",omitempty"`
+	Level   string `json:",omitempty"`
+
+	// FrameCountMismatch is set when the encode and reference frame counts disagree, so the score
+	// may be misaligned rather than low.
+	FrameCountMismatch string `json:",omitempty"`
+
+	// VmafClamped is set when libvmaf clamped the score at 100, so the true quality may be higher.
+	VmafClamped bool `json:",omitempty"`
+
+	// HysteresisApplied is set when a lower resolution scored better but by less than the minimum
+	// VMAF gain, so the previous resolution was kept.
+	HysteresisApplied bool `json:",omitempty"`
+
+	// ResolutionCorrected is set when the point replaced one at a lower resolution than a lower rate
+	// used, see EnforceMonotonicResolution.
+	ResolutionCorrected bool `json:",omitempty"`
+
+	// StepLimited is set when the point replaced one more rungs away from the previous rate's
+	// resolution than -max-resolution-step allows, see LimitResolutionStep.
+	StepLimited bool `json:",omitempty"`
+
+	// ClipScores are the scores of every clip with -clips; VmafScore is their frame weighted mean.
+	ClipScores []ClipScore `json:",omitempty"`
+
+	// EncodeTiming is the achieved rate of the encode and what it was computed from, see probe.go.
+	EncodeTiming *EncodeTiming `json:",omitempty"`
+
+	// EncodeUsage and VmafUsage are the time spent encoding and scoring the point.
+	EncodeUsage *ProcessUsage `json:",omitempty"`
+	VmafUsage   *ProcessUsage `json:",omitempty"`
+
+	// Status and Reason describe the outcome of an attempted point, see PointOk. They are only set
+	// in the attempts, every point of the hull itself is ok.
+	Status string `json:",omitempty"`
+	Reason string `json:",omitempty"`
+
+	// Hashes of the ffmpeg commands that produced the point, recorded when auditing is enabled.
+	EncodeCommandHash string `json:",omitempty"`
+	VmafCommandHash   string `json:",omitempty"`
 }
 
+const (
+	StandardVmafModel = "vmaf_v0.6.1"
+	PhoneVmafModel    = "vmaf_v0.6.1_phone"
+	// UhdVmafModel is trained for 4K displays viewed at 1.5 times the screen height.
+	UhdVmafModel       = "vmaf_4k_v0.6.1"
+	BootstrapVmafModel = "vmaf_b_v0.6.3"
+	// NegVmafModel is the no enhancement gain model, which does not reward sharpening.
+	NegVmafModel = "vmaf_v0.6.1neg"
+)
+
+// uhdModelMinShortSide is the short side of the scoring resolution from which -auto-4k-model
+// scores with UhdVmafModel.
+const uhdModelMinShortSide = 2160
+
+// negVmafMetric is the name the NEG model is reported under when scored next to another model.
+const negVmafMetric = "vmaf_neg"
+
 func GetNextResolution(resolution Resolution) (Resolution, error) {
-	for _, res := range resolutions {
+	for _, res := range LadderFor(resolution) {
 		if res.Height < resolution.Height {
 			return res, nil
 		}
@@ -56,11 +141,74 @@ func IntMax(a int, b int) int {
 	return b
 }
 
+func IntAbs(a int) int {
+	if a < 0 {
+		return -a
+	}
+	return a
+}
+
+// RateBounds returns the inclusive range of target rates for a source at the given rate, combining
+// the absolute bounds with the bounds relative to the source rate. -min-rate does not bound listed
+// rate steps, which may well go below the default.
+func RateBounds(rate int) (int, int) {
+	lower := config.MinRate
+	if ladderRates != nil {
+		lower = 0
+	}
+	upper := IntMin(rate, config.MaxRate)
+	if config.MinRateFraction > 0 {
+		lower = IntMax(lower, int(math.Ceil(config.MinRateFraction*float64(rate))))
+	}
+	if config.MaxRateFraction > 0 {
+		upper = IntMin(upper, int(config.MaxRateFraction*float64(rate)))
+	}
+	return lower, upper
+}
+
 func GetTargetRates(rate int) []int {
+	lower, upper := RateBounds(rate)
+	return targetRatesBetween(lower, upper)
+}
+
+// InfeasibleRates returns the rates the configuration asks for that lie above the source rate.
+func InfeasibleRates(rate int) []int {
+	if config.MaxRateFraction > 0 || rate >= config.MaxRate {
+		return nil
+	}
+	lower, _ := RateBounds(rate)
+	var infeasible []int
+	for _, targetRate := range targetRatesBetween(lower, config.MaxRate) {
+		if targetRate > rate {
+			infeasible = append(infeasible, targetRate)
+		}
+	}
+	return infeasible
+}
+
+// targetRatesBetween returns the target rates within the bounds, highest first.
+func targetRatesBetween(lower int, upper int) []int {
 	var targetRates []int
 
-	// Add all rates starting at 500, in increments of 500, until we reach the rate or 10,000.
-	for i := 500; i <= IntMin(rate, 10000); i += 500 {
+	// Ladder presets with their own rate steps use those within the bounds, highest first.
+	if ladderRates != nil {
+		for _, ladderRate := range ladderRates {
+			if ladderRate >= lower && ladderRate <= upper {
+				targetRates = append(targetRates, ladderRate)
+			}
+		}
+		sort.Sort(sort.Reverse(sort.IntSlice(targetRates)))
+		return targetRates
+	}
+	if config.RateSpacing == LogRateSpacing {
+		return logRatesBetween(lower, upper, config.RatePoints)
+	}
+
+	// Add all multiples of the step between the rate bounds, by default starting at 500 until we
+	// reach the rate or 10,000.
+	rateStep := config.RateStep
+	start := IntMax(rateStep, (lower+rateStep-1)/rateStep*rateStep)
+	for i := start; i <= upper; i += rateStep {
 		targetRates = append(targetRates, i)
 	}
 
@@ -74,120 +222,545 @@ func GetTargetRates(rate int) []int {
 	return targetRates
 }
 
-// EncodeVideo Encodes the video and returns the encoded file name.
-func EncodeVideo(filename string, outputFilename string, resolution Resolution, rate int, success chan bool) {
-	fmt.Printf("Encoding %s to %d kbps and resolution %dx%d\n", filename, rate, resolution.Height, resolution.Width)
+// Spacings of the generated target rates.
+const (
+	// LinearRateSpacing steps the rates by -rate-step.
+	LinearRateSpacing = "linear"
+	// LogRateSpacing spaces -rate-points rates evenly on the logarithm of the rate, so they are
+	// dense at low rates where VMAF changes quickly and sparse at high rates where it saturates.
+	LogRateSpacing = "log"
+)
 
-	cmd := exec.Command("ffmpeg", "-i", filename, "-c:v", "libx264", "-b:v", fmt.Sprintf("%dk", rate), "-s", fmt.Sprintf("%dx%d", resolution.Width, resolution.Height), outputFilename)
-	fmt.Printf("Executing command: %s\n", cmd.String())
-	err := cmd.Run()
-	if err != nil {
-		fmt.Printf("Error encoding video: %s\n", err.Error())
-		success <- false
+// logRatesBetween returns up to points rates spaced evenly on the logarithm of the rate from lower,
+// or one -rate-step when lower is zero, to upper, highest first. Rates that round to the same kbps
+// are kept once.
+func logRatesBetween(lower int, upper int, points int) []int {
+	if lower <= 0 {
+		lower = config.RateStep
+	}
+	if lower > upper {
+		return nil
+	}
+	if points == 1 || lower == upper {
+		return []int{upper}
+	}
+	var targetRates []int
+	ratio := math.Log(float64(upper) / float64(lower))
+	for i := points - 1; i >= 0; i-- {
+		rate := int(math.Round(float64(lower) * math.Exp(ratio*float64(i)/float64(points-1))))
+		if len(targetRates) == 0 || rate != targetRates[len(targetRates)-1] {
+			targetRates = append(targetRates, rate)
+		}
+	}
+	return targetRates
+}
+
+// ApplyRates makes the -rates list, when given, the rate steps of the walk in place of those of
+// the ladder, and checks -rate-step.
+func ApplyRates() error {
+	if config.RateStep <= 0 {
+		return fmt.Errorf("rate step %d must be positive", config.RateStep)
+	}
+	if config.RateSpacing != LinearRateSpacing && config.RateSpacing != LogRateSpacing {
+		return fmt.Errorf("unknown rate spacing %q, expected %s or %s", config.RateSpacing, LinearRateSpacing, LogRateSpacing)
+	}
+	if config.RatePoints <= 0 {
+		return fmt.Errorf("rate points %d must be positive", config.RatePoints)
+	}
+	if config.Rates == "" {
+		return nil
+	}
+	var rates []int
+	seen := make(map[int]bool)
+	for _, entry := range strings.Split(config.Rates, ",") {
+		rate, err := strconv.Atoi(strings.TrimSpace(entry))
+		if err != nil || rate <= 0 {
+			return fmt.Errorf("rate %q is not a positive number of kbps", entry)
+		}
+		if seen[rate] {
+			return fmt.Errorf("rate %d is listed twice", rate)
+		}
+		seen[rate] = true
+		rates = append(rates, rate)
+	}
+	sort.Sort(sort.Reverse(sort.IntSlice(rates)))
+	ladderRates = rates
+	return nil
+}
+
+// ValidateRateBounds reports an error when the configured rate bounds cannot produce any rate.
+func ValidateRateBounds() error {
+	if config.MinRate < 0 || config.MaxRate <= 0 || config.MinRate > config.MaxRate {
+		return fmt.Errorf("rate range %d-%d kbps is empty", config.MinRate, config.MaxRate)
+	}
+	for _, fraction := range []float64{config.MinRateFraction, config.MaxRateFraction} {
+		if fraction < 0 || fraction > 1 {
+			return fmt.Errorf("rate fraction %g must be between 0 and 1", fraction)
+		}
+	}
+	if config.MaxRateFraction > 0 && config.MinRateFraction >= config.MaxRateFraction {
+		return fmt.Errorf("minimum rate fraction %g must be below the maximum %g", config.MinRateFraction, config.MaxRateFraction)
+	}
+	return nil
+}
+
+// BuildEncodeCommand returns the ffmpeg command that encodes filename to outputFilename, the second
+// pass of a two-pass encoder.
+func BuildEncodeCommand(filename string, outputFilename string, resolution Resolution, rate int) *exec.Cmd {
+	args := encodeArgs(filename, resolution, rate)
+	if twoPass() {
+		args = append(args, "-pass", "2", "-passlogfile", PassLogPrefix(outputFilename))
+	}
+	return FfmpegCommand(append(args, outputFilename)...)
+}
+
+// BuildFirstPassCommand returns the analysis pass of a two-pass encode of filename to
+// outputFilename, which writes only its statistics, or nil for single pass encoders.
+func BuildFirstPassCommand(filename string, outputFilename string, resolution Resolution, rate int) *exec.Cmd {
+	if !twoPass() {
+		return nil
+	}
+	args := append(encodeArgs(filename, resolution, rate), "-pass", "1", "-passlogfile", PassLogPrefix(outputFilename), "-an", "-f", "null", os.DevNull)
+	return FfmpegCommand(args...)
+}
+
+// encodeArgs returns the ffmpeg options of an encode of filename, without the output.
+func encodeArgs(filename string, resolution Resolution, rate int) []string {
+	args := append(SourceInputArgs(filename), "-map", VideoStreamSpecifier(0, filename))
+	args = append(args, SyncEncodeArgs()...)
+	args = append(args, ActiveEncoder().BuildArgs(ActiveCodecProfile(), rate)...)
+	if deinterlace := DeinterlaceFilter(filename); deinterlace != "" {
+		scale := "scale=" + resolution.ToFilterString()
+		if colorOptions := ColorScaleOptions(); colorOptions != "" {
+			scale += ":" + colorOptions
+		}
+		args = append(args, "-vf", deinterlace+","+scale)
+	} else if colorOptions := ColorScaleOptions(); colorOptions != "" {
+		args = append(args, "-vf", fmt.Sprintf("scale=%s:%s", resolution.ToFilterString(), colorOptions))
+	} else {
+		args = append(args, "-s", fmt.Sprintf("%dx%d", resolution.Width, resolution.Height))
+	}
+	args = append(args, ColorEncodeArgs()...)
+	return append(args, HdrEncodeArgs(filename)...)
+}
+
+// EncodeVideo encodes filename to outputFilename and sends nil, or the failure, to result.
+func EncodeVideo(filename string, outputFilename string, resolution Resolution, rate int, result chan error) {
+	videoLog(filename).Info("Encoding", "rate", rate, "resolution", resolution.ToFilterString())
+
+	if err := CreateOutputDirectory(outputFilename); err != nil {
+		result <- err
 		return
 	}
+	release := readLimiter.Acquire(filename)
+	defer release()
+	var firstPassUsage ProcessUsage
+	if cmd := BuildFirstPassCommand(filename, outputFilename, resolution, rate); cmd != nil {
+		defer RemoveIntermediate(PassLogFilename(outputFilename))
+		slog.Debug("Executing command", "cmd", cmd.String())
+		usage, err := RunMeasured(cmd, ErrEncodeFailed)
+		firstPassUsage = usage
+		if err != nil {
+			RecordEncodeUsage(outputFilename, usage)
+			summary.AddEncodeUsage(usage)
+			videoLog(filename).Error("Error in the first pass", "output", outputFilename, "err", err)
+			result <- err
+			return
+		}
+	}
+	cmd := BuildEncodeCommand(filename, outputFilename, resolution, rate)
+	slog.Debug("Executing command", "cmd", cmd.String())
+	usage, err := RunMeasured(cmd, ErrEncodeFailed)
+	usage = firstPassUsage.Add(usage)
+	RecordEncodeUsage(outputFilename, usage)
+	summary.AddEncodeUsage(usage)
+	if err != nil {
+		videoLog(filename).Error("Error encoding", "output", outputFilename, "err", err)
+	}
+	result <- err
+}
 
-	success <- true
+// ConfidenceInterval is the bootstrapped 95% confidence interval around a pooled VMAF mean.
+type ConfidenceInterval struct {
+	Low  float64
+	High float64
 }
 
-func ParseVmafScoreFromLogFile(logPath string) float64 {
+// Overlaps reports whether two intervals overlap, in which case the scores are statistically indistinguishable.
+func (ci *ConfidenceInterval) Overlaps(other *ConfidenceInterval) bool {
+	return ci.Low <= other.High && other.Low <= ci.High
+}
+
+// VmafMetrics holds the pooled VMAF statistics parsed from a libvmaf log. A negative Mean means scoring failed.
+type VmafMetrics struct {
+	Mean float64
+	Ci   *ConfidenceInterval
+
+	// Frames is the number of frames scored and ClampedFrames how many of them libvmaf clamped to 100.
+	Frames        int
+	ClampedFrames int
+
+	// StdDev is the standard deviation of the frame scores, nil when no frame was scored.
+	StdDev *float64
+
+	// FrameCountMismatch describes a frame count difference between the inputs that misaligns scoring.
+	FrameCountMismatch string
+
+	// ClipScores are the scores of the clips of a multi-clip reference.
+	ClipScores []ClipScore
+
+	// NegMean is the NEG model score when scored next to the main model with -vmaf-neg-gap.
+	NegMean *float64
+
+	// Chroma are the chroma-aware metrics with -vmaf-planes chroma.
+	Chroma *ChromaMetrics
+
+	// Usage is the time spent scoring, including any prescale.
+	Usage ProcessUsage
+
+	// Err is why scoring failed, see errors.go.
+	Err error
+}
+
+// nearlyClampedVmaf is the mean above which clamped frames are taken to hide headroom.
+const nearlyClampedVmaf = 99.0
+
+// Clamped reports whether libvmaf clamping may hide how much quality headroom the encode had.
+func (metrics VmafMetrics) Clamped() bool {
+	return metrics.Mean >= 100 || (metrics.ClampedFrames > 0 && metrics.Mean >= nearlyClampedVmaf)
+}
+
+// ParseVmafMetricsFromLogFile reads and removes a libvmaf log, unless -keep-intermediates is set.
+// The per-frame scores are also split into the clips of a multi-clip reference when clips is not
+// empty.
+func ParseVmafMetricsFromLogFile(logPath string, clips []ClipRange) VmafMetrics {
 	jsonFile, err := os.Open(logPath)
 	if err != nil {
-		fmt.Printf("Error opening log file: %s\n", err.Error())
-		return -1.0
+		slog.Error("Error opening log file", "err", err)
+		return VmafMetrics{Mean: -1.0, Err: fmt.Errorf("%w: %s", ErrVmafParse, err.Error())}
 	}
 	defer jsonFile.Close()
-	os.Remove(logPath)
-	byteValue, _ := ioutil.ReadAll(jsonFile)
-
-	var result map[string]map[string]map[string]interface{}
-	json.Unmarshal([]byte(byteValue), &result)
+	RemoveIntermediate(logPath)
 
-	return result["pooled_metrics"]["vmaf"]["mean"].(float64)
+	frames, clampedFrames := 0, 0
+	clipScores := NewClipScoreAccumulator(clips)
+	var frameStats FrameStats
+	metrics, err := ParseVmafLog(bufio.NewReader(jsonFile), func(frame VmafLogFrame) {
+		frames++
+		if frame.Metrics["vmaf"] >= 100 {
+			clampedFrames++
+		}
+		clipScores.Add(frame.FrameNum, frame.Metrics["vmaf"])
+		frameStats.Add(frame.Metrics["vmaf"])
+	})
+	metrics.Frames, metrics.ClampedFrames = frames, clampedFrames
+	metrics.StdDev = frameStats.StdDev()
+	metrics.ClipScores = clipScores.Scores()
+	if err != nil {
+		slog.Error("Error parsing log file", "file", logPath, "err", err)
+		return VmafMetrics{Mean: -1.0, Err: err}
+	}
+	return metrics
 }
 
-func ComputeVmaf(referenceFilename string, referenceResolution Resolution, testFilename string, result chan float64) {
-	fmt.Printf("Computing VMAF for %s and %s\n", referenceFilename, testFilename)
-	// Upscale the test video to the reference resolution if necessary, then compute the vmaf score.
+// SelectVmafModel returns the libvmaf model used to score an encode at the given resolution.
+// An empty model leaves the choice to libvmaf.
+func SelectVmafModel(resolution Resolution) string {
+	if config.VmafModel == "neg" {
+		return NegVmafModel
+	}
+	if config.VmafModel != "" {
+		return config.VmafModel
+	}
+	if !config.AutoPhoneModel {
+		return ""
+	}
+	if resolution.Height < config.PhoneModelMaxHeight {
+		return PhoneVmafModel
+	}
+	return StandardVmafModel
+}
 
-	// Compute the VMAF score.
-	logPath := fmt.Sprintf("%s.json", testFilename)
+// VmafModelFilterOption returns the libvmaf filter option that loads the model, escaped for use in a filter graph.
+// With confidence intervals enabled the standard model is swapped for its bootstrap variant; other
+// models have no bootstrap variant and are scored without intervals. With -vmaf-neg-gap the NEG
+// model is loaded next to it, so both scores come from one pass.
+func VmafModelFilterOption(model string) string {
+	version := model
+	transform := false
+	switch model {
+	case "":
+		if !config.VmafCi && !config.VmafNegGap {
+			return ""
+		}
+		version = StandardVmafModel
+	case PhoneVmafModel:
+		version, transform = StandardVmafModel, true
+	}
+	if config.VmafCi && version == StandardVmafModel {
+		version = BootstrapVmafModel
+	}
 
-	filterCmd := fmt.Sprintf("[0:v]scale=%s:flags=bicubic:[main];[main][1:v]libvmaf=n_threads=8:log_fmt=json:log_path=%s", referenceResolution.ToFilterString(), logPath)
+	option := fmt.Sprintf("model=version=%s", version)
+	if isVmafModelPath(version) {
+		option = fmt.Sprintf("model=path=%s", version)
+	}
+	if transform {
+		option += "\\\\:enable_transform=true"
+	}
+	if config.VmafNegGap {
+		option += fmt.Sprintf("\\\\:name=vmaf|version=%s\\\\:name=%s", NegVmafModel, negVmafMetric)
+	}
+	return option
+}
 
-	cmd := exec.Command("ffmpeg", "-i", testFilename, "-i", referenceFilename, "-filter_complex", filterCmd, "-f", "null", "-")
-	fmt.Printf("Executing command: %s\n", cmd.String())
-	err := cmd.Run()
-	if err != nil {
-		fmt.Printf("Error computing vmaf: %s\n", err.Error())
-		result <- -1.0
-		return
+// VmafLogPath returns the path libvmaf writes its JSON log to when scoring testFilename: next to
+// an encode, and in the encode directory for anything else, such as a source scored against itself.
+func VmafLogPath(testFilename string) string {
+	if root := encodeRoot(); root != "" && !withinDirectory(root, testFilename) {
+		return filepath.Join(encodeDirectory(testFilename), filepath.Base(testFilename)+".json")
 	}
+	return fmt.Sprintf("%s.json", testFilename)
+}
 
-	// Parse the log file.
-	result <- ParseVmafScoreFromLogFile(logPath)
+// VmafFilterGraph joins the filter chains applied to the selected video streams of the test (input
+// 0) and reference (input 1) and the libvmaf options into a filter graph.
+func VmafFilterGraph(testFilename string, referenceFilename string, testChain []string, referenceChain []string, vmafOptions []string) string {
+	var graph []string
+	testLabel, referenceLabel := "["+VideoStreamSpecifier(0, testFilename)+"]", "["+VideoStreamSpecifier(1, referenceFilename)+"]"
+	if len(testChain) > 0 {
+		graph = append(graph, testLabel+strings.Join(testChain, ",")+"[main]")
+		testLabel = "[main]"
+	}
+	if len(referenceChain) > 0 {
+		graph = append(graph, referenceLabel+strings.Join(referenceChain, ",")+"[ref]")
+		referenceLabel = "[ref]"
+	}
+	graph = append(graph, testLabel+referenceLabel+"libvmaf="+strings.Join(vmafOptions, ":"))
+	return strings.Join(graph, ";")
 }
 
-func GetOptimalResolutionForRate(referenceVideoFilename string, referenceVideoResolution Resolution, rate int, candidateResolution Resolution) (ConvexHullPoint, error) {
+// BuildVmafCommand returns the ffmpeg command that scores testFilename, encoded at testResolution,
+// against referenceFilename. A non-empty frameSelection is a select expression applied identically
+// to both inputs.
+func BuildVmafCommand(referenceFilename string, referenceResolution Resolution, testFilename string, testResolution Resolution, model string, frameSelection string) *exec.Cmd {
+	var testChain, referenceChain []string
+	if deinterlace := DeinterlaceFilter(referenceFilename); deinterlace != "" {
+		referenceChain = append(referenceChain, deinterlace)
+	}
+	if fps := FpsFilter(); fps != "" {
+		testChain = append(testChain, fps)
+		referenceChain = append(referenceChain, fps)
+	}
+	testTrim, referenceTrim := FrameOffsetFilters(config.FrameOffset)
+	if testTrim != "" {
+		testChain = append(testChain, testTrim)
+	}
+	if referenceTrim != "" {
+		referenceChain = append(referenceChain, referenceTrim)
+	}
+	if window, _ := ActiveScoringWindow(); window != nil {
+		testChain = append(testChain, window.TrimFilter())
+		referenceChain = append(referenceChain, window.TrimFilter())
+	}
+	if frameSelection != "" {
+		selectFilter := fmt.Sprintf("select='%s',setpts=N/FRAME_RATE/TB", frameSelection)
+		testChain = append(testChain, selectFilter)
+		referenceChain = append(referenceChain, selectFilter)
+	}
 
-	// Get the next candidate resolution.
-	nextResolution, err := GetNextResolution(candidateResolution)
-	if err != nil {
-		return ConvexHullPoint{Resolution: candidateResolution, Rate: rate, VmafScore: -1.}, nil
+	// Bring both inputs to the scoring resolution, see scaling.go, then compute the vmaf score. A
+	// prescaled encode is already there.
+	scoringResolution := ScoringResolution(referenceResolution, testResolution)
+	scoredResolution := testResolution
+	if config.Prescale {
+		scoredResolution = scoringResolution
+	}
+	if testScale := InputScaleFilter(scoredResolution, scoringResolution, config.ScaleDistorted); testScale != "" {
+		testChain = append(testChain, testScale)
+	}
+	if referenceScale := InputScaleFilter(referenceResolution, scoringResolution, config.ScaleReference); referenceScale != "" {
+		referenceChain = append(referenceChain, referenceScale)
+	}
+	if hdrFormat := HdrScoringFilter(referenceFilename); hdrFormat != "" {
+		testChain = append(testChain, hdrFormat)
+		referenceChain = append(referenceChain, hdrFormat)
+	}
+
+	vmafOptions := []string{fmt.Sprintf("n_threads=%d", cpuPlan.VmafThreads), "log_fmt=json", "log_path=" + VmafLogPath(testFilename)}
+	if config.VmafSubsample > 1 {
+		vmafOptions = append(vmafOptions, fmt.Sprintf("n_subsample=%d", config.VmafSubsample))
+	}
+	if modelOption := VmafModelFilterOption(model); modelOption != "" {
+		vmafOptions = append(vmafOptions, modelOption)
+	}
+	if featureOption := VmafFeatureFilterOption(); featureOption != "" {
+		vmafOptions = append(vmafOptions, featureOption)
 	}
 
-	referenceFileName := strings.TrimSuffix(referenceVideoFilename, ".mp4")
-	referenceExt := "mp4"
-	candidateResolutionEncodedFilename := fmt.Sprintf("%s_%dx%d_%dkbps.%s", referenceFileName, candidateResolution.Height, candidateResolution.Width, rate, referenceExt)
-	candidateResolutionEncodeSuccess := make(chan bool)
-	go EncodeVideo(referenceVideoFilename, candidateResolutionEncodedFilename, candidateResolution, rate, candidateResolutionEncodeSuccess)
+	filterCmd := VmafFilterGraph(testFilename, referenceFilename, testChain, referenceChain, vmafOptions)
+	args := append(SourceInputArgs(testFilename), SourceInputArgs(referenceFilename)...)
+	args = append(args, "-filter_complex", filterCmd, "-an", "-f", "null", "-")
+	return FfmpegCommand(args...)
+}
+
+// ComputeVmaf scores testFilename, encoded at testResolution or zero when unknown, against
+// referenceFilename and sends the metrics to result.
+func ComputeVmaf(referenceFilename string, referenceResolution Resolution, testFilename string, testResolution Resolution, model string, result chan VmafMetrics) {
+	videoLog(referenceFilename).Info("Computing VMAF", "test", testFilename)
+
+	var prescaleUsage ProcessUsage
+	if config.Prescale {
+		usage, err := PrescaleVideo(testFilename, referenceResolution, testResolution)
+		prescaleUsage = usage
+		if err != nil {
+			videoLog(referenceFilename).Error("Error prescaling", "test", testFilename, "err", err)
+			result <- VmafMetrics{Mean: -1.0, Err: err}
+			return
+		}
+	}
+	scoredFilename := ScoredFilename(testFilename)
+	if err := CheckScaling(scoredFilename, referenceResolution, testResolution); err != nil {
+		videoLog(referenceFilename).Error("Error scaling for scoring", "test", testFilename, "err", err)
+		result <- VmafMetrics{Mean: -1.0, Err: fmt.Errorf("%w: %s", ErrVmafFailed, err.Error())}
+		return
+	}
 
-	nextResolutionEncodedFilename := fmt.Sprintf("%s_%dx%d_%dkbps.%s", referenceFileName, nextResolution.Height, nextResolution.Width, rate, referenceExt)
-	nextResolutionEncodeSuccess := make(chan bool)
-	go EncodeVideo(referenceVideoFilename, nextResolutionEncodedFilename, nextResolution, rate, nextResolutionEncodeSuccess)
+	// Compute the VMAF score.
+	logPath := VmafLogPath(scoredFilename)
+	if err := CreateOutputDirectory(logPath); err != nil {
+		videoLog(referenceFilename).Error("Error creating log directory", "err", err)
+		result <- VmafMetrics{Mean: -1.0, Err: fmt.Errorf("%w: %s", ErrVmafFailed, err.Error())}
+		return
+	}
+	frameSelection, err := VmafFrameSelection(referenceFilename)
+	if err != nil {
+		videoLog(referenceFilename).Error("Error selecting frames to score", "err", err)
+		result <- VmafMetrics{Mean: -1.0, Err: fmt.Errorf("%w: %s", ErrVmafFailed, err.Error())}
+		return
+	}
+	cmd := BuildVmafCommand(referenceFilename, referenceResolution, scoredFilename, testResolution, model, frameSelection)
+	release := readLimiter.Acquire(referenceFilename)
+	slog.Debug("Executing command", "cmd", cmd.String())
+	usage, err := RunMeasured(cmd, ErrVmafFailed)
+	release()
+	summary.AddVmafUsage(usage)
+	if config.Prescale {
+		videoLog(referenceFilename).Info("Scored", "test", scoredFilename, "took", time.Duration(usage.WallSeconds*float64(time.Second)))
+	}
+	if err != nil {
+		videoLog(referenceFilename).Error("Error computing VMAF", "test", testFilename, "err", err)
+		result <- VmafMetrics{Mean: -1.0, Err: err}
+		return
+	}
 
-	// Wait for the encodings to finish.
-	candidateEncodeSuccess := <-candidateResolutionEncodeSuccess
-	nextEncodeSuccess := <-nextResolutionEncodeSuccess
-	if !candidateEncodeSuccess || !nextEncodeSuccess {
-		return ConvexHullPoint{}, errors.New("failed to encode video")
+	// Parse the log file.
+	metrics := ParseVmafMetricsFromLogFile(logPath, ClipRangesOf(referenceFilename))
+	if mismatch := CheckFrameAlignment(referenceFilename, testFilename); mismatch != "" {
+		videoLog(referenceFilename).Warn("Frame counts differ", "test", testFilename, "mismatch", mismatch)
+		metrics.FrameCountMismatch = mismatch
 	}
+	metrics.Usage = prescaleUsage.Add(usage)
+	result <- metrics
+}
 
-	candidateVmafResult := make(chan float64, 1)
-	nextVmafResult := make(chan float64, 1)
+// EncodedFilename returns the name of the intermediate encode of the reference at the given resolution and rate,
+// named by -encode-template in the encode directory of the reference, see encodeDirectory.
+func EncodedFilename(referenceVideoFilename string, resolution Resolution, rate int) string {
+	directory := encodeDirectory(referenceVideoFilename)
+	name := ExpandRungTemplate(config.EncodeTemplate, referenceVideoFilename, ConvexHullPoint{Resolution: resolution, Rate: rate})
+	return filepath.Join(directory, name)
+}
 
-	// Compute VMAF for the two encodings.
-	go ComputeVmaf(referenceVideoFilename, referenceVideoResolution, candidateResolutionEncodedFilename, candidateVmafResult)
-	go ComputeVmaf(referenceVideoFilename, referenceVideoResolution, nextResolutionEncodedFilename, nextVmafResult)
+func GetOptimalResolutionForRate(referenceVideoFilename string, referenceVideoResolution Resolution, rate int, candidateResolution Resolution) (ConvexHullPoint, error) {
+	if err := CheckAssetBudget(referenceVideoFilename); err != nil {
+		RecordAttempt(referenceVideoFilename, ConvexHullPoint{Resolution: candidateResolution, Rate: rate, Status: PointTimeout, Reason: err.Error()})
+		return ConvexHullPoint{}, err
+	}
 
-	// Wait for the VMAF computations to finish.
-	candidateResolutionVmaf := <-candidateVmafResult
-	nextResolutionVmaf := <-nextVmafResult
+	// Compare the candidate against the next resolution down. At the bottom of the ladder there is
+	// nothing to compare against, so only the candidate is measured.
+	resolutionsToMeasure := []Resolution{candidateResolution}
+	nextResolution, err := GetNextResolution(candidateResolution)
+	if err == nil {
+		resolutionsToMeasure = append(resolutionsToMeasure, nextResolution)
+	}
 
-	os.Remove(candidateResolutionEncodedFilename)
-	os.Remove(nextResolutionEncodedFilename)
+	points := make([]ConvexHullPoint, len(resolutionsToMeasure))
+	errs := make([]error, len(resolutionsToMeasure))
+	var wg sync.WaitGroup
+	for i, resolution := range resolutionsToMeasure {
+		wg.Add(1)
+		go func(i int, resolution Resolution) {
+			defer wg.Done()
+			points[i], errs[i] = MeasureOrReuse(referenceVideoFilename, referenceVideoResolution, OperatingPoint{Resolution: resolution, Rate: rate})
+		}(i, resolution)
+	}
+	wg.Wait()
+	for _, err := range errs {
+		if err != nil {
+			return ConvexHullPoint{}, err
+		}
+	}
 
-	if candidateResolutionVmaf < 0 || nextResolutionVmaf < 0 {
-		return ConvexHullPoint{}, errors.New("failed to compute VMAF")
+	// Return the resolution with the best VMAF, less any -vmaf-stddev-penalty. Ties go to the lower
+	// resolution.
+	best := 0
+	for i := 1; i < len(points); i++ {
+		if points[i].SelectionScore() >= points[best].SelectionScore() {
+			best = i
+		}
 	}
 
-	// Return the resolution with the best VMAF.
-	if candidateResolutionVmaf > nextResolutionVmaf {
-		return ConvexHullPoint{Resolution: candidateResolution, Rate: rate, VmafScore: candidateResolutionVmaf}, nil
+	// Only leave the candidate resolution when the gain is worth a resolution switch, so the ladder
+	// does not flip-flop between adjacent rates on sub-threshold differences.
+	hysteresisApplied := false
+	if best != 0 && points[best].SelectionScore()-points[0].SelectionScore() < config.MinVmafGain {
+		best = 0
+		hysteresisApplied = true
+	}
+	for i := range points {
+		if i != best && points[i].VmafCi != nil && points[best].VmafCi != nil && points[i].VmafCi.Overlaps(points[best].VmafCi) {
+			videoLog(referenceVideoFilename).Info("VMAF at both resolutions is statistically indistinguishable", "resolution", resolutionsToMeasure[best].ToFilterString(), "other", resolutionsToMeasure[i].ToFilterString(), "rate", rate)
+		}
 	}
 
-	return ConvexHullPoint{Resolution: nextResolution, Rate: rate, VmafScore: nextResolutionVmaf}, nil
+	point := points[best]
+	point.HysteresisApplied = hysteresisApplied
+	return point, nil
 }
 
 func WalkConvexHull(referenceVideoFilename string, referenceVideoResolution Resolution, referenceVideoRate int) ([]ConvexHullPoint, error) {
+	if referenceVideoRate <= 0 {
+		return nil, ErrUnknownSourceRate
+	}
+	RecordSkippedRates(referenceVideoFilename, InfeasibleRates(referenceVideoRate), PointInfeasible, "above the source rate")
 	targetRates := GetTargetRates(referenceVideoRate)
+	if len(targetRates) == 0 {
+		lower, upper := RateBounds(referenceVideoRate)
+		return nil, fmt.Errorf("no target rates between %d and %d kbps for a %d kbps source", lower, upper, referenceVideoRate)
+	}
 
+	if config.ParallelRates > 1 {
+		return WalkConvexHullParallel(referenceVideoFilename, referenceVideoResolution, targetRates)
+	}
+	return walkRates(referenceVideoFilename, referenceVideoResolution, targetRates)
+}
+
+// walkRates walks the target rates from the highest down, starting each from the resolution the
+// rate above settled on.
+func walkRates(referenceVideoFilename string, referenceVideoResolution Resolution, targetRates []int) ([]ConvexHullPoint, error) {
 	convexHull := make([]ConvexHullPoint, 0)
-	currentResolution := referenceVideoResolution
-	for _, targetRate := range targetRates {
+	currentResolution := WalkStart(referenceVideoResolution)
+	for i, targetRate := range targetRates {
 		convexHullPoint, err := GetOptimalResolutionForRate(referenceVideoFilename, referenceVideoResolution, targetRate, currentResolution)
 		if err != nil {
-			fmt.Printf("Error getting optimal resolution for rate %d. Error code: %s\n", targetRate, err.Error())
+			videoLog(referenceVideoFilename).Error("Error getting optimal resolution", "rate", targetRate, "err", err)
+			if errors.Is(err, ErrTimeout) {
+				RecordSkippedRates(referenceVideoFilename, targetRates[i+1:], PointTimeout, err.Error())
+			} else {
+				RecordSkippedRates(referenceVideoFilename, targetRates[i+1:], PointSkipped, fmt.Sprintf("walk stopped at %d kbps", targetRate))
+			}
 			return convexHull, err
 		}
 		convexHull = append(convexHull, convexHullPoint)
@@ -196,13 +769,52 @@ func WalkConvexHull(referenceVideoFilename string, referenceVideoResolution Reso
 	return convexHull, nil
 }
 
+// DescendToOptimalResolution walks down the ladder from startResolution at a fixed rate until the
+// next resolution no longer scores better, and returns the point it settles on. Each step measures
+// only the next resolution, the current one having been measured by the step before.
+func DescendToOptimalResolution(referenceVideoFilename string, referenceVideoResolution Resolution, rate int, startResolution Resolution) (ConvexHullPoint, error) {
+	currentResolution := startResolution
+	for {
+		point, err := GetOptimalResolutionForRate(referenceVideoFilename, referenceVideoResolution, rate, currentResolution)
+		if err != nil || point.Resolution == currentResolution {
+			return point, err
+		}
+		currentResolution = point.Resolution
+	}
+}
+
+// WalkConvexHullParallel computes the hull of the sequential walk with up to config.ParallelRates
+// rates measured at once. The sequential walk starts each rate from the resolution the rate above
+// settled on, which is not known until that rate is done, so each rate first descends from the
+// walk start on its own, in parallel, which measures the resolutions the sequential walk is likely
+// to compare at that rate. The sequential walk then runs over those measurements, reused from the
+// attempts, and measures only the points the descents did not reach. The hull is that of the
+// sequential walk, at the cost of measuring the resolutions above where a rate starts in it.
+func WalkConvexHullParallel(referenceVideoFilename string, referenceVideoResolution Resolution, targetRates []int) ([]ConvexHullPoint, error) {
+	slots := make(chan struct{}, config.ParallelRates)
+	var wg sync.WaitGroup
+	for _, targetRate := range targetRates {
+		wg.Add(1)
+		go func(targetRate int) {
+			defer wg.Done()
+			slots <- struct{}{}
+			defer func() { <-slots }()
+			// A failed descent is left to the sequential walk, which measures the point again and
+			// stops there.
+			DescendToOptimalResolution(referenceVideoFilename, referenceVideoResolution, targetRate, WalkStart(referenceVideoResolution))
+		}(targetRate)
+	}
+	wg.Wait()
+	return walkRates(referenceVideoFilename, referenceVideoResolution, targetRates)
+}
+
 func GetVideoResolutionAndBitrate(filename string) (Resolution, int) {
 	resolution := Resolution{}
 	rate := -1
 
 	video, err := vidio.NewVideo(filename)
 	if err != nil {
-		fmt.Printf("Error opening video %s. Error code: %s\n", filename, err.Error())
+		videoLog(filename).Error("Error opening video", "err", err)
 		return resolution, rate
 	}
 	resolution.Width = video.Width()
@@ -211,42 +823,215 @@ func GetVideoResolutionAndBitrate(filename string) (Resolution, int) {
 	return resolution, rate
 }
 
-func WriteConvexHullToJson(convexHull []ConvexHullPoint, filename string) error {
+// ConvexHullResult is the document written for each asset: a metadata header followed by the hull.
+type ConvexHullResult struct {
+	Metadata   RunMetadata
+	ConvexHull []ConvexHullPoint
+	Knees      []KneePoint `json:",omitempty"`
+	// Grid holds every measured point when the hull was computed over a -grid or extended.
+	Grid []ConvexHullPoint `json:",omitempty"`
+	// Attempts holds every operating point attempted, with its status, including failed ones.
+	Attempts []ConvexHullPoint `json:",omitempty"`
+	// HdrTransfer is the transfer characteristics of an HDR source, see hdr.go.
+	HdrTransfer string `json:",omitempty"`
+	// FieldParity is the field parity of an interlaced source, see deinterlace.go.
+	FieldParity string `json:",omitempty"`
+	// SourceResolution is the resolution of the source before rotation, and SourceResolutionFrom
+	// the tool it was read with, see ReconcileResolution.
+	SourceResolution     *Resolution `json:",omitempty"`
+	SourceResolutionFrom string      `json:",omitempty"`
+	// VmafBaseline is the score of the source against itself with -normalize-vmaf.
+	VmafBaseline *float64 `json:",omitempty"`
+	// VideoStream is the ffprobe index of the source stream that was read, see stream.go.
+	VideoStream *int `json:",omitempty"`
+	// QuickBounds replaces the hull with -quick-bounds.
+	QuickBounds *QuickBounds `json:",omitempty"`
+	// TargetVmaf replaces the hull with -target-vmaf.
+	TargetVmaf *TargetSearch `json:",omitempty"`
+	// InputHash is the digest of the source with -input-hash, see inputhash.go.
+	InputHash *InputDigest `json:",omitempty"`
+}
+
+func WriteConvexHullToJson(result ConvexHullResult, filename string) error {
+	return WriteJson(result, filename)
+}
+
+// WriteJson writes value to filename as indented JSON.
+func WriteJson(value interface{}, filename string) error {
 	jsonFile, err := os.Create(filename)
 	if err != nil {
-		fmt.Printf("Error creating json file %s. Error code: %s\n", filename, err.Error())
+		slog.Error("Error creating json file", "file", filename, "err", err)
 		return err
 	}
 	defer jsonFile.Close()
 
 	encoder := json.NewEncoder(jsonFile)
 	encoder.SetIndent("", "    ")
-	err = encoder.Encode(convexHull)
+	err = encoder.Encode(value)
 	if err != nil {
-		fmt.Printf("Error encoding json file %s. Error code: %s\n", filename, err.Error())
+		slog.Error("Error encoding json file", "file", filename, "err", err)
 		return err
 	}
 	return nil
 }
 
+// ReadConvexHullFromJson reads a hull written by WriteConvexHullToJson. Files written before the
+// metadata header was added hold a bare array of points and are returned with empty metadata.
+func ReadConvexHullFromJson(filename string) (ConvexHullResult, error) {
+	var result ConvexHullResult
+	byteValue, err := os.ReadFile(filename)
+	if err != nil {
+		return result, err
+	}
+
+	if trimmed := strings.TrimSpace(string(byteValue)); strings.HasPrefix(trimmed, "[") {
+		err = json.Unmarshal(byteValue, &result.ConvexHull)
+	} else {
+		err = json.Unmarshal(byteValue, &result)
+	}
+	if err != nil {
+		return result, fmt.Errorf("parsing %s: %w", filename, err)
+	}
+	return result, nil
+}
+
+// HullFilename returns the path the result of videoFilename is written to in the current mode.
+func HullFilename(videoFilename string) string {
+	convexHullFilename := ExpandOutputTemplate(config.OutputTemplate, videoFilename)
+	if config.QuickBounds {
+		convexHullFilename = QuickBoundsFilename(convexHullFilename)
+	}
+	if config.TargetVmaf > 0 {
+		convexHullFilename = TargetVmafFilename(convexHullFilename)
+	}
+	return convexHullFilename
+}
+
+// WritesFullHull reports whether the run writes hulls, which complete a video, rather than the
+// -quick-bounds, -target-vmaf or -encode-only results that leave it to a later walk.
+func WritesFullHull() bool {
+	return !config.QuickBounds && config.TargetVmaf <= 0 && !config.EncodeOnly
+}
+
 func EstimateVmafConvexHull(videoFilename string, wg *sync.WaitGroup) {
 	defer wg.Done()
-	convexHullFilename := fmt.Sprintf("%s.json", strings.TrimSuffix(videoFilename, ".mp4"))
+	started := time.Now()
+	logger := videoLog(videoFilename)
+	convexHullFilename := HullFilename(videoFilename)
+	var existing *ConvexHullResult
 	_, err := os.OpenFile(convexHullFilename, os.O_RDONLY, 0666)
-	if !os.IsNotExist(err) {
-		fmt.Printf("Convex hull file %s already exists. Skipping.\n", convexHullFilename)
+	exists := !os.IsNotExist(err)
+	var previous ConvexHullResult
+	var readErr error
+	if exists {
+		previous, readErr = ReadConvexHullFromJson(convexHullFilename)
+	}
+	if exists && readErr == nil && config.InputHash != "" {
+		changed, err := InputChanged(videoFilename, previous.InputHash)
+		if err != nil {
+			logger.Error("Error hashing", "err", err)
+			summary.Record(videoFilename, AssetFailed, err.Error())
+			return
+		}
+		if changed {
+			logger.Info("Video changed since its hull was written, recomputing", "hull", convexHullFilename)
+			exists = false
+		}
+	}
+	if exists {
+		if readErr == nil {
+			for _, mismatch := range VersionMismatches(previous.Metadata, runMetadata) {
+				logger.Warn("Hull was produced with different versions, results are not comparable", "hull", convexHullFilename, "mismatch", mismatch)
+			}
+		}
+		if !config.Extend {
+			logger.Info("Hull already exists, skipping", "hull", convexHullFilename)
+			summary.Record(videoFilename, AssetSkipped, "hull already exists")
+			if WritesFullHull() {
+				if err := completedManifest.Add(videoFilename); err != nil {
+					logger.Error("Error updating completed manifest", "err", err)
+				}
+			}
+			return
+		}
+		if readErr == nil {
+			readErr = CheckExtendable(previous.Metadata)
+		}
+		if readErr != nil {
+			logger.Error("Error reading hull to extend", "hull", convexHullFilename, "err", readErr)
+			summary.Record(videoFilename, AssetFailed, readErr.Error())
+			return
+		}
+		existing = &previous
+	}
+	if err := batchManifest.Update(videoFilename, AssetRunning, ""); err != nil {
+		logger.Error("Error updating manifest", "err", err)
+	}
+	vidioResolution, rate := GetVideoResolutionAndBitrate(videoFilename)
+	stream, probeErr := SelectVideoStream(videoFilename)
+	if probeErr != nil {
+		logger.Error("Error probing", "err", probeErr)
+	}
+	resolution, resolutionSource := ReconcileResolution(videoFilename, vidioResolution, stream, probeErr)
+	if resolution.Height <= 0 || resolution.Width <= 0 {
+		logger.Error("Error reading resolution")
+		summary.Record(videoFilename, AssetFailed, "resolution is unknown")
 		return
 	}
+	sourceResolution := resolution
+	if rate <= 0 {
+		fallbackRate, err := FallbackSourceRate(videoFilename)
+		if err != nil {
+			logger.Error("Error reading bitrate", "err", err)
+			summary.Record(videoFilename, AssetFailed, err.Error())
+			return
+		}
+		logger.Info("Bitrate is not in the metadata, using the fallback", "rate", fallbackRate)
+		rate = fallbackRate
+	}
+	logger.Info("Probed", "resolution", resolution.ToFilterString(), "source", resolutionSource, "rate", rate)
 
-	resolution, rate := GetVideoResolutionAndBitrate(videoFilename)
-	fmt.Printf("Resolution: %s Rate: %d\n", resolution.ToFilterString(), rate)
-	if resolution.Height > 1080 {
-		fmt.Printf("Video %s has resolution %dx%d. Skipping.\n", videoFilename, resolution.Height, resolution.Width)
+	filterReason, err := InputFilterReason(videoFilename, stream, probeErr, resolution, rate)
+	if err != nil {
+		logger.Error("Error applying input filters", "err", err)
+		summary.Record(videoFilename, AssetFailed, err.Error())
+		return
+	}
+	if filterReason != "" {
+		logger.Info("Input filters exclude video, skipping", "reason", filterReason)
+		summary.Record(videoFilename, AssetSkipped, filterReason)
 		return
 	}
 
-	found := false
-	for _, validResolution := range resolutions {
+	hdrTransfer := ""
+	if probeErr == nil {
+		hdrTransfer = HdrTransfer(stream)
+		if err := CheckVmafFps(stream); err != nil {
+			logger.Error("Error scoring at a matched frame rate", "err", err)
+			summary.Record(videoFilename, AssetFailed, err.Error())
+			return
+		}
+		for _, warning := range CheckColorTags(stream) {
+			logger.Warn(warning)
+		}
+		if rotation := stream.Rotation(); rotation != 0 {
+			resolution = EffectiveResolution(resolution, stream)
+			logger.Info("Video is rotated, walking it as displayed", "rotation", rotation, "resolution", resolution.ToFilterString())
+		}
+	}
+	if hdrTransfer != "" && !config.Hdr {
+		logger.Info("Video is HDR, skipping, pass -hdr to score the HDR signal", "transfer", hdrTransfer)
+		summary.Record(videoFilename, AssetSkipped, "HDR source without -hdr")
+		return
+	}
+	if config.MaxHeight > 0 && resolution.ShortSide() > config.MaxHeight {
+		logger.Info("Resolution is above -max-height, skipping", "resolution", resolution.ToFilterString(), "max_height", config.MaxHeight)
+		summary.Record(videoFilename, AssetSkipped, fmt.Sprintf("resolution above %dp", config.MaxHeight))
+		return
+	}
+
+	found := len(operatingGrid) > 0 || WalkStart(resolution) != resolution
+	for _, validResolution := range LadderFor(resolution) {
 		if resolution.Height == validResolution.Height && resolution.Width == validResolution.Width {
 			found = true
 			break
@@ -254,31 +1039,211 @@ func EstimateVmafConvexHull(videoFilename string, wg *sync.WaitGroup) {
 	}
 
 	if !found {
-		fmt.Printf("Video %s has resolution %dx%d. Skipping.\n", videoFilename, resolution.Height, resolution.Width)
+		logger.Info("Resolution is not walkable, skipping", "resolution", resolution.ToFilterString())
+		summary.Record(videoFilename, AssetSkipped, fmt.Sprintf("resolution %s is not on the ladder", resolution.ToFilterString()))
+		return
+	}
+
+	if config.DryRun {
+		PrintDryRun(videoFilename, resolution, rate)
+		summary.Record(videoFilename, AssetSkipped, "dry run")
+		return
+	}
+
+	var inputHash *InputDigest
+	if config.InputHash != "" {
+		digest, err := HashInput(videoFilename, config.InputHash)
+		if err != nil {
+			logger.Error("Error hashing", "err", err)
+			summary.Record(videoFilename, AssetFailed, err.Error())
+			return
+		}
+		inputHash = &digest
+	}
+
+	fieldParity := ""
+	if probeErr == nil {
+		parity, err := DetectFieldParity(videoFilename, stream)
+		if err != nil {
+			logger.Error("Error detecting interlacing", "err", err)
+		}
+		fieldParity = parity
+		if fieldParity != "" && config.Deinterlace == "" {
+			logger.Warn("Video is interlaced and scored with its combing, pass -deinterlace to deinterlace it", "parity", fieldParity)
+		}
+	}
+
+	// The walk reads the reference from referenceFilename, which is a lossless clip with -clip. The
+	// source rate and resolution still come from the asset itself.
+	referenceFilename := videoFilename
+	if config.Clip != "" || config.Clips > 0 {
+		extract := ExtractClip
+		if config.Clips > 0 {
+			extract = ExtractClips
+		}
+		clipFilename, cleanup, err := extract(videoFilename)
+		if err != nil {
+			logger.Error("Error extracting clips", "err", err)
+			summary.Record(videoFilename, AssetFailed, err.Error())
+			return
+		}
+		defer cleanup()
+		referenceFilename = clipFilename
+	}
+	if hdrTransfer != "" {
+		RegisterHdrReference(referenceFilename, hdrTransfer)
+	}
+	if fieldParity != "" && config.Deinterlace != "" {
+		RegisterInterlacedReference(referenceFilename, fieldParity)
+	}
+	// Attempts are taken for the result, this only forgets them when no result is written.
+	defer TakeAttempts(referenceFilename)
+	defer StartAssetBudget(referenceFilename, started)()
+
+	if config.EncodeOnly {
+		BenchmarkVideo(referenceFilename, resolution, rate, convexHullFilename)
+		return
+	}
+	if config.QuickBounds {
+		WriteQuickBounds(videoFilename, referenceFilename, resolution, rate, inputHash, convexHullFilename)
+		return
+	}
+	if config.TargetVmaf > 0 {
+		WriteTargetVmaf(videoFilename, referenceFilename, resolution, rate, inputHash, convexHullFilename)
 		return
 	}
 
-	convexHull, err := WalkConvexHull(videoFilename, resolution, rate)
+	// An asset out of -asset-timeout still writes the hull of the points measured in time.
+	var convexHull, grid []ConvexHullPoint
+	var timeoutErr error
+	if len(operatingGrid) > 0 || config.Exhaustive {
+		points := operatingGrid
+		if config.Exhaustive {
+			RecordSkippedRates(referenceFilename, InfeasibleRates(rate), PointInfeasible, "above the source rate")
+			points = ExhaustiveGrid(resolution, rate)
+		}
+		grid, err = MeasureOperatingGrid(referenceFilename, resolution, points)
+		if errors.Is(err, ErrTimeout) {
+			timeoutErr = err
+		} else if err != nil {
+			logger.Error("Error measuring operating grid", "err", err)
+			summary.Record(videoFilename, AssetFailed, err.Error())
+			return
+		}
+		convexHull = HullOfPoints(grid)
+	} else if existing != nil {
+		convexHull, grid, err = ExtendConvexHull(referenceFilename, resolution, rate, *existing)
+		if errors.Is(err, ErrTimeout) {
+			timeoutErr = err
+		} else if err != nil {
+			logger.Error("Error extending convex hull", "err", err)
+			summary.Record(videoFilename, AssetFailed, err.Error())
+			return
+		}
+	} else {
+		convexHull, err = WalkConvexHull(referenceFilename, resolution, rate)
+		if errors.Is(err, ErrTimeout) {
+			timeoutErr = err
+		} else if err != nil {
+			logger.Error("Error walking convex hull", "err", err)
+			summary.Record(videoFilename, AssetFailed, err.Error())
+			return
+		}
+	}
+	if timeoutErr != nil {
+		logger.Warn("Video ran out of time, writing the hull points measured", "points", len(convexHull), "err", timeoutErr)
+	}
+
+	if config.SanityCheck {
+		convexHull = SanityCheckHull(referenceFilename, convexHull, grid)
+	}
+	if config.MonotonicResolution {
+		convexHull = EnforceMonotonicResolution(referenceFilename, resolution, convexHull, append(AttemptsOf(referenceFilename), grid...))
+	}
+	if config.MaxResolutionStep > 0 {
+		convexHull = LimitResolutionStep(referenceFilename, resolution, convexHull, append(AttemptsOf(referenceFilename), grid...), config.MaxResolutionStep)
+	}
+
+	result := ConvexHullResult{Metadata: runMetadata, ConvexHull: convexHull, Knees: FindKnees(convexHull), Grid: grid, Attempts: TakeAttempts(referenceFilename), HdrTransfer: hdrTransfer, FieldParity: fieldParity, SourceResolution: &sourceResolution, SourceResolutionFrom: resolutionSource, InputHash: inputHash}
+	if probeErr == nil {
+		result.VideoStream = &stream.Index
+	}
+	if config.NormalizeVmaf {
+		baseline, err := ScoreBaseline(referenceFilename, resolution)
+		if err != nil {
+			logger.Error("Error normalizing VMAF", "err", err)
+			summary.Record(videoFilename, AssetFailed, err.Error())
+			return
+		}
+		result.VmafBaseline = &baseline
+		NormalizeVmaf(result.ConvexHull, baseline)
+		NormalizeVmaf(result.Grid, baseline)
+		NormalizeVmaf(result.Attempts, baseline)
+	}
+
+	err = CreateOutputDirectory(convexHullFilename)
 	if err != nil {
-		fmt.Printf("Error walking convex hull for %s. Error code: %s\n", videoFilename, err.Error())
+		logger.Error("Error creating output directory", "file", convexHullFilename, "err", err)
+		summary.Record(videoFilename, AssetFailed, err.Error())
 		return
 	}
 
-	err = WriteConvexHullToJson(convexHull, convexHullFilename)
+	err = WriteConvexHullToJson(result, convexHullFilename)
 	if err != nil {
-		fmt.Printf("Error writing convex hull to json file %s. Error code: %s\n", convexHullFilename, err.Error())
+		logger.Error("Error writing convex hull", "file", convexHullFilename, "err", err)
+		summary.Record(videoFilename, AssetFailed, err.Error())
+		return
+	}
+
+	if config.LadderScript != "" {
+		if err := WriteLadderScript(videoFilename, convexHull, hdrTransfer); err != nil {
+			logger.Error("Error writing ladder script", "err", err)
+		}
+	}
+
+	if config.Heatmap != "" {
+		if err := WriteHeatmap(result, resolution, rate, convexHullFilename); err != nil {
+			logger.Error("Error writing heatmap", "err", err)
+		}
+	}
+
+	if config.VegaLite {
+		if err := WriteVegaLiteSpec(videoFilename, convexHull, convexHullFilename); err != nil {
+			logger.Error("Error writing plot spec", "err", err)
+		}
+	}
+
+	if !HasValidPoint(convexHull) {
+		summary.RecordResult(videoFilename, AssetFailed, "no valid hull points", &result)
+		return
+	}
+	if err := combinedCsv.Append(videoFilename, convexHull); err != nil {
+		logger.Error("Error appending to combined CSV", "file", config.CombinedCsv, "err", err)
+	}
+	if timeoutErr != nil {
+		summary.RecordResult(videoFilename, AssetPartial, timeoutErr.Error(), &result)
+		return
+	}
+	if err := completedManifest.Add(videoFilename); err != nil {
+		logger.Error("Error updating completed manifest", "err", err)
 	}
+	summary.RecordResult(videoFilename, AssetDone, "", &result)
 }
 
+// readLines returns the lines of the file at path, or of standard input when path is -.
 func readLines(path string) ([]string, error) {
-	file, err := os.Open(path)
-	if err != nil {
-		return nil, err
+	var input io.Reader = os.Stdin
+	if path != "-" {
+		file, err := os.Open(path)
+		if err != nil {
+			return nil, err
+		}
+		defer file.Close()
+		input = file
 	}
-	defer file.Close()
 
 	var lines []string
-	scanner := bufio.NewScanner(file)
+	scanner := bufio.NewScanner(input)
 	for scanner.Scan() {
 		lines = append(lines, scanner.Text())
 	}
@@ -297,22 +1262,235 @@ func IntMin(a, b int) int {
 //	fmt.Printf("Target rates: %v\n", GetTargetRates(1000))
 //}
 
+// subcommands maps subcommand names to their entry points. Without a subcommand the tool runs hull.
+var subcommands = map[string]func(args []string) int{
+	"ab":              RunAbCommand,
+	"apply":           RunApplyCommand,
+	"compare":         RunDiffCommand,
+	"diff":            RunDiffCommand,
+	"hls":             RunHlsCommand,
+	"hull":            RunHullCommand,
+	"ladder":          RunLadderCommand,
+	"ladders":         RunLaddersCommand,
+	"profiles":        RunProfilesCommand,
+	"selftest":        RunSelftestCommand,
+	"serve":           RunServeCommand,
+	"validate-config": RunValidateConfigCommand,
+}
+
 func main() {
-	filenames, err := readLines("filenames.txt")
+	if len(os.Args) > 1 {
+		if subcommand, ok := subcommands[os.Args[1]]; ok {
+			os.Exit(subcommand(os.Args[2:]))
+		}
+	}
+	os.Exit(RunHullCommand(os.Args[1:]))
+}
+
+// StartRun validates the parsed flags, checks the environment and sets up the state shared by the
+// walks of a run, exiting when any of it fails. scores tells whether the run scores with libvmaf,
+// which the preflight then checks ffmpeg for along with the encoder. It returns the function
+// removing the run temporary directory.
+func StartRun(scores bool) func() {
+	for _, check := range configChecks {
+		if err := check.Check(); err != nil {
+			slog.Error("Invalid "+check.Name, "err", err)
+			os.Exit(2)
+		}
+	}
+	if err := RequireBinaries(); err != nil {
+		slog.Error("Error checking ffmpeg", "err", err)
+		os.Exit(2)
+	}
+	if err := ApplyEncoderFallback(); err != nil {
+		slog.Error("Invalid encoder settings for the fallback encoder", "codec", config.Codec, "err", err)
+		os.Exit(2)
+	}
+	if err := Preflight(true, scores); err != nil {
+		slog.Error("Error checking ffmpeg", "err", err)
+		os.Exit(2)
+	}
+	ApplyHwaccel()
+	if err := ApplyCpuBudget(); err != nil {
+		slog.Error("Invalid -cpu-budget", "err", err)
+		os.Exit(2)
+	}
+	removeTempDir, err := StartRunTempDir()
 	if err != nil {
-		fmt.Printf("Error reading video filenames. Error code: %s\", err.Error()")
-		return
+		slog.Error("Error creating temporary directory", "err", err)
+		os.Exit(1)
+	}
+	runMetadata = NewRunMetadata()
+	if config.MaxReadsPerSource > 0 || config.MaxConcurrentReads > 0 {
+		readLimiter = NewReadLimiter(config.MaxReadsPerSource, config.MaxConcurrentReads)
+	}
+	return removeTempDir
+}
+
+// runOutputsClosed closes the outputs of the batch once, at its end or on a signal.
+var runOutputsClosed sync.Once
+
+// CloseRunOutputs closes the combined CSV and the completed manifest and waits for the pending
+// webhook deliveries.
+func CloseRunOutputs() {
+	runOutputsClosed.Do(func() {
+		if err := combinedCsv.Close(); err != nil {
+			slog.Error("Error closing combined CSV", "file", config.CombinedCsv, "err", err)
+		}
+		if err := completedManifest.Close(); err != nil {
+			slog.Error("Error closing completed manifest", "file", config.CompletedManifest, "err", err)
+		}
+		webhook.Wait()
+	})
+}
+
+// RunHullCommand implements the hull subcommand, the default: hull [flags] [video ...]. It walks
+// the convex hull of every video given, - reading their paths from standard input, or without
+// arguments of every video in -input-list or discovered with -scan.
+func RunHullCommand(args []string) int {
+	flags := flag.NewFlagSet("hull", flag.ExitOnError)
+	config.RegisterFlags(flags)
+	ParseFlags(flags, args)
+	removeTempDir := StartRun(!config.EncodeOnly)
+	defer removeTempDir()
+
+	filenames, err := InputFilenames(flags.Args())
+	if err != nil {
+		slog.Error("Error reading video filenames", "err", err)
+		return 1
+	}
+	// The overrides are read first, since an overridden codec changes the {codec} of the outputs
+	// looked for by -skip-existing and checked for collisions.
+	var overrides map[string]VideoOverrides
+	if config.Overrides != "" {
+		overrides, err = ReadVideoOverrides(config.Overrides)
+		if err != nil {
+			slog.Error("Error reading overrides", "file", config.Overrides, "err", err)
+			ExitRun(1)
+		}
+	}
+	if config.SkipExisting {
+		pending := WithoutExistingHullsOverridden(filenames, overrides)
+		slog.Info("Skipping videos that already have a hull", "skipped", len(filenames)-len(pending), "videos", len(filenames))
+		filenames = pending
+	}
+	if collisions := FindOutputCollisions(config.OutputTemplate, GroupByOverrides(filenames, overrides)); len(collisions) > 0 {
+		for output, videos := range collisions {
+			slog.Error("Videos would all be written to one output", "videos", strings.Join(videos, ","), "output", output)
+		}
+		slog.Error("Output template is not unique per video, include {dir} or {rel}", "template", config.OutputTemplate)
+		ExitRun(2)
+	}
+
+	if config.WebhookUrl != "" {
+		webhook = NewWebhook(config.WebhookUrl)
+	}
+	if config.CompletedManifest != "" {
+		completedManifest, err = OpenCompletedManifest(config.CompletedManifest)
+		if err != nil {
+			slog.Error("Error reading completed manifest", "file", config.CompletedManifest, "err", err)
+			ExitRun(1)
+		}
+		delta := completedManifest.Delta(filenames)
+		slog.Info("Read completed manifest", "file", config.CompletedManifest, "new", len(delta), "videos", len(filenames))
+		filenames = delta
+	}
+
+	if config.Resume && config.ManifestPath == "" {
+		slog.Error("-resume requires -manifest")
+		ExitRun(2)
+	}
+	if config.ManifestPath != "" {
+		if config.Resume {
+			batchManifest, err = LoadManifest(config.ManifestPath)
+		} else {
+			batchManifest = NewManifest(config.ManifestPath)
+		}
+		if err != nil {
+			slog.Error("Error reading manifest", "file", config.ManifestPath, "err", err)
+			ExitRun(1)
+		}
+		if batchManifest.Metadata == nil {
+			batchManifest.Metadata = &runMetadata
+		}
+		for _, mismatch := range VersionMismatches(*batchManifest.Metadata, runMetadata) {
+			slog.Warn("Resuming a batch started with different versions, results are not comparable", "mismatch", mismatch)
+		}
+		scheduled, err := batchManifest.Schedule(filenames)
+		if err != nil {
+			slog.Error("Error writing manifest", "file", config.ManifestPath, "err", err)
+			ExitRun(1)
+		}
+		slog.Info("Read manifest", "file", config.ManifestPath, "scheduled", len(scheduled), "videos", len(filenames))
+		filenames = scheduled
 	}
+
+	if config.CombinedCsv != "" {
+		combinedCsv, err = OpenCombinedCsv(config.CombinedCsv, config.Resume)
+		if err != nil {
+			slog.Error("Error opening combined CSV", "file", config.CombinedCsv, "err", err)
+			ExitRun(1)
+		}
+	}
+
+	groups := GroupByOverrides(filenames, overrides)
+
 	var wg sync.WaitGroup
-	batchSize := 100
-	for i := 0; i < len(filenames); i++ {
-		effectiveBatchSize := IntMin(len(filenames)-i, batchSize)
-		wg.Add(effectiveBatchSize)
-		for j := i; j < i+effectiveBatchSize; j++ {
-			go EstimateVmafConvexHull("videos/"+filenames[j], &wg)
+	batchSize := cpuPlan.Walks
+	if config.BatchSize > 0 {
+		batchSize = config.BatchSize
+	}
+	for _, group := range groups {
+		if summary.Aborted() {
+			for _, videoFilename := range group.Videos {
+				summary.Record(videoFilename, AssetAborted, "batch aborted after a failure")
+			}
+			continue
+		}
+		restore, err := ApplyVideoOverrides(group.Overrides)
+		if err != nil {
+			slog.Error("Invalid overrides", "overrides", fmt.Sprintf("%+v", group.Overrides), "err", err)
+			for _, videoFilename := range group.Videos {
+				summary.Record(videoFilename, AssetFailed, err.Error())
+			}
+			continue
+		}
+		if group.Overrides != (VideoOverrides{}) {
+			slog.Info("Walking videos with overrides", "videos", len(group.Videos), "overrides", fmt.Sprintf("%+v", group.Overrides))
+		}
+		videos := group.Videos
+		for i := 0; i < len(videos); i++ {
+			if summary.Aborted() {
+				slog.Error("Aborting the batch after a failure", "remaining", len(videos)-i)
+				for _, videoFilename := range videos[i:] {
+					summary.Record(videoFilename, AssetAborted, "batch aborted after a failure")
+				}
+				break
+			}
+			effectiveBatchSize := IntMin(len(videos)-i, batchSize)
+			wg.Add(effectiveBatchSize)
+			for j := i; j < i+effectiveBatchSize; j++ {
+				go EstimateVmafConvexHull(videos[j], &wg)
+			}
+			slog.Info("Batch started", "size", effectiveBatchSize)
+			i += effectiveBatchSize - 1
+			wg.Wait()
 		}
-		fmt.Printf("Batch of size %d started\n", effectiveBatchSize)
-		i += effectiveBatchSize - 1
-		wg.Wait()
+		restore()
+	}
+	CloseRunOutputs()
+
+	summary.Print()
+	if config.DryRun {
+		PrintDryRunTotals()
+	}
+	if config.FailureReport != "" {
+		if err := summary.WriteFailureReport(config.FailureReport); err != nil {
+			slog.Error("Error writing failure report", "file", config.FailureReport, "err", err)
+		}
+	}
+	if (config.Strict || config.OnError == AbortOnError) && len(summary.Failures()) > 0 {
+		return 1
 	}
+	return 0
 }
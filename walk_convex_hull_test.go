@@ -0,0 +1,169 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"reflect"
+	"sync"
+	"testing"
+)
+
+// syntheticScore models VMAF falling with the bits per pixel, so lower resolutions win at lower
+// rates, and capped by the resolution, so the source wins at high rates.
+func syntheticScore(resolution Resolution, rate int) float64 {
+	bitsPerPixel := float64(rate) * 1000 / float64(resolution.Width*resolution.Height)
+	ceiling := 100 * math.Pow(float64(resolution.Height)/1080, 0.15)
+	return ceiling * (1 - math.Exp(-bitsPerPixel*2))
+}
+
+// measurement is one operating point of one reference.
+type measurement struct {
+	Reference string
+	OperatingPoint
+}
+
+// stubMeasurements replaces the encode and scoring of operating points with score for the test and
+// returns how often each point was measured.
+func stubMeasurements(t *testing.T, score func(Resolution, int) float64) map[measurement]int {
+	t.Helper()
+	previousConfig, previousMeasure := config, measureOperatingPoint
+	config = DefaultConfig()
+	var mu sync.Mutex
+	measured := make(map[measurement]int)
+	measureOperatingPoint = func(referenceVideoFilename string, referenceVideoResolution Resolution, point OperatingPoint) (ConvexHullPoint, error) {
+		mu.Lock()
+		measured[measurement{referenceVideoFilename, point}]++
+		mu.Unlock()
+		return ConvexHullPoint{Resolution: point.Resolution, Rate: point.Rate, VmafScore: score(point.Resolution, point.Rate)}, nil
+	}
+	t.Cleanup(func() {
+		config, measureOperatingPoint = previousConfig, previousMeasure
+	})
+	return measured
+}
+
+func TestWalkReachesBottomOfLadder(t *testing.T) {
+	stubMeasurements(t, func(resolution Resolution, rate int) float64 {
+		// Every lower resolution scores better, so the walk descends a rung per rate.
+		return 100 - float64(resolution.Height)/100
+	})
+	reference := fmt.Sprintf("bottom-%s.mp4", t.Name())
+	defer TakeAttempts(reference)
+	config.RateStep = 100
+	bottom := resolutions[len(resolutions)-1]
+
+	hull, err := WalkConvexHull(reference, Resolution{1080, 1920}, 3000)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(hull) != len(GetTargetRates(3000)) {
+		t.Fatalf("hull has %d points, expected one per target rate", len(hull))
+	}
+	last := hull[len(hull)-1]
+	if last.Resolution != bottom || last.VmafScore < 0 {
+		t.Errorf("walk ended at %s with VMAF %f, expected a valid point at %s", last.Resolution.ToFilterString(), last.VmafScore, bottom.ToFilterString())
+	}
+	for _, point := range hull {
+		if point.VmafScore < 0 {
+			t.Errorf("point at %d kbps has VMAF %f", point.Rate, point.VmafScore)
+		}
+	}
+}
+
+func TestGetOptimalResolutionForRateAtBottom(t *testing.T) {
+	measured := stubMeasurements(t, syntheticScore)
+	reference := fmt.Sprintf("rung-%s.mp4", t.Name())
+	defer TakeAttempts(reference)
+	bottom := resolutions[len(resolutions)-1]
+
+	point, err := GetOptimalResolutionForRate(reference, Resolution{1080, 1920}, 200, bottom)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if point.Resolution != bottom || point.VmafScore != syntheticScore(bottom, 200) {
+		t.Errorf("got %+v, expected the bottom rung measured", point)
+	}
+	if len(measured) != 1 {
+		t.Errorf("measured %d points at the bottom rung, expected only the rung itself", len(measured))
+	}
+}
+
+func TestParallelWalkMatchesSequentialWalk(t *testing.T) {
+	measured := stubMeasurements(t, syntheticScore)
+	source := Resolution{1080, 1920}
+
+	sequentialReference := fmt.Sprintf("sequential-%s.mp4", t.Name())
+	defer TakeAttempts(sequentialReference)
+	sequential, err := WalkConvexHull(sequentialReference, source, 8000)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if sequential[0].Resolution == sequential[len(sequential)-1].Resolution {
+		t.Fatalf("sequential walk stayed at %s, the scores should make it descend", sequential[0].Resolution.ToFilterString())
+	}
+
+	for _, parallelRates := range []int{2, 4, 16} {
+		config.ParallelRates = parallelRates
+		reference := fmt.Sprintf("parallel%d-%s.mp4", parallelRates, t.Name())
+		parallel, err := WalkConvexHull(reference, source, 8000)
+		TakeAttempts(reference)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(parallel, sequential) {
+			t.Errorf("-parallel-rates %d hull\n%+v\ndiffers from the sequential hull\n%+v", parallelRates, parallel, sequential)
+		}
+	}
+	for point, count := range measured {
+		if count != 1 {
+			t.Errorf("%s at %d kbps was measured %d times for %s", point.Resolution.ToFilterString(), point.Rate, count, point.Reference)
+		}
+	}
+}
+
+func TestDescendMeasuresEachPointOnce(t *testing.T) {
+	measured := stubMeasurements(t, syntheticScore)
+	reference := fmt.Sprintf("descend-%s.mp4", t.Name())
+	defer TakeAttempts(reference)
+
+	if _, err := DescendToOptimalResolution(reference, Resolution{1080, 1920}, 500, Resolution{1080, 1920}); err != nil {
+		t.Fatal(err)
+	}
+	for point, count := range measured {
+		if count != 1 {
+			t.Errorf("%s at %d kbps was measured %d times", point.Resolution.ToFilterString(), point.Rate, count)
+		}
+	}
+}
+
+func TestGetTargetRates(t *testing.T) {
+	previous, previousResolutions, previousRates := config, resolutions, ladderRates
+	t.Cleanup(func() { config, resolutions, ladderRates = previous, previousResolutions, previousRates })
+
+	for _, test := range []struct {
+		name   string
+		setup  func()
+		source int
+		want   []int
+	}{
+		{"default", func() {}, 2600, []int{2500, 2000, 1500, 1000, 500}},
+		{"rate step below the minimum", func() { config.RateStep = 200 }, 1300, []int{1200, 1000, 800, 600}},
+		{"no minimum", func() { config.MinRate, config.RateStep = 0, 200 }, 700, []int{600, 400, 200}},
+		{"log spacing", func() { config.RateSpacing, config.RatePoints = LogRateSpacing, 3 }, 2000, []int{2000, 1000, 500}},
+		{"listed rates", func() { config.Rates = "100,200,400,800" }, 600, []int{400, 200, 100}},
+		{"ladder rates", func() { config.Ladder = "apple-hls" }, 1000, []int{730, 365, 145}},
+		{"ladder rates over a fraction", func() { config.Ladder, config.MinRateFraction = "apple-hls", 0.2 }, 1000, []int{730, 365}},
+	} {
+		config, ladderRates = DefaultConfig(), nil
+		test.setup()
+		if err := ApplyLadder(config.Ladder); err != nil {
+			t.Fatal(err)
+		}
+		if err := ApplyRates(); err != nil {
+			t.Fatal(err)
+		}
+		if got := GetTargetRates(test.source); !reflect.DeepEqual(got, test.want) {
+			t.Errorf("%s: got %v for a %d kbps source, want %v", test.name, got, test.source, test.want)
+		}
+	}
+}
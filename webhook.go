@@ -0,0 +1,119 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+)
+
+// -webhook POSTs a JSON notification to a URL as each asset finishes, so event-driven pipelines
+// need no watcher polling the outputs. Each notification carries the outcome of the asset and,
+// when it wrote one, its ConvexHullResult. Deliveries run in the background and are retried with
+// backoff on network errors and 5xx or 429 responses; a notification that cannot be delivered is
+// logged and never fails the analysis. The run waits for pending deliveries before it exits.
+
+// webhookBackoff is the wait before the first retry, doubled for every further retry.
+const webhookBackoff = time.Second
+
+// WebhookNotification is the body POSTed for each finished asset.
+type WebhookNotification struct {
+	AssetOutcome
+	Result *ConvexHullResult `json:",omitempty"`
+}
+
+// Webhook delivers notifications to one URL. A nil Webhook delivers nothing.
+type Webhook struct {
+	url     string
+	client  *http.Client
+	retries int
+	pending sync.WaitGroup
+}
+
+var webhook *Webhook
+
+// NewWebhook returns a webhook POSTing to rawUrl with the configured timeout and retries.
+func NewWebhook(rawUrl string) *Webhook {
+	return &Webhook{url: rawUrl, client: &http.Client{Timeout: config.WebhookTimeout}, retries: config.WebhookRetries}
+}
+
+// ValidateWebhook checks -webhook and its delivery settings.
+func ValidateWebhook() error {
+	if config.WebhookUrl == "" {
+		return nil
+	}
+	parsed, err := url.Parse(config.WebhookUrl)
+	if err != nil {
+		return err
+	}
+	if parsed.Scheme != "http" && parsed.Scheme != "https" {
+		return fmt.Errorf("%q is not an http or https URL", config.WebhookUrl)
+	}
+	if config.WebhookTimeout <= 0 {
+		return errors.New("-webhook-timeout must be positive")
+	}
+	if config.WebhookRetries < 0 {
+		return errors.New("-webhook-retries is negative")
+	}
+	return nil
+}
+
+// Notify delivers the notification of one asset in the background.
+func (w *Webhook) Notify(notification WebhookNotification) {
+	if w == nil {
+		return
+	}
+	body, err := json.Marshal(notification)
+	if err != nil {
+		videoLog(notification.Video).Error("Error encoding webhook notification", "err", err)
+		return
+	}
+	w.pending.Add(1)
+	go func() {
+		defer w.pending.Done()
+		if err := w.deliver(body); err != nil {
+			videoLog(notification.Video).Error("Error delivering webhook notification", "err", err)
+		}
+	}()
+}
+
+// deliver POSTs body, retrying failures that may be transient.
+func (w *Webhook) deliver(body []byte) error {
+	backoff := webhookBackoff
+	var err error
+	for attempt := 0; ; attempt++ {
+		var retry bool
+		retry, err = w.post(body)
+		if err == nil || !retry || attempt >= w.retries {
+			return err
+		}
+		time.Sleep(backoff)
+		backoff *= 2
+	}
+}
+
+// post POSTs body once and reports whether a failure is worth retrying.
+func (w *Webhook) post(body []byte) (bool, error) {
+	response, err := w.client.Post(w.url, "application/json", bytes.NewReader(body))
+	if err != nil {
+		return true, err
+	}
+	response.Body.Close()
+	if response.StatusCode >= 200 && response.StatusCode < 300 {
+		return false, nil
+	}
+	retry := response.StatusCode >= 500 || response.StatusCode == http.StatusTooManyRequests
+	return retry, fmt.Errorf("webhook responded %s", response.Status)
+}
+
+// Wait waits for every pending delivery.
+func (w *Webhook) Wait() {
+	if w == nil {
+		return
+	}
+	w.pending.Wait()
+}
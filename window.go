@@ -0,0 +1,61 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// ScoringWindow restricts scoring to part of the asset. Both inputs are trimmed identically, after
+// any frame offset, so only the window is decoded into libvmaf.
+type ScoringWindow struct {
+	// Unit is "frames" or "seconds". End is exclusive.
+	Unit  string
+	Start float64
+	End   float64
+}
+
+// ParseScoringWindow parses a "start-end" range in the given unit.
+func ParseScoringWindow(value string, unit string) (*ScoringWindow, error) {
+	parts := strings.SplitN(value, "-", 2)
+	if len(parts) != 2 {
+		return nil, fmt.Errorf("window %q is not of the form start-end", value)
+	}
+	start, err := strconv.ParseFloat(parts[0], 64)
+	if err != nil {
+		return nil, fmt.Errorf("window %q has an invalid start: %w", value, err)
+	}
+	end, err := strconv.ParseFloat(parts[1], 64)
+	if err != nil {
+		return nil, fmt.Errorf("window %q has an invalid end: %w", value, err)
+	}
+	if start < 0 || end <= start {
+		return nil, fmt.Errorf("window %q is empty", value)
+	}
+	if unit == "frames" && (start != float64(int(start)) || end != float64(int(end))) {
+		return nil, fmt.Errorf("frame window %q must use whole frames", value)
+	}
+	return &ScoringWindow{Unit: unit, Start: start, End: end}, nil
+}
+
+// ActiveScoringWindow returns the configured window, or nil to score the whole asset.
+func ActiveScoringWindow() (*ScoringWindow, error) {
+	switch {
+	case config.VmafFrames != "" && config.VmafTime != "":
+		return nil, errors.New("set only one of -vmaf-frames and -vmaf-time")
+	case config.VmafFrames != "":
+		return ParseScoringWindow(config.VmafFrames, "frames")
+	case config.VmafTime != "":
+		return ParseScoringWindow(config.VmafTime, "seconds")
+	}
+	return nil, nil
+}
+
+// TrimFilter returns the trim filter keeping only the window.
+func (window *ScoringWindow) TrimFilter() string {
+	if window.Unit == "frames" {
+		return fmt.Sprintf("trim=start_frame=%d:end_frame=%d,setpts=PTS-STARTPTS", int(window.Start), int(window.End))
+	}
+	return fmt.Sprintf("trim=start=%g:end=%g,setpts=PTS-STARTPTS", window.Start, window.End)
+}